@@ -4,26 +4,40 @@ import (
 	"errors"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+// Cache keeps fetched pages on disk, so a rerun does not hit every outlet again.
+// An entry older than the cache's ttl counts as missing; a ttl of 0 keeps
+// entries forever. It is safe for concurrent use: an entry is written to a
+// temporary file and renamed into place, so a reader never sees half of one.
 type Cache struct {
 	directory string
+	ttl       time.Duration
 }
 
-func NewCache(directory string) *Cache {
+func NewCache(directory string, ttl time.Duration) *Cache {
 	if !strings.HasSuffix(directory, "/") {
 		directory = directory + "/"
 	}
 	os.MkdirAll(directory, os.ModePerm)
 	return &Cache{
 		directory: directory,
+		ttl:       ttl,
 	}
 }
 
 func (c *Cache) Get(key string) (string, bool) {
-	filepath := c.directory + key
-	bytes, err := os.ReadFile(filepath)
+	path := c.directory + key
+	if c.ttl > 0 {
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) > c.ttl {
+			return "", false
+		}
+	}
+	bytes, err := os.ReadFile(path)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			log.Printf("error reading cache file: %v", err)
@@ -34,17 +48,24 @@ func (c *Cache) Get(key string) (string, bool) {
 }
 
 func (c *Cache) Put(key string, value string) {
-	filepath := c.directory + key
-	filepathParts := strings.Split(filepath, "/")
-	filepathDir := strings.Join(filepathParts[:len(filepathParts)-1], "/")
-	os.MkdirAll(filepathDir, os.ModePerm)
-	f, err := os.Create(filepath)
+	path := c.directory + key
+	dir := filepath.Dir(path)
+	os.MkdirAll(dir, os.ModePerm)
+	f, err := os.CreateTemp(dir, ".tmp-*")
 	if err != nil {
 		log.Printf("error creating cache file: %v", err)
+		return
 	}
-	defer f.Close()
+	defer os.Remove(f.Name())
 	_, err = f.WriteString(value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		log.Printf("error writing cache file: %v", err)
+		return
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		log.Printf("error writing cache file: %v", err)
 	}
 }
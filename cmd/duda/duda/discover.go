@@ -0,0 +1,206 @@
+package duda
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+// feedTypes are the link types a page advertises its feeds with.
+var feedTypes = []string{"application/rss+xml", "application/atom+xml", "application/feed+json", "application/json"}
+
+// commonFeedPaths are where feeds tend to live on sites that do not advertise
+// theirs, tried against the root of the outlet's homepage.
+var commonFeedPaths = []string{"/rss", "/rss.xml", "/feed", "/feed/", "/feed.xml", "/atom.xml", "/index.xml", "/feeds/posts/default", "/rss/nyheder"}
+
+// Feed is a feed found for an outlet, with what its items say about it.
+type Feed struct {
+	Url   string
+	Items int
+	// Interval is the median time between the feed's items, or 0 when fewer
+	// than two of them are dated.
+	Interval time.Duration
+	// Latest is when the newest item was published, if any are dated.
+	Latest time.Time
+}
+
+// Outlet is a homepage and the feeds that were found on it.
+type Outlet struct {
+	Link
+	// Language is the primary language subtag the homepage or its feeds
+	// declare, or "" if none do.
+	Language string
+	Feeds    []Feed
+	// PageText is the visible text of the homepage, and Headlines the titles
+	// of the newest items of its first feed: what a description is drafted
+	// from.
+	PageText  string
+	Headlines []string
+}
+
+// Bounds on what of an outlet is kept for drafting its description.
+const (
+	maxPageTextRunes = 3000
+	maxHeadlines     = 20
+)
+
+// Discover looks for the feeds of the outlet at link: those its homepage
+// links to as alternates, and those at the common feed paths. Every candidate
+// is fetched and parsed, and kept only if it is a feed with items. A feed
+// served at several paths is kept once, at the first of them.
+func (s *Scraper) Discover(link Link) (Outlet, error) {
+	outlet := Outlet{Link: link}
+	content, err := s.GetContent(link)
+	if err != nil {
+		return outlet, err
+	}
+	home, err := url.Parse(link.Url)
+	if err != nil {
+		return outlet, fmt.Errorf("invalid url %q: %w", link.Url, err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return outlet, fmt.Errorf("could not parse %v: %w", link.Url, err)
+	}
+
+	seen := make(map[string]bool)
+	for _, candidate := range feedCandidates(doc, home) {
+		parsed, err := s.parseFeed(candidate)
+		if err != nil {
+			continue
+		}
+		key := feedKey(parsed)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		outlet.Feeds = append(outlet.Feeds, feedStats(candidate, parsed))
+		if len(outlet.Feeds) == 1 {
+			outlet.Language = primaryLanguage(parsed.Language)
+			for _, item := range parsed.Items[:min(len(parsed.Items), maxHeadlines)] {
+				if title := strings.TrimSpace(item.Title); title != "" {
+					outlet.Headlines = append(outlet.Headlines, title)
+				}
+			}
+		}
+	}
+	if language := pageLanguage(doc); language != "" {
+		outlet.Language = language
+	}
+	outlet.PageText = pageText(doc)
+	return outlet, nil
+}
+
+// pageText is the page's title, its meta description and the text of its body,
+// with whitespace collapsed and cut at maxPageTextRunes.
+func pageText(doc *goquery.Document) string {
+	doc.Find("script, style, noscript, svg").Remove()
+	parts := []string{
+		doc.Find("title").First().Text(),
+		doc.Find(`meta[name="description"]`).AttrOr("content", ""),
+		doc.Find("body").Text(),
+	}
+	text := []rune(strings.Join(strings.Fields(strings.Join(parts, " ")), " "))
+	return string(text[:min(len(text), maxPageTextRunes)])
+}
+
+// feedCandidates returns the advertised feeds of the page at home, then the
+// common feed paths, without duplicates. Comment feeds are left out.
+func feedCandidates(doc *goquery.Document, home *url.URL) []string {
+	candidates := make([]string, 0)
+	add := func(ref string) {
+		resolved, err := home.Parse(ref)
+		if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+			return
+		}
+		if candidate := resolved.String(); !slices.Contains(candidates, candidate) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	doc.Find(`link[rel~="alternate"][href]`).Each(func(_ int, sel *goquery.Selection) {
+		linkType := strings.ToLower(strings.TrimSpace(sel.AttrOr("type", "")))
+		if !slices.Contains(feedTypes, linkType) {
+			return
+		}
+		href := sel.AttrOr("href", "")
+		if strings.Contains(strings.ToLower(sel.AttrOr("title", "")+href), "comment") {
+			return
+		}
+		add(href)
+	})
+	for _, path := range commonFeedPaths {
+		add(path)
+	}
+	return candidates
+}
+
+func (s *Scraper) parseFeed(feedUrl string) (*gofeed.Feed, error) {
+	content, err := s.GetContent(Link{Url: feedUrl})
+	if err != nil {
+		return nil, err
+	}
+	feed, err := gofeed.NewParser().ParseString(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(feed.Items) == 0 {
+		return nil, fmt.Errorf("%v has no items", feedUrl)
+	}
+	return feed, nil
+}
+
+// feedKey identifies a feed by its items, so the same feed at two URLs is
+// recognised.
+func feedKey(feed *gofeed.Feed) string {
+	keys := make([]string, 0, 5)
+	for _, item := range feed.Items[:min(len(feed.Items), 5)] {
+		keys = append(keys, item.Link+"|"+item.Title)
+	}
+	return strings.Join(keys, "\n")
+}
+
+func feedStats(feedUrl string, feed *gofeed.Feed) Feed {
+	stats := Feed{Url: feedUrl, Items: len(feed.Items)}
+	published := make([]time.Time, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		if item.PublishedParsed != nil {
+			published = append(published, *item.PublishedParsed)
+		}
+	}
+	if len(published) == 0 {
+		return stats
+	}
+	slices.SortFunc(published, func(a, b time.Time) int { return a.Compare(b) })
+	stats.Latest = published[len(published)-1]
+	if len(published) < 2 {
+		return stats
+	}
+	intervals := make([]time.Duration, 0, len(published)-1)
+	for i := 1; i < len(published); i++ {
+		intervals = append(intervals, published[i].Sub(published[i-1]))
+	}
+	slices.Sort(intervals)
+	stats.Interval = intervals[len(intervals)/2]
+	return stats
+}
+
+// pageLanguage is the language the page declares on its html element, or
+// failing that in its og:locale.
+func pageLanguage(doc *goquery.Document) string {
+	if language := primaryLanguage(doc.Find("html").AttrOr("lang", "")); language != "" {
+		return language
+	}
+	return primaryLanguage(doc.Find(`meta[property="og:locale"]`).AttrOr("content", ""))
+}
+
+// primaryLanguage reduces a language tag or locale, "da-DK" or "da_DK", to its
+// primary subtag.
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	return strings.ToLower(primary)
+}
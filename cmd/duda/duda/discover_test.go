@@ -0,0 +1,103 @@
+package duda
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+const testHomepage = `<html lang="da-DK"><head>
+<link rel="alternate" type="application/rss+xml" href="/rss" title="Nyheder">
+<link rel="alternate" type="application/atom+xml" href="https://feeds.example.dk/atom">
+<link rel="alternate" type="application/rss+xml" href="/comments/feed" title="Kommentarer">
+<link rel="alternate" type="application/rss+xml" href="/debat/rss" title="All comments">
+<link rel="alternate" type="text/html" href="/en">
+<link rel="alternate" type="application/rss+xml" href="javascript:void(0)">
+</head><body></body></html>`
+
+// The advertised feeds come first, resolved against the homepage, then the
+// common paths; the comment feeds, the non-feeds and the repeats are left out.
+func TestFeedCandidates(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testHomepage))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	home, _ := url.Parse("https://example.dk/forside")
+	got := feedCandidates(doc, home)
+	want := []string{"https://example.dk/rss", "https://feeds.example.dk/atom"}
+	for _, path := range commonFeedPaths[1:] {
+		want = append(want, "https://example.dk"+path)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("feedCandidates = %v, want %v", got, want)
+	}
+}
+
+func feedOf(items ...*gofeed.Item) *gofeed.Feed {
+	return &gofeed.Feed{Items: items}
+}
+
+func dated(title string, published string) *gofeed.Item {
+	item := &gofeed.Item{Title: title, Link: "https://example.dk/" + title}
+	if published != "" {
+		t, _ := time.Parse(time.RFC3339, published)
+		item.PublishedParsed = &t
+	}
+	return item
+}
+
+// The interval is the median between the dated items, in whatever order the
+// feed lists them.
+func TestFeedStats(t *testing.T) {
+	feed := feedOf(
+		dated("c", "2024-03-01T12:00:00Z"),
+		dated("a", "2024-03-01T10:00:00Z"),
+		dated("undated", ""),
+		dated("d", "2024-03-01T18:00:00Z"),
+		dated("b", "2024-03-01T11:00:00Z"),
+	)
+	got := feedStats("https://example.dk/rss", feed)
+	want := Feed{Url: "https://example.dk/rss", Items: 5, Interval: time.Hour, Latest: *feed.Items[3].PublishedParsed}
+	if got != want {
+		t.Errorf("feedStats = %+v, want %+v", got, want)
+	}
+
+	if got := feedStats("u", feedOf(dated("a", "2024-03-01T10:00:00Z"), dated("b", ""))); got.Interval != 0 || got.Latest.IsZero() {
+		t.Errorf("feedStats of one dated item = %+v, want its time and no interval", got)
+	}
+	if got := feedStats("u", feedOf(dated("a", ""))); got.Items != 1 || !got.Latest.IsZero() {
+		t.Errorf("feedStats of undated items = %+v, want no time", got)
+	}
+}
+
+// The same items make the same key, whichever URL they were fetched from; the
+// key is of the newest five only.
+func TestFeedKey(t *testing.T) {
+	items := []*gofeed.Item{dated("a", ""), dated("b", ""), dated("c", ""), dated("d", ""), dated("e", "")}
+	key := feedKey(feedOf(items...))
+	if got := feedKey(feedOf(append(slices.Clone(items), dated("f", ""))...)); got != key {
+		t.Errorf("key with a sixth item = %q, want %q", got, key)
+	}
+	if got := feedKey(feedOf(items[1:]...)); got == key {
+		t.Errorf("key of other items = %q, want it to differ", got)
+	}
+}
+
+func TestPrimaryLanguage(t *testing.T) {
+	for tag, want := range map[string]string{
+		"da":     "da",
+		"da-DK":  "da",
+		"da_DK":  "da",
+		" EN-gb": "en",
+		"":       "",
+	} {
+		if got := primaryLanguage(tag); got != want {
+			t.Errorf("primaryLanguage(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
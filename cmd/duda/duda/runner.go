@@ -1,28 +1,128 @@
 package duda
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
 	"log"
-	"strings"
+	"slices"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
 )
 
-func PrintPotentialRssFeedSites(cache *Cache) {
-	dudaScraper := NewScraper(cache)
+// fallbackLanguage is given to an outlet that declares no language. duda lists
+// Danish media, so that is the likely one.
+const fallbackLanguage = "da"
+
+// GenerateSites discovers the feeds of the outlets duda lists, and writes a JSON
+// array of a site for each outlet with new feeds to out. Sites are drafts, as
+// the import of the admin sites page or of the sites command creates them, and
+// have no id: the import numbers them as it creates them, so they cannot
+// collide with an existing site's. Feeds that already belong to an existing
+// site are left out, as is an outlet whose name is already a site's.
+//
+// With an aiClient, each site also gets a drafted description, for whoever
+// reviews the JSON to read and correct; without one, descriptions are left
+// empty.
+func GenerateSites(ctx context.Context, cache *Cache, existing []core.NewsSite, workers int, aiClient core.AiClient, out io.Writer) error {
+	dudaScraper := NewScraper(cache, workers)
 	links, err := dudaScraper.GetMediaUrls()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	workingLinks, err := dudaScraper.DownloadContents(links)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	for i, link := range workingLinks {
-		content, err := dudaScraper.GetContent(link)
+	outlets := make([]Outlet, len(workingLinks))
+	dudaScraper.forEach(len(workingLinks), func(i int) {
+		outlet, err := dudaScraper.Discover(workingLinks[i])
 		if err != nil {
-			log.Printf("error getting content for %v: %v", link.Url, err)
+			log.Printf("error discovering feeds of %v: %v", workingLinks[i].Url, err)
+		}
+		outlets[i] = outlet
+	})
+
+	sites := newSites(ctx, outlets, existing, aiClient)
+	log.Printf("found new feeds for %v of %v outlets", len(sites), len(outlets))
+	return writeSites(out, sites)
+}
+
+// writeSites writes sites as the indented JSON array the import reads.
+func writeSites(out io.Writer, sites []core.NewsSite) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sites)
+}
+
+// newSites makes a site of each outlet with feeds that are not existing sites',
+// described by aiClient if there is one.
+func newSites(ctx context.Context, outlets []Outlet, existing []core.NewsSite, aiClient core.AiClient) []core.NewsSite {
+	known := make(map[string]bool)
+	for _, site := range existing {
+		known[site.Name] = true
+		for _, url := range site.Urls {
+			known[url] = true
+		}
+	}
+	sites := make([]core.NewsSite, 0)
+	for _, outlet := range outlets {
+		if outlet.Title == "" || known[outlet.Title] {
+			continue
+		}
+		urls := make([]string, 0, len(outlet.Feeds))
+		for _, feed := range outlet.Feeds {
+			if known[feed.Url] {
+				continue
+			}
+			log.Printf("%v: %v has %v items, median interval %v, latest %v", outlet.Title, feed.Url, feed.Items, feed.Interval, feed.Latest.Format("2006-01-02"))
+			urls = append(urls, feed.Url)
+		}
+		if len(urls) == 0 {
+			continue
+		}
+		site := core.NewsSite{
+			Name:     outlet.Title,
+			Urls:     urls,
+			Language: cmp.Or(outlet.Language, fallbackLanguage),
+			Disabled: true,
+			Draft:    true,
+		}
+		if aiClient != nil {
+			description, err := aiClient.GenerateSiteDescription(ctx, site, outlet.PageText, outlet.Headlines, descriptionExamples(existing, site.Language))
+			if err != nil {
+				log.Printf("error describing %v: %v", site.Name, err)
+			}
+			site.Description = description
 		}
-		if strings.Contains(content, "rss") {
-			log.Printf("(%v) %v: %v", i, link.Url, len(content))
+		known[site.Name] = true
+		for _, url := range urls {
+			known[url] = true
 		}
+		sites = append(sites, site)
 	}
+	return sites
+}
+
+// maxDescriptionExamples is how many existing descriptions a drafted one is
+// shown as examples of the style.
+const maxDescriptionExamples = 3
+
+// descriptionExamples picks the existing sites whose descriptions a new site's
+// is drafted after: sites in its language first, and among those the ones with
+// the longest descriptions, which tell the most about the style.
+func descriptionExamples(existing []core.NewsSite, language string) []core.NewsSite {
+	examples := slices.DeleteFunc(slices.Clone(existing), func(site core.NewsSite) bool { return site.Description == "" })
+	slices.SortStableFunc(examples, func(a, b core.NewsSite) int {
+		if (a.Language == language) != (b.Language == language) {
+			if a.Language == language {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(len(b.Description), len(a.Description))
+	})
+	return examples[:min(len(examples), maxDescriptionExamples)]
 }
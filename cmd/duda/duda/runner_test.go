@@ -0,0 +1,107 @@
+package duda
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bjarke-xyz/rasende2/internal/ai"
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/news"
+	"github.com/bjarke-xyz/rasende2/internal/repository"
+	"github.com/bjarke-xyz/rasende2/internal/repository/db"
+)
+
+var testExisting = []core.NewsSite{
+	{Id: 1, Name: "Arbejderen", Urls: []string{"https://arbejderen.dk/feed/"}, Language: "da", Description: "A Danish left-wing daily."},
+}
+
+var testOutlets = []Outlet{
+	{Link: Link{Title: "Arbejderen"}, Feeds: []Feed{{Url: "https://arbejderen.dk/rss"}}},
+	{Link: Link{Title: "Ny Avis"}, Language: "da", Feeds: []Feed{{Url: "https://arbejderen.dk/feed/"}, {Url: "https://nyavis.dk/rss"}, {Url: "https://nyavis.dk/sport/rss"}}},
+	{Link: Link{Title: "Uden Sprog"}, Feeds: []Feed{{Url: "https://udensprog.dk/feed"}}},
+	{Link: Link{Title: "Intet Feed"}},
+	{Feeds: []Feed{{Url: "https://ukendt.dk/rss"}}},
+}
+
+// importList imports list as the admin sites page and the sites command do,
+// into a new database, which has the seeded sites.
+func importList(t *testing.T, list []byte) core.OpmlImport {
+	t.Helper()
+	cfg := &config.Config{DbConnStr: filepath.Join(t.TempDir(), "test.db")}
+	conn, err := db.Open(cfg)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.Migrate("up", conn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	appContext := &core.AppContext{Config: cfg}
+	repo := repository.NewSqliteNews(appContext)
+	service := news.NewRssService(appContext, repo, news.NewRssSearch(appContext, repo))
+	result, err := service.ImportSitesJson(context.Background(), bytes.NewReader(list))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	return result
+}
+
+// The outlets with new feeds are written as JSON entries the import creates
+// a draft site of each of, with only the feeds that are new.
+func TestNewSitesImport(t *testing.T) {
+	sites := newSites(context.Background(), testOutlets, testExisting, nil)
+	var list bytes.Buffer
+	if err := writeSites(&list, sites); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	result := importList(t, list.Bytes())
+	if len(result.Created) != 2 || len(result.Skipped) != 0 {
+		t.Fatalf("import = %+v, want Ny Avis and Uden Sprog created", result)
+	}
+	nyAvis, udenSprog := result.Created[0], result.Created[1]
+	if nyAvis.Name != "Ny Avis" || !slices.Equal(nyAvis.Urls, []string{"https://nyavis.dk/rss", "https://nyavis.dk/sport/rss"}) ||
+		nyAvis.Language != "da" || !nyAvis.Draft || !nyAvis.Disabled || nyAvis.Id <= testExisting[0].Id {
+		t.Errorf("created %+v, want a newly numbered draft of Ny Avis with its own two feeds", nyAvis)
+	}
+	if udenSprog.Name != "Uden Sprog" || udenSprog.Language != fallbackLanguage {
+		t.Errorf("created %+v, want Uden Sprog in %v", udenSprog, fallbackLanguage)
+	}
+	if nyAvis.Description != "" {
+		t.Errorf("description without a client = %q, want none", nyAvis.Description)
+	}
+}
+
+// With the fake LLM, each new site is described by its placeholder, which
+// ends up in the JSON and in the drafts imported from it.
+func TestNewSitesDescribedByFakeLlm(t *testing.T) {
+	aiClient := ai.NewLLMClient(&core.AppContext{Config: &config.Config{UseFakeLLM: true}})
+	outlets := []Outlet{{Link: Link{Title: "Ny Avis"}, Language: "da", Feeds: []Feed{{Url: "https://nyavis.dk/rss"}},
+		PageText: "Ny Avis - nyheder", Headlines: []string{"Første overskrift", "Anden overskrift"}}}
+	sites := newSites(context.Background(), outlets, testExisting, aiClient)
+	if len(sites) != 1 {
+		t.Fatalf("sites = %+v, want Ny Avis", sites)
+	}
+	want, err := aiClient.GenerateSiteDescription(context.Background(), sites[0], outlets[0].PageText, outlets[0].Headlines, testExisting)
+	if err != nil {
+		t.Fatalf("describe: %v", err)
+	}
+	if !strings.Contains(want, "placeholder") {
+		t.Fatalf("fake description = %q, want a placeholder", want)
+	}
+
+	var list bytes.Buffer
+	if err := writeSites(&list, sites); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if !bytes.Contains(list.Bytes(), []byte(`"description": "`+want+`"`)) {
+		t.Errorf("entries = %s, want the description %q", list.Bytes(), want)
+	}
+	result := importList(t, list.Bytes())
+	if len(result.Created) != 1 || result.Created[0].Description != want {
+		t.Errorf("import = %+v, want Ny Avis described as %q", result, want)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -25,14 +27,44 @@ type disabledSite struct {
 	Reason string
 }
 
+// Scraper fetches pages through the cache, with up to workers requests in
+// flight at once.
 type Scraper struct {
-	cache *Cache
+	cache   *Cache
+	client  *http.Client
+	workers int
 }
 
-func NewScraper(cache *Cache) *Scraper {
+func NewScraper(cache *Cache, workers int) *Scraper {
+	if workers <= 0 {
+		workers = 1
+	}
 	return &Scraper{
-		cache: cache,
+		cache:   cache,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		workers: workers,
+	}
+}
+
+// forEach calls fn for each index below n, on the scraper's workers, and
+// returns when all calls have.
+func (s *Scraper) forEach(n int, fn func(i int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(s.workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		indexes <- i
 	}
+	close(indexes)
+	wg.Wait()
 }
 
 func (s *Scraper) getDisabledSites() (map[string]disabledSite, error) {
@@ -65,18 +97,17 @@ func (s *Scraper) GetContent(link Link) (string, error) {
 	}
 	req, err := http.NewRequest("GET", link.Url, nil)
 	if err != nil {
-		log.Printf("error creating request: %v", err)
+		return "", fmt.Errorf("error creating request: %w", err)
 	}
 	req.Header.Set("User-Agent", chromeUserAgent)
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error getting %v: %w", link.Url, err)
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		return "", fmt.Errorf("%v returned non-200: %v", link.Url, resp.StatusCode)
 	}
-	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("error reading body: %w", err)
@@ -86,6 +117,9 @@ func (s *Scraper) GetContent(link Link) (string, error) {
 	return bodyStr, nil
 }
 
+// DownloadContents fetches the links concurrently, and returns those that
+// could be fetched, in their original order. A link that fails is remembered as
+// disabled and not tried again on later runs.
 func (s *Scraper) DownloadContents(links []Link) ([]Link, error) {
 
 	disabledSites, err := s.getDisabledSites()
@@ -93,18 +127,31 @@ func (s *Scraper) DownloadContents(links []Link) ([]Link, error) {
 		return nil, err
 	}
 
-	workingLinks := make([]Link, 0)
-	for _, link := range links {
-		if _, ok := disabledSites[link.Url]; ok {
-			continue
+	var mu sync.Mutex
+	working := make([]bool, len(links))
+	s.forEach(len(links), func(i int) {
+		link := links[i]
+		mu.Lock()
+		_, disabled := disabledSites[link.Url]
+		mu.Unlock()
+		if disabled {
+			return
 		}
 		_, err := s.GetContent(link)
+		mu.Lock()
+		defer mu.Unlock()
 		if err != nil {
 			log.Printf("error getting %v: %v\n", link.Url, err)
 			disabledSites[link.Url] = disabledSite{Reason: fmt.Sprintf("error getting: %v", err)}
-			continue
+			return
+		}
+		working[i] = true
+	})
+	workingLinks := make([]Link, 0)
+	for i, link := range links {
+		if working[i] {
+			workingLinks = append(workingLinks, link)
 		}
-		workingLinks = append(workingLinks, link)
 	}
 	err = s.saveDisabledSites(disabledSites)
 	if err != nil {
@@ -121,14 +168,13 @@ func (s *Scraper) GetMediaUrls() ([]Link, error) {
 	cachedHtml, ok := s.cache.Get(cacheKey)
 	if !ok {
 		log.Println("duda not found in cache, getting")
-		client := &http.Client{}
 		req, err := http.NewRequest("GET", duda, nil)
 		if err != nil {
 			return nil, fmt.Errorf("could not create request: %w", err)
 		}
 		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8")
 		req.Header.Set("User-Agent", chromeUserAgent)
-		res, err := client.Do(req)
+		res, err := s.client.Do(req)
 		if err != nil {
 			return nil, fmt.Errorf("could not do request: %w", err)
 		}
@@ -1,10 +1,60 @@
+// Command duda discovers the feeds of the Danish media listed on duda.dk, and
+// writes them as draft NewsSite JSON entries to stdout. Outlets and feeds that
+// are already sites in the database are left out. The entries have no ids; the
+// sites command, or the import of the admin sites page, creates them as drafts
+// numbered on from the existing sites. With -describe, the configured LLM
+// drafts each site's description from its homepage and headlines; run with
+// USE_FAKE_LLM=true, the drafts are placeholders.
+//
+//	duda -cache ./cache -ttl 24h -workers 8 -describe > duda.json
+//	sites import duda.json
 package main
 
 import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
 	"github.com/bjarke-xyz/rasende2/cmd/duda/duda"
+	"github.com/bjarke-xyz/rasende2/internal/app"
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/repository/db"
 )
 
 func main() {
-	cache := duda.NewCache("./cache")
-	duda.PrintPotentialRssFeedSites(cache)
+	cacheDir := flag.String("cache", "./cache", "directory fetched pages are cached in")
+	ttl := flag.Duration("ttl", 24*time.Hour, "how long a cached page is used, 0 for ever")
+	workers := flag.Int("workers", 8, "how many pages are fetched at once")
+	describe := flag.Bool("describe", false, "draft a description of each site with the LLM")
+	flag.Parse()
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	dbConn, err := db.Open(cfg)
+	if err != nil {
+		log.Fatalf("opening db failed: %v", err)
+	}
+	if err := db.Migrate("up", dbConn); err != nil {
+		log.Fatalf("migration failed: %v", err)
+	}
+	ctx := context.Background()
+	deps := app.AppContext(cfg).Deps
+	existing, err := deps.Service.GetSites(ctx)
+	if err != nil {
+		log.Fatalf("getting sites failed: %v", err)
+	}
+	var aiClient core.AiClient
+	if *describe {
+		aiClient = deps.AiClient
+	}
+
+	cache := duda.NewCache(*cacheDir, *ttl)
+	if err := duda.GenerateSites(ctx, cache, existing, *workers, aiClient, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
 }
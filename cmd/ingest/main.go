@@ -0,0 +1,75 @@
+// Command ingest runs the fetch job once, the same as the scheduler does, and
+// exits. With -record it also saves every response it gets; with -replay it asks
+// no site at all and serves the saved responses instead, so an ingest — and a
+// parser bug — can be reproduced offline, exactly as it happened.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bjarke-xyz/rasende2/internal/app"
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/logging"
+	"github.com/bjarke-xyz/rasende2/internal/repository/db"
+)
+
+func main() {
+	logging.Setup()
+	record := flag.String("record", "", "save every response under this directory")
+	replay := flag.String("replay", "", "serve the responses saved under this directory instead of fetching")
+	flag.Parse()
+	if err := run(*record, *replay); err != nil {
+		slog.Error("ingest failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(record, replay string) error {
+	if record != "" && replay != "" {
+		return fmt.Errorf("-record and -replay cannot be combined")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if record != "" {
+		cfg.FeedRecordDir = record
+	}
+	if replay != "" {
+		cfg.FeedReplayDir = replay
+	}
+	dbConn, err := db.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("opening db failed: %w", err)
+	}
+	if err := db.Migrate("up", dbConn); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	service := app.AppContext(cfg).Deps.Service
+	if err := service.FetchAndSaveNewItems(ctx); err != nil {
+		return err
+	}
+	runs, err := service.GetJobRuns(ctx, 10)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if run.Kind == core.JobFetchItems {
+			slog.Info("ingest finished", "status", run.Status,
+				"inserted", run.Counters["inserted"], "revised", run.Counters["revised"], "failedSites", run.Counters["failedSites"])
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Command sites moves the site list in and out of rasende2 as OPML, the format
+// feed readers import and export.
+//
+//	sites export > sites.opml
+//	sites import feeds.opml
+//	sites import duda.json
+//
+// An import creates every new feed as a disabled draft site, to be reviewed and
+// enabled on the admin site page. A .json file is a list of sites, as the duda
+// command writes them.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bjarke-xyz/rasende2/internal/app"
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/logging"
+	"github.com/bjarke-xyz/rasende2/internal/repository/db"
+)
+
+const usage = "usage: sites export | sites import <file.opml|file.json>"
+
+func main() {
+	logging.Setup()
+	if err := run(os.Args[1:]); err != nil {
+		slog.Error("sites failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	ctx := context.Background()
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	dbConn, err := db.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("opening db failed: %w", err)
+	}
+	if err := db.Migrate("up", dbConn); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	service := app.AppContext(cfg).Deps.Service
+
+	switch {
+	case args[0] == "export" && len(args) == 1:
+		out, err := service.ExportOpml(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(out)
+		return err
+	case args[0] == "import" && len(args) == 2:
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		importSites := service.ImportOpml
+		if strings.EqualFold(filepath.Ext(args[1]), ".json") {
+			importSites = service.ImportSitesJson
+		}
+		result, err := importSites(ctx, f)
+		for _, site := range result.Created {
+			slog.Info("created draft site", "id", site.Id, "name", site.Name, "language", site.Language)
+		}
+		for _, name := range result.Skipped {
+			slog.Info("skipped site, its name or a feed of it is already a site's", "name", name)
+		}
+		for _, site := range result.Unanalyzed {
+			slog.Warn("draft site has a language with no analyzer", "id", site.Id, "name", site.Name, "language", site.Language)
+		}
+		return err
+	default:
+		return errors.New(usage)
+	}
+}
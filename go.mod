@@ -4,6 +4,7 @@ go 1.26.5
 
 require (
 	github.com/PuerkitoBio/goquery v1.12.0
+	github.com/andybalholm/cascadia v1.3.4
 	github.com/pressly/goose/v3 v3.27.2
 	github.com/sashabaranov/go-openai v1.41.2
 	github.com/xeonx/timeago v1.0.0-rc5
@@ -57,7 +58,6 @@ require (
 )
 
 require (
-	github.com/andybalholm/cascadia v1.3.4 // indirect
 	github.com/aws/aws-sdk-go-v2 v1.42.1
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.105.0
 	github.com/aymerick/douceur v0.2.0 // indirect
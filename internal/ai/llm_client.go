@@ -45,9 +45,13 @@ func NewLLMClient(appContext *core.AppContext) core.AiClient {
 
 // siteLang is the edition a site belongs to, and so the language its fake news
 // must be written in. The repository rejects a site whose language has no
-// edition at startup, so this cannot miss at runtime.
+// edition, except for a draft, which is disabled until it is reviewed; if one is
+// picked by hand anyway, it is written for the default edition.
 func siteLang(site core.NewsSite) lang.Lang {
-	return lang.MustGet(lang.Code(site.Language))
+	if l, ok := lang.Get(site.Language); ok {
+		return l
+	}
+	return lang.MustGet(lang.Default)
 }
 
 func (o *llmClient) GenerateImage(ctx context.Context, site core.NewsSite, articleTitle string, translateTitle bool) (string, error) {
@@ -441,6 +445,47 @@ func (o *llmClient) GenerateArticleContent(ctx context.Context, site core.NewsSi
 	return wrapLlmChatCompletionStream(stream), err
 }
 
+// GenerateSiteDescription is a review aid for new sites, not part of any
+// request a reader makes. The description it drafts goes into every prompt
+// about the site, so like the hand-written ones it is in English, and it sticks
+// to what the site covers and its tone: that is what the satire imitates.
+func (o *llmClient) GenerateSiteDescription(ctx context.Context, site core.NewsSite, pageText string, headlines []string, examples []core.NewsSite) (string, error) {
+	if o.useFake {
+		return fmt.Sprintf("%v is a news media. This is a placeholder description, drafted without an LLM from its homepage and %v recent headlines.", site.Name, len(headlines)), nil
+	}
+	slog.Debug("generate site description", "site", site.Name, "headlines", len(headlines))
+	var exampleText strings.Builder
+	for _, example := range examples {
+		fmt.Fprintf(&exampleText, "'%v': '%v'\n", example.Name, example.Description)
+	}
+	sysPrompt := fmt.Sprintf("You write short descriptions of news media, for the editors of a satirical news site to read. You are given the text of the homepage of the news media '%v', and a list of its recent article titles. Describe the media in English, in 2-5 sentences: what kind of media it is, where it is from, what it covers, and its tone and style. Only state what the homepage and titles support, and do not make up founding years or figures. Return only the description, nothing else. Follow the style of these existing descriptions:\n%v", site.Name, exampleText.String())
+	req := openai.ChatCompletionRequest{
+		Model:       chatModel,
+		Temperature: 0.3,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: sysPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf("Homepage of '%v':\n%v\n\nRecent article titles:\n%v", site.Name, pageText, strings.Join(headlines, "\n")),
+			},
+		},
+		Stream: false,
+	}
+	slog.Debug("generate site description prompts", "prompts", fmt.Sprintf("%+v", req.Messages))
+	resp, err := o.client.CreateChatCompletion(ctx, req)
+	metrics.AiCounterSiteDescriptionInc()
+	if err != nil {
+		return "", fmt.Errorf("LLM API error: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("LLM returned no description")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
 type LlmChatCompletionStream struct {
 	stream *openai.ChatCompletionStream
 }
@@ -0,0 +1,29 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+)
+
+// With the fake LLM, a drafted description is a placeholder that depends only
+// on its input, so the discovery step that asks for it can be tested.
+func TestFakeSiteDescriptionIsDeterministic(t *testing.T) {
+	client := NewLLMClient(&core.AppContext{Config: &config.Config{UseFakeLLM: true}})
+	site := core.NewsSite{Name: "Ny Avis", Language: "da"}
+	headlines := []string{"Første overskrift", "Anden overskrift"}
+	first, err := client.GenerateSiteDescription(context.Background(), site, "Ny Avis - nyheder", headlines, nil)
+	if err != nil {
+		t.Fatalf("describe: %v", err)
+	}
+	second, _ := client.GenerateSiteDescription(context.Background(), site, "Ny Avis - nyheder", headlines, nil)
+	if first != second {
+		t.Errorf("descriptions differ: %q and %q", first, second)
+	}
+	if !strings.HasPrefix(first, "Ny Avis ") {
+		t.Errorf("description = %q, want it to name the site", first)
+	}
+}
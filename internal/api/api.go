@@ -3,15 +3,13 @@ package api
 import (
 	"context"
 	"crypto/subtle"
+	"errors"
 	"log/slog"
-	"math/rand/v2"
 	"net/http"
-	"strings"
+	"strconv"
 
 	"github.com/bjarke-xyz/rasende2/internal/core"
 	"github.com/bjarke-xyz/rasende2/internal/httpx"
-	"github.com/bjarke-xyz/rasende2/internal/lang"
-	"github.com/bjarke-xyz/rasende2/pkg"
 )
 
 type api struct {
@@ -25,13 +23,16 @@ func NewAPI(appContext *core.AppContext) *api {
 }
 
 func (a *api) Route(mux *http.ServeMux) {
-	handle := func(path string, fn http.HandlerFunc) {
-		mux.Handle("POST /api"+path, a.requireJobKey(fn))
-	}
-	handle("/job", a.RunJob)
-	handle("/admin/rebuild-index", a.RebuildIndex)
-	handle("/admin/auto-generate-fake-news", a.AutoGenerateFakeNews)
-	handle("/admin/clean-fake-news", a.CleanUpFakeNews)
+	handle := func(method, path string, fn http.HandlerFunc) {
+		mux.Handle(method+" /api"+path, a.requireJobKey(fn))
+	}
+	handle(http.MethodPost, "/job", a.RunJob)
+	handle(http.MethodPost, "/admin/rebuild-index", a.RebuildIndex)
+	handle(http.MethodPost, "/admin/auto-generate-fake-news", a.AutoGenerateFakeNews)
+	handle(http.MethodPost, "/admin/clean-fake-news", a.CleanUpFakeNews)
+	handle(http.MethodPost, "/admin/archive-items", a.ArchiveItems)
+	handle(http.MethodGet, "/admin/jobs", a.GetJobRuns)
+	handle(http.MethodGet, "/admin/jobs/{id}", a.GetJobRun)
 }
 
 // requireJobKey guards the endpoints the cron calls. They are the only way into
@@ -57,6 +58,18 @@ func (a *api) requireJobKey(next http.Handler) http.Handler {
 	})
 }
 
+// jobBusy answers 409 and reports true if err is from a run of a job that was
+// already running, started by the scheduler or by an earlier request. Such a
+// run is not started; a fire and forget one just shows up as skipped in
+// /api/admin/jobs.
+func jobBusy(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, core.ErrJobBusy) {
+		return false
+	}
+	httpx.String(w, http.StatusConflict, "%v", err)
+	return true
+}
+
 func (a *api) RunJob(w http.ResponseWriter, r *http.Request) {
 	fireAndForget := r.URL.Query().Get("fireAndForget") == "true"
 	//using context.Background to not cancel, if this method times out
@@ -64,8 +77,8 @@ func (a *api) RunJob(w http.ResponseWriter, r *http.Request) {
 
 	if fireAndForget {
 		go a.appContext.Deps.Service.FetchAndSaveNewItems(ctx)
-	} else {
-		a.appContext.Deps.Service.FetchAndSaveNewItems(ctx)
+	} else if jobBusy(w, a.appContext.Deps.Service.FetchAndSaveNewItems(ctx)) {
+		return
 	}
 	w.WriteHeader(http.StatusOK)
 }
@@ -77,6 +90,9 @@ func (a *api) CleanUpFakeNews(w http.ResponseWriter, r *http.Request) {
 	} else {
 		ctx := r.Context()
 		err := a.appContext.Deps.Service.CleanUpFakeNews(ctx)
+		if jobBusy(w, err) {
+			return
+		}
 		if err != nil {
 			httpx.String(w, http.StatusInternalServerError, "fake news clean up failed: %v", err)
 			return
@@ -86,137 +102,99 @@ func (a *api) CleanUpFakeNews(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// RebuildIndex discards rss_items_fts and reindexes every item. Ordinary indexing
-// happens transactionally on insert, so this is only needed after an analyzer change.
-func (a *api) RebuildIndex(w http.ResponseWriter, r *http.Request) {
-	go a.appContext.Deps.Service.RebuildSearchIndexAndLogError(context.Background())
-	w.WriteHeader(http.StatusOK)
-}
-
-var noAutoGenerateSites map[int]any = map[int]any{8: struct{}{} /* DR */, 19: struct{}{} /* TV2 */}
-
-func (a *api) AutoGenerateFakeNews(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
-	// The cron generates for every edition, not just one, so it samples across
-	// all of them. Each site carries its own language, and that is what decides
-	// the language the article comes back in.
-	allSites := make([]core.NewsSite, 0)
-	for _, l := range lang.All {
-		sites, err := a.appContext.Deps.Service.GetSiteInfos(ctx, l)
-		if err != nil {
-			slog.Error("getting site infos failed", "error", err)
-			httpx.JSON(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-		allSites = append(allSites, sites...)
-	}
-	latestFakeNews, err := a.appContext.Deps.Service.GetRecentFakeNews(ctx, 3, nil)
-	if err != nil {
-		slog.Error("getting recent fake news failed", "error", err)
-		httpx.JSON(w, http.StatusInternalServerError, err.Error())
+// ArchiveItems drops the content of the items past their retention period,
+// after exporting it. With ?fireAndForget=true it answers at once and the
+// outcome is in /api/admin/jobs.
+func (a *api) ArchiveItems(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("fireAndForget") == "true" {
+		go func() {
+			if err := a.appContext.Deps.Service.ArchiveItems(context.Background()); err != nil {
+				slog.Error("archiving items failed", "error", err)
+			}
+		}()
+		w.WriteHeader(http.StatusOK)
 		return
 	}
-	latestFakeNewsSites := make(map[int]any, len(latestFakeNews))
-	for _, fn := range latestFakeNews {
-		latestFakeNewsSites[fn.SiteId] = struct{}{}
-	}
-	sites := make([]core.NewsSite, 0)
-	for _, site := range allSites {
-		if site.Disabled {
-			continue
-		}
-		_, isInLatest := latestFakeNewsSites[site.Id]
-		if isInLatest {
-			continue
-		}
-		_, isNoAutoGenerateSite := noAutoGenerateSites[site.Id]
-		if isNoAutoGenerateSite {
-			continue
+	if err := a.appContext.Deps.Service.ArchiveItems(r.Context()); err != nil {
+		if jobBusy(w, err) {
+			return
 		}
-		sites = append(sites, site)
-	}
-	if len(sites) == 0 {
-		httpx.JSON(w, http.StatusInternalServerError, "sites list was empty")
+		httpx.String(w, http.StatusInternalServerError, "archiving items failed: %v", err)
 		return
 	}
-	site := sites[rand.IntN(len(sites))]
-	recentArticleTitles, err := a.appContext.Deps.Service.GetRecentTitles(ctx, site, 10, true)
-	if err != nil {
-		slog.Error("getting recent article titles failed", "error", err)
-		httpx.JSON(w, http.StatusInternalServerError, err.Error())
+	w.WriteHeader(http.StatusOK)
+}
+
+// RebuildIndex discards rss_items_fts and reindexes every item. Ordinary indexing
+// happens transactionally on insert, so this is only needed after an analyzer change.
+//
+// The rebuild runs in the background. The response is the run it started, which
+// /api/admin/jobs/{id} reports on until it finishes.
+func (a *api) RebuildIndex(w http.ResponseWriter, r *http.Request) {
+	run, err := a.appContext.Deps.Service.StartRebuildSearchIndex(r.Context())
+	if jobBusy(w, err) {
 		return
 	}
-	var temperature float32 = 1
-	var generatedTitleCount = 30
-	generatedArticleTitles, err := a.appContext.Deps.AiClient.GenerateArticleTitlesList(ctx, site, recentArticleTitles, generatedTitleCount, temperature)
 	if err != nil {
-		slog.Error("getting generated article titles failed", "error", err)
+		slog.Error("starting search index rebuild failed", "error", err)
 		httpx.JSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	slog.Debug("generated titles", "titles", strings.Join(generatedArticleTitles, ", "))
-	selectedTitle, err := a.appContext.Deps.AiClient.SelectBestArticleTitle(ctx, site, generatedArticleTitles)
-	if err != nil {
-		slog.Error("selecting best article title failed", "error", err)
-		httpx.JSON(w, http.StatusInternalServerError, err.Error())
-		return
+	httpx.JSON(w, http.StatusAccepted, run)
+}
+
+const defaultJobRunsLimit = 50
+
+// GetJobRuns lists the most recent job runs, newest first. ?limit= caps how many.
+func (a *api) GetJobRuns(w http.ResponseWriter, r *http.Request) {
+	limit := defaultJobRunsLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			httpx.JSON(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = parsed
 	}
-	slog.Debug("selected title", "title", selectedTitle)
-	externalId := pkg.NewID()
-	err = a.appContext.Deps.Service.CreateFakeNews(ctx, site.Id, selectedTitle, externalId)
+	runs, err := a.appContext.Deps.Service.GetJobRuns(r.Context(), limit)
 	if err != nil {
-		slog.Error("creating fake news failed", "error", err)
+		slog.Error("getting job runs failed", "error", err)
 		httpx.JSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	httpx.JSON(w, http.StatusOK, runs)
+}
 
-	articleImgPromise := pkg.NewPromise(func() (string, error) {
-		imgUrl, err := a.appContext.Deps.AiClient.GenerateImage(ctx, site, selectedTitle, true)
-		if err != nil {
-			slog.Error("making fake news img failed", "error", err)
-		}
-		if imgUrl != "" {
-			a.appContext.Deps.Service.SetFakeNewsImgUrl(ctx, site.Id, selectedTitle, imgUrl)
-		}
-		return imgUrl, err
-	})
-
-	articleContent, err := a.appContext.Deps.AiClient.GenerateArticleContentStr(ctx, site, selectedTitle, temperature)
+func (a *api) GetJobRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		slog.Error("generating article content failed", "error", err)
-		httpx.JSON(w, http.StatusInternalServerError, err.Error())
+		httpx.JSON(w, http.StatusBadRequest, "id must be a number")
 		return
 	}
-
-	err = a.appContext.Deps.Service.UpdateFakeNews(ctx, site.Id, selectedTitle, articleContent)
+	run, err := a.appContext.Deps.Service.GetJobRun(r.Context(), id)
 	if err != nil {
-		slog.Error("updating fake news failed", "error", err)
+		slog.Error("getting job run failed", "id", id, "error", err)
 		httpx.JSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-
-	slog.Debug("waiting for img")
-	articleImgPromise.Get()
-	slog.Debug("img done")
-
-	err = a.appContext.Deps.Service.SetFakeNewsHighlighted(ctx, site.Id, selectedTitle, true)
-	if err != nil {
-		slog.Error("setting highlighted failed", "error", err)
-		httpx.JSON(w, http.StatusInternalServerError, err.Error())
+	if run == nil {
+		httpx.JSON(w, http.StatusNotFound, "no such job run")
 		return
 	}
+	httpx.JSON(w, http.StatusOK, *run)
+}
 
-	createdFakeNews, err := a.appContext.Deps.Service.GetFakeNews(ctx, externalId)
-	if err != nil {
-		slog.Error("getting fake news failed", "error", err)
-		httpx.JSON(w, http.StatusInternalServerError, err.Error())
+// AutoGenerateFakeNews generates one article on demand. The scheduler runs the
+// same thing on its own; this is the manual trigger.
+func (a *api) AutoGenerateFakeNews(w http.ResponseWriter, r *http.Request) {
+	createdFakeNews, err := a.appContext.Deps.Service.AutoGenerateFakeNews(context.Background())
+	if jobBusy(w, err) {
 		return
 	}
-	if createdFakeNews == nil {
-		slog.Warn("fake news was nil")
-		httpx.JSON(w, http.StatusInternalServerError, "fake new was nil")
+	if err != nil {
+		slog.Error("auto generating fake news failed", "error", err)
+		httpx.JSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-
 	httpx.JSON(w, http.StatusOK, *createdFakeNews)
 }
@@ -2,12 +2,15 @@ package app
 
 import (
 	"context"
+	"log/slog"
+	"time"
 
 	"github.com/bjarke-xyz/rasende2/internal/ai"
 	"github.com/bjarke-xyz/rasende2/internal/config"
 	"github.com/bjarke-xyz/rasende2/internal/core"
 	"github.com/bjarke-xyz/rasende2/internal/news"
 	"github.com/bjarke-xyz/rasende2/internal/repository"
+	"github.com/bjarke-xyz/rasende2/internal/scheduler"
 )
 
 func AppContext(cfg *config.Config) *core.AppContext {
@@ -25,10 +28,51 @@ func AppContext(cfg *config.Config) *core.AppContext {
 	return appContext
 }
 
+// jobScheduler is started by Initialise and waited for by Dispose. There is one
+// app per process, so it lives here rather than on AppDeps, where every test that
+// builds an AppContext would have to know about it.
+var jobScheduler *scheduler.Scheduler
+
+// Initialise starts the background work. ctx is the process's shutdown context:
+// cancelling it stops the scheduler.
 func Initialise(ctx context.Context, appContext *core.AppContext) {
 	appContext.Deps.Service.Initialise(ctx)
+
+	jobScheduler = newScheduler(appContext)
+	jobScheduler.Start(ctx)
 }
 
+// Dispose waits for a job that was running at shutdown to wind down, so the
+// process does not exit halfway through a write.
 func Dispose(appContext *core.AppContext) {
+	if jobScheduler != nil {
+		jobScheduler.Wait()
+	}
 	appContext.Deps.Service.Dispose()
 }
+
+func newScheduler(appContext *core.AppContext) *scheduler.Scheduler {
+	cfg := appContext.Config
+	service := appContext.Deps.Service
+	s := scheduler.New()
+	add := func(name, spec string, run scheduler.JobFunc) {
+		if spec == "" {
+			return
+		}
+		schedule, err := scheduler.Parse(spec, time.Local)
+		if err != nil {
+			slog.Error("invalid job schedule; the job will only run when triggered", "job", name, "error", err)
+			return
+		}
+		slog.Info("scheduling job", "job", name, "schedule", spec)
+		s.Add(name, schedule, run)
+	}
+	add("fetch-items", cfg.ScheduleFetchItems, service.FetchAndSaveNewItems)
+	add("auto-generate-fake-news", cfg.ScheduleAutoGenerateFakeNews, func(ctx context.Context) error {
+		_, err := service.AutoGenerateFakeNews(ctx)
+		return err
+	})
+	add("clean-fake-news", cfg.ScheduleCleanFakeNews, service.CleanUpFakeNews)
+	add("archive-items", cfg.ScheduleArchiveItems, service.ArchiveItems)
+	return s
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bjarke-xyz/rasende2/pkg"
@@ -50,6 +52,41 @@ type Config struct {
 	OIDCIssuer       string
 	OIDCClientID     string
 	OIDCClientSecret string
+
+	// The schedules of the background jobs, each either a Go duration ("15m") or
+	// a five-field cron expression ("0 3 * * *"). An empty one leaves that job
+	// to be triggered through its /api endpoint only.
+	ScheduleFetchItems           string
+	ScheduleAutoGenerateFakeNews string
+	ScheduleCleanFakeNews        string
+	ScheduleArchiveItems         string
+
+	// FetchWorkers is how many sites the fetch job fetches at once, and
+	// FetchPerHost how many requests it has in flight to any one host. Zero
+	// means the default.
+	FetchWorkers int
+	FetchPerHost int
+
+	// FeedRecordDir, if set, has the fetch job save every response it gets
+	// there. FeedReplayDir, if set, has it serve the saved responses instead of
+	// asking the sites, so an ingest can run offline.
+	FeedRecordDir string
+	FeedReplayDir string
+
+	// ContentRetentionMonths is how many months items keep their content before
+	// the archive job drops it, for the sites that set no period of their own.
+	// Zero keeps it forever.
+	ContentRetentionMonths int
+	// ArchiveDir, if set, is where the archive job exports the items it is
+	// about to strip, as gzipped JSON Lines. Without it they go to the image
+	// bucket, if there is one, and are not exported at all otherwise.
+	ArchiveDir string
+
+	// TrackedTerms are the queries, besides each edition's default one, whose
+	// matches are rolled up per site and day, so that charting them does not
+	// run them through the search index. A query with a filter, such as
+	// site:, cannot be rolled up and is charted the slow way.
+	TrackedTerms []string
 }
 
 // OIDCRedirectURI is the callback the auth server redirects back to after login.
@@ -113,5 +150,48 @@ func NewConfig() (*Config, error) {
 		OIDCIssuer:             os.Getenv("OIDC_ISSUER"),
 		OIDCClientID:           os.Getenv("OIDC_CLIENT_ID"),
 		OIDCClientSecret:       os.Getenv("OIDC_CLIENT_SECRET"),
+
+		ScheduleFetchItems:           os.Getenv("SCHEDULE_FETCH_ITEMS"),
+		ScheduleAutoGenerateFakeNews: os.Getenv("SCHEDULE_AUTO_GENERATE_FAKE_NEWS"),
+		ScheduleCleanFakeNews:        os.Getenv("SCHEDULE_CLEAN_FAKE_NEWS"),
+		ScheduleArchiveItems:         os.Getenv("SCHEDULE_ARCHIVE_ITEMS"),
+
+		FetchWorkers: optionalIntEnv("FETCH_WORKERS"),
+		FetchPerHost: optionalIntEnv("FETCH_PER_HOST"),
+
+		FeedRecordDir: os.Getenv("FEED_RECORD_DIR"),
+		FeedReplayDir: os.Getenv("FEED_REPLAY_DIR"),
+
+		ContentRetentionMonths: optionalIntEnv("CONTENT_RETENTION_MONTHS"),
+		ArchiveDir:             os.Getenv("ARCHIVE_DIR"),
+
+		TrackedTerms: listEnv("TRACKED_TERMS"),
 	}, nil
 }
+
+// optionalIntEnv reads a number that may be left unset. An unset or invalid one
+// is 0, which leaves it to the default; an invalid one is logged.
+func optionalIntEnv(name string) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("parsing env failed, using the default", "name", name, "error", err)
+		return 0
+	}
+	return i
+}
+
+// listEnv reads a comma separated list, leaving out empty entries. An unset
+// variable is an empty list.
+func listEnv(name string) []string {
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -21,6 +21,10 @@ type AiClient interface {
 	SelectBestArticleTitle(ctx context.Context, site NewsSite, articleTitles []string) (string, error)
 	GenerateArticleContentStr(ctx context.Context, site NewsSite, articleTitle string, temperature float32) (string, error)
 	GenerateArticleContent(ctx context.Context, site NewsSite, articleTitle string, temperature float32) (ChatCompletionStream, error)
+	// GenerateSiteDescription drafts a Description for a site that has none,
+	// from the text of its homepage and its recent headlines, in the style of
+	// the examples.
+	GenerateSiteDescription(ctx context.Context, site NewsSite, pageText string, headlines []string, examples []NewsSite) (string, error)
 }
 
 type ChatCompletionStream interface {
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// ChartRange names the span of time a line chart covers, counted back from
+// today, or all of it, or the span a visitor picked.
+type ChartRange string
+
+const (
+	Range7Days   ChartRange = "7d"
+	Range30Days  ChartRange = "30d"
+	Range90Days  ChartRange = "90d"
+	Range1Year   ChartRange = "1y"
+	RangeAll     ChartRange = "all"
+	RangeCustom  ChartRange = "custom"
+	DefaultRange            = Range7Days
+)
+
+var ChartRanges = []ChartRange{Range7Days, Range30Days, Range90Days, Range1Year, RangeAll, RangeCustom}
+
+// ChartBucket is what a line chart counts per point.
+type ChartBucket string
+
+const (
+	BucketHour    ChartBucket = "hour"
+	BucketDay     ChartBucket = "day"
+	BucketWeek    ChartBucket = "week"
+	BucketMonth   ChartBucket = "month"
+	DefaultBucket             = BucketDay
+)
+
+var ChartBuckets = []ChartBucket{BucketHour, BucketDay, BucketWeek, BucketMonth}
+
+// MaxChartBuckets is the most points a line chart gets. A bucket too fine for
+// the range, hours over a year say, is coarsened until it fits.
+const MaxChartBuckets = 750
+
+// ErrInvalidChartPeriod is a custom range without two valid dates in order.
+var ErrInvalidChartPeriod = errors.New("invalid chart period")
+
+// ChartPeriod is the span and resolution of a line chart. Buckets are days,
+// weeks and months on the calendar of Location, the edition's time zone, so a
+// day is 23 or 25 hours long when the clocks change. A week starts on Monday.
+type ChartPeriod struct {
+	Range ChartRange
+	// Start is the first instant counted, or zero to count from the oldest
+	// match. End is the first instant not counted.
+	Start    time.Time
+	End      time.Time
+	Bucket   ChartBucket
+	Location *time.Location
+}
+
+// NewChartPeriod reads a period from the values of the search form. An unknown
+// range or bucket falls back to the default; a custom range takes start and
+// end as dates in loc, end included. The preset ranges end with today, now's
+// day in loc. A nil loc is UTC.
+func NewChartPeriod(chartRange string, bucket string, start string, end string, now time.Time, loc *time.Location) (ChartPeriod, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	period := ChartPeriod{Range: ChartRange(chartRange), Bucket: ChartBucket(bucket), Location: loc}
+	if !slices.Contains(ChartBuckets, period.Bucket) {
+		period.Bucket = DefaultBucket
+	}
+	if !slices.Contains(ChartRanges, period.Range) {
+		period.Range = DefaultRange
+	}
+	tomorrow := BucketDay.Truncate(now.In(loc)).AddDate(0, 0, 1)
+	period.End = tomorrow
+	switch period.Range {
+	case Range7Days:
+		period.Start = tomorrow.AddDate(0, 0, -7)
+	case Range30Days:
+		period.Start = tomorrow.AddDate(0, 0, -30)
+	case Range90Days:
+		period.Start = tomorrow.AddDate(0, 0, -90)
+	case Range1Year:
+		period.Start = tomorrow.AddDate(-1, 0, 0)
+	case RangeCustom:
+		first, err := time.ParseInLocation(time.DateOnly, start, loc)
+		if err != nil {
+			return ChartPeriod{}, ErrInvalidChartPeriod
+		}
+		last, err := time.ParseInLocation(time.DateOnly, end, loc)
+		if err != nil || last.Before(first) {
+			return ChartPeriod{}, ErrInvalidChartPeriod
+		}
+		period.Start, period.End = first, last.AddDate(0, 0, 1)
+	}
+	return period, nil
+}
+
+// DefaultChartPeriod is the last 7 days by day, the period of the front page.
+func DefaultChartPeriod(now time.Time, loc *time.Location) ChartPeriod {
+	period, _ := NewChartPeriod(string(DefaultRange), string(DefaultBucket), "", "", now, loc)
+	return period
+}
+
+// Truncate returns the start of the period's bucket t falls in.
+func (p ChartPeriod) Truncate(t time.Time) time.Time {
+	return p.Bucket.Truncate(t.In(p.location()))
+}
+
+func (p ChartPeriod) location() *time.Location {
+	if p.Location == nil {
+		return time.UTC
+	}
+	return p.Location
+}
+
+// Fit returns the period with its bucket coarsened until the span from first,
+// or from Start when it is set, to End has at most MaxChartBuckets of them.
+func (p ChartPeriod) Fit(first time.Time) ChartPeriod {
+	if !p.Start.IsZero() {
+		first = p.Start
+	}
+	for p.Bucket != BucketMonth && len(p.buckets(first)) > MaxChartBuckets {
+		p.Bucket = ChartBuckets[slices.Index(ChartBuckets, p.Bucket)+1]
+	}
+	return p
+}
+
+// buckets returns the start of each bucket from the one holding first up to
+// End.
+func (p ChartPeriod) buckets(first time.Time) []time.Time {
+	starts := []time.Time{}
+	for t := p.Truncate(first); t.Before(p.End); t = p.Bucket.next(t) {
+		starts = append(starts, t)
+		if len(starts) > MaxChartBuckets && p.Bucket != BucketMonth {
+			// Fit only needs to know there are too many.
+			break
+		}
+	}
+	return starts
+}
+
+// Truncate returns the start of the bucket t falls in, on the clock of t's
+// location. An hour is cut back by its minutes on the wall clock rather than
+// on UTC's, which keeps zones half an hour off UTC on their own hours, and the
+// hour repeated when the clocks go back apart from the one before it.
+func (b ChartBucket) Truncate(t time.Time) time.Time {
+	loc := t.Location()
+	switch b {
+	case BucketHour:
+		return t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	case BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case BucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// next returns the start of the bucket after the one starting at t. Hours are
+// counted in elapsed time and the rest on the calendar of t's location.
+func (b ChartBucket) next(t time.Time) time.Time {
+	switch b {
+	case BucketHour:
+		return t.Add(time.Hour)
+	case BucketWeek:
+		return t.AddDate(0, 0, 7)
+	case BucketMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// label is how a bucket starting at t is named on the chart's axis. The year is
+// left out while the chart stays within one.
+func (b ChartBucket) label(t time.Time, withYear bool) string {
+	switch {
+	case b == BucketMonth:
+		return t.Format("2006-01")
+	case b == BucketHour && withYear:
+		return t.Format("2006-01-02 15:00")
+	case b == BucketHour:
+		return t.Format("01-02 15:00")
+	case withYear:
+		return t.Format(time.DateOnly)
+	default:
+		return t.Format("01-02")
+	}
+}
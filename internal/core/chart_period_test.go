@@ -0,0 +1,225 @@
+package core
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewChartPeriod(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 6, 15, 4, 0, 0, time.UTC)
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC) }
+
+	var tests = []struct {
+		name               string
+		chartRange, bucket string
+		start, end         string
+		wantRange          ChartRange
+		wantBucket         ChartBucket
+		wantStart, wantEnd time.Time
+		wantErr            bool
+	}{
+		{"defaults", "", "", "", "", Range7Days, BucketDay, day(2, 29), day(3, 7), false},
+		{"unknown values", "week", "minute", "", "", Range7Days, BucketDay, day(2, 29), day(3, 7), false},
+		{"30 days by week", "30d", "week", "", "", Range30Days, BucketWeek, day(2, 6), day(3, 7), false},
+		{"a year", "1y", "month", "", "", Range1Year, BucketMonth, time.Date(2023, 3, 7, 0, 0, 0, 0, time.UTC), day(3, 7), false},
+		{"all", "all", "day", "", "", RangeAll, BucketDay, time.Time{}, day(3, 7), false},
+		{"custom", "custom", "hour", "2024-01-01", "2024-01-31", RangeCustom, BucketHour, day(1, 1), day(2, 1), false},
+		{"custom single day", "custom", "day", "2024-01-01", "2024-01-01", RangeCustom, BucketDay, day(1, 1), day(1, 2), false},
+		{"custom end before start", "custom", "day", "2024-01-31", "2024-01-01", "", "", time.Time{}, time.Time{}, true},
+		{"custom without end", "custom", "day", "2024-01-01", "", "", "", time.Time{}, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period, err := NewChartPeriod(tt.chartRange, tt.bucket, tt.start, tt.end, now, time.UTC)
+			if tt.wantErr {
+				if err != ErrInvalidChartPeriod {
+					t.Errorf("got err %v, want ErrInvalidChartPeriod", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got err %v", err)
+			}
+			want := ChartPeriod{Range: tt.wantRange, Start: tt.wantStart, End: tt.wantEnd, Bucket: tt.wantBucket, Location: time.UTC}
+			if period != want {
+				t.Errorf("got %+v, want %+v", period, want)
+			}
+		})
+	}
+}
+
+func TestChartPeriodFit(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 6, 15, 4, 0, 0, time.UTC)
+	year, _ := NewChartPeriod("1y", "hour", "", "", now, time.UTC)
+	if got := year.Fit(time.Time{}).Bucket; got != BucketDay {
+		t.Errorf("a year by hour fits as %v, want day", got)
+	}
+	week, _ := NewChartPeriod("7d", "hour", "", "", now, time.UTC)
+	if got := week.Fit(time.Time{}).Bucket; got != BucketHour {
+		t.Errorf("a week by hour fits as %v, want hour", got)
+	}
+	all, _ := NewChartPeriod("all", "day", "", "", now, time.UTC)
+	if got := all.Fit(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)).Bucket; got != BucketWeek {
+		t.Errorf("three years by day fits as %v, want week", got)
+	}
+}
+
+func TestChartBucketTruncate(t *testing.T) {
+	t.Parallel()
+
+	// A Sunday evening in UTC.
+	at := time.Date(2024, 3, 10, 22, 30, 0, 0, time.UTC)
+	var tests = []struct {
+		bucket ChartBucket
+		want   time.Time
+	}{
+		{BucketHour, time.Date(2024, 3, 10, 22, 0, 0, 0, time.UTC)},
+		{BucketDay, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{BucketWeek, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{BucketMonth, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.bucket.Truncate(at); !got.Equal(tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.bucket, got, tt.want)
+		}
+	}
+}
+
+func TestMakeLineChartFillsEmptyBuckets(t *testing.T) {
+	t.Parallel()
+
+	period, _ := NewChartPeriod("custom", "week", "2024-02-26", "2024-03-17", time.Now(), time.UTC)
+	counts := []SearchQueryCount{
+		{Timestamp: time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), Count: 2},
+		{Timestamp: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), Count: 5},
+	}
+	chart := MakeLineChartFromSearchQueryCounts([]ChartSeries{{Label: "label", Counts: counts}}, period, "title")
+	if want := []string{"02-26", "03-04", "03-11"}; !slices.Equal(chart.Labels, want) {
+		t.Errorf("labels = %v, want %v", chart.Labels, want)
+	}
+	if want := []float64{2, 0, 5}; !slices.Equal(chart.Datasets[0].Data, want) {
+		t.Errorf("data = %v, want %v", chart.Datasets[0].Data, want)
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %v: %v", name, err)
+	}
+	return loc
+}
+
+// The same instant is on different days, weeks and months depending on the
+// zone: ten to midnight UTC on Sunday the 31st of March is already Monday the
+// 1st of April in Copenhagen, an hour after its clocks went forward.
+func TestChartBucketTruncateInZone(t *testing.T) {
+	t.Parallel()
+
+	copenhagen := mustLoadLocation(t, "Europe/Copenhagen")
+	kolkata := mustLoadLocation(t, "Asia/Kolkata")
+	at := time.Date(2024, 3, 31, 23, 50, 0, 0, time.UTC)
+	var tests = []struct {
+		name   string
+		loc    *time.Location
+		bucket ChartBucket
+		want   string
+	}{
+		{"utc day", time.UTC, BucketDay, "2024-03-31T00:00:00Z"},
+		{"utc week", time.UTC, BucketWeek, "2024-03-25T00:00:00Z"},
+		{"utc month", time.UTC, BucketMonth, "2024-03-01T00:00:00Z"},
+		{"day", copenhagen, BucketDay, "2024-04-01T00:00:00+02:00"},
+		{"week", copenhagen, BucketWeek, "2024-04-01T00:00:00+02:00"},
+		{"month", copenhagen, BucketMonth, "2024-04-01T00:00:00+02:00"},
+		{"hour", copenhagen, BucketHour, "2024-04-01T01:00:00+02:00"},
+		{"half hour zone", kolkata, BucketHour, "2024-04-01T05:00:00+05:30"},
+		{"half hour zone day", kolkata, BucketDay, "2024-04-01T00:00:00+05:30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.bucket.Truncate(at.In(tt.loc)).Format(time.RFC3339); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The day the clocks go forward has 23 hours and the day they go back 25, and
+// the hour that happens twice is two buckets with the same label.
+func TestChartPeriodAcrossDaylightSaving(t *testing.T) {
+	t.Parallel()
+
+	copenhagen := mustLoadLocation(t, "Europe/Copenhagen")
+	var tests = []struct {
+		day       string
+		wantHours int
+	}{
+		{"2024-03-31", 23},
+		{"2024-10-27", 25},
+		{"2024-06-01", 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.day, func(t *testing.T) {
+			period, err := NewChartPeriod("custom", "hour", tt.day, tt.day, time.Now(), copenhagen)
+			if err != nil {
+				t.Fatalf("got err %v", err)
+			}
+			if got := len(period.buckets(period.Start)); got != tt.wantHours {
+				t.Errorf("got %v hours, want %v", got, tt.wantHours)
+			}
+		})
+	}
+
+	// 02:30 in summer time, and 02:30 again an hour later in winter time.
+	period, _ := NewChartPeriod("custom", "hour", "2024-10-27", "2024-10-27", time.Now(), copenhagen)
+	counts := []SearchQueryCount{
+		{Timestamp: time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC), Count: 1},
+		{Timestamp: time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC), Count: 2},
+	}
+	chart := MakeLineChartFromSearchQueryCounts([]ChartSeries{{Label: "label", Counts: counts}}, period, "title")
+	if got, want := chart.Labels[2:4], []string{"10-27 02:00", "10-27 02:00"}; !slices.Equal(got, want) {
+		t.Errorf("labels around the change = %v, want %v", got, want)
+	}
+	if got, want := chart.Datasets[0].Data[1:5], []float64{0, 1, 2, 0}; !slices.Equal(got, want) {
+		t.Errorf("data around the change = %v, want %v", got, want)
+	}
+
+	// A week over the change is still Monday to Monday, and a month's buckets
+	// start at local midnight on the first.
+	week, _ := NewChartPeriod("custom", "week", "2024-03-25", "2024-04-07", time.Now(), copenhagen)
+	starts := week.buckets(week.Start)
+	if len(starts) != 2 || starts[1].Format(time.RFC3339) != "2024-04-01T00:00:00+02:00" {
+		t.Errorf("weeks = %v, want the second to start on Monday the 1st of April", starts)
+	}
+	if got := starts[1].Sub(starts[0]); got != 167*time.Hour {
+		t.Errorf("week with the change is %v long, want 167h", got)
+	}
+	month, _ := NewChartPeriod("custom", "month", "2024-10-01", "2024-11-30", time.Now(), copenhagen)
+	starts = month.buckets(month.Start)
+	if len(starts) != 2 || starts[1].Format(time.RFC3339) != "2024-11-01T00:00:00+01:00" {
+		t.Errorf("months = %v, want the second to start at midnight on the 1st of November", starts)
+	}
+}
+
+// The preset ranges end with today in the zone, not in UTC: half past
+// midnight in Copenhagen is still yesterday in UTC.
+func TestNewChartPeriodTodayInZone(t *testing.T) {
+	t.Parallel()
+
+	copenhagen := mustLoadLocation(t, "Europe/Copenhagen")
+	now := time.Date(2024, 3, 6, 23, 30, 0, 0, time.UTC)
+	period := DefaultChartPeriod(now, copenhagen)
+	if got, want := period.Start.Format(time.RFC3339), "2024-03-01T00:00:00+01:00"; got != want {
+		t.Errorf("start = %v, want %v", got, want)
+	}
+	if got, want := period.End.Format(time.RFC3339), "2024-03-08T00:00:00+01:00"; got != want {
+		t.Errorf("end = %v, want %v", got, want)
+	}
+}
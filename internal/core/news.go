@@ -1,24 +1,47 @@
 package core
 
 import (
+	"cmp"
 	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
-	"regexp"
-	"strings"
+	"io"
+	"math"
+	"slices"
 	"time"
 
+	"github.com/bjarke-xyz/rasende2/internal/config"
 	"github.com/bjarke-xyz/rasende2/internal/lang"
 	"github.com/gosimple/slug"
 )
 
 type NewsRepository interface {
 	GetSites(ctx context.Context) ([]NewsSite, error)
+	CreateSite(ctx context.Context, site NewsSite) (int, error)
+	UpdateSite(ctx context.Context, site NewsSite) error
 	GetSiteNames(ctx context.Context) ([]string, error)
 	GetRecentItems(ctx context.Context, siteId int, limit int, insertAtOffset *time.Time) ([]RssItemDto, error)
-	GetExistingItemsByIds(ctx context.Context, itemIds []string) (map[string]any, error)
+	GetExistingItems(ctx context.Context, siteId int, items []RssItemDto) (map[string]RssItemDto, error)
+	GetItem(ctx context.Context, itemId string) (*RssItemDto, error)
+	GetItemRevisions(ctx context.Context, itemId string) ([]RssItemRevision, error)
 	GetArticleCounts(ctx context.Context) (map[int]int, error)
 	InsertItems(ctx context.Context, newsSite NewsSite, items []RssItemDto) (int, error)
+	UpdateItemContent(ctx context.Context, newsSite NewsSite, itemId string, content string, canonicalLink string) error
+	ReviseItem(ctx context.Context, newsSite NewsSite, storedItemId string, item RssItemDto) (bool, error)
+	GetSiteItems(ctx context.Context, siteId int) ([]RssItemDto, error)
+	DeleteItems(ctx context.Context, newsSite NewsSite, itemIds []string) (int, error)
+	GetItemsToArchive(ctx context.Context, siteId int, publishedBefore time.Time, limit int) ([]RssItemDto, error)
+	ArchiveItems(ctx context.Context, newsSite NewsSite, itemIds []string, archivedAt time.Time) error
+	GetFeedValidators(ctx context.Context, urls []string) (map[string]FeedValidators, error)
+	SaveFeedValidators(ctx context.Context, validators map[string]FeedValidators) error
+	GetFeedHealth(ctx context.Context) ([]FeedHealth, error)
+	SaveFeedHealth(ctx context.Context, health FeedHealth) error
+	CreateJobRun(ctx context.Context, kind string, startedAt time.Time) (int64, error)
+	FinishJobRun(ctx context.Context, run JobRun) error
+	FailRunningJobRuns(ctx context.Context, reason string) error
+	GetJobRuns(ctx context.Context, limit int) ([]JobRun, error)
+	GetJobRun(ctx context.Context, id int64) (*JobRun, error)
 	EnrichSiteCountWithSiteNames(ctx context.Context, siteCounts []SiteCount)
 	EnrichRssSearchResultWithSiteNames(ctx context.Context, rssSearchResults []RssSearchResult)
 
@@ -38,17 +61,29 @@ type NewsService interface {
 	Initialise(ctx context.Context)
 	Dispose()
 	GetIndexPageData(ctx context.Context, l lang.Lang) (*IndexPageData, error)
-	GetChartData(ctx context.Context, l lang.Lang, query string) (ChartsResult, error)
+	GetChartData(ctx context.Context, l lang.Lang, terms []string, filter SearchFilter, period ChartPeriod) (ChartsResult, error)
 	GetSiteNames(ctx context.Context) ([]string, error)
 	GetSiteInfos(ctx context.Context, l lang.Lang) ([]NewsSite, error)
 	GetSiteInfo(ctx context.Context, siteName string) (*NewsSite, error)
 	GetSiteInfoById(ctx context.Context, id int) (*NewsSite, error)
-	SearchItems(ctx context.Context, l lang.Lang, query string, searchContent bool, offset int, limit int, orderBy string) ([]RssSearchResult, error)
-	GetItemCountForSearchQuery(ctx context.Context, l lang.Lang, query string, searchContent bool, start *time.Time, end *time.Time, orderBy string) ([]SearchQueryCount, error)
-	GetSiteCountForSearchQuery(ctx context.Context, l lang.Lang, query string, searchContent bool) ([]SiteCount, error)
+	GetSites(ctx context.Context) ([]NewsSite, error)
+	CreateSite(ctx context.Context, site NewsSite) (int, error)
+	UpdateSite(ctx context.Context, site NewsSite) error
+	TestFetch(ctx context.Context, site NewsSite, url string) ([]RssItemDto, error)
+	ExportOpml(ctx context.Context) ([]byte, error)
+	ImportOpml(ctx context.Context, body io.Reader) (OpmlImport, error)
+	ImportSitesJson(ctx context.Context, body io.Reader) (OpmlImport, error)
+	MatchContentRule(ctx context.Context, site NewsSite, rule ContentRule) ([]RssItemDto, error)
+	PurgeContentRule(ctx context.Context, site NewsSite, rule ContentRule) (int, error)
+	SearchItems(ctx context.Context, l lang.Lang, query string, searchContent bool, filter SearchFilter, offset int, limit int, orderBy string) ([]RssSearchResult, error)
+	GetItemCountForSearchQuery(ctx context.Context, l lang.Lang, query string, searchContent bool, filter SearchFilter, start *time.Time, end *time.Time, bucket ChartBucket) ([]SearchQueryCount, error)
+	GetSiteCountForSearchQuery(ctx context.Context, l lang.Lang, query string, searchContent bool, filter SearchFilter, start *time.Time, end *time.Time) ([]SiteCount, error)
+	GetSiteRates(ctx context.Context, l lang.Lang, query string, filter SearchFilter, start *time.Time, end *time.Time) ([]SiteRate, error)
 	GetRecentTitles(ctx context.Context, siteInfo NewsSite, limit int, shuffle bool) ([]string, error)
 	GetRecentItems(ctx context.Context, siteId int, limit int, insertedAtOffset *time.Time) ([]RssItemDto, error)
-	RebuildSearchIndexAndLogError(ctx context.Context)
+	GetItem(ctx context.Context, itemId string) (*RssItemDto, error)
+	GetItemRevisions(ctx context.Context, itemId string) ([]RssItemRevision, error)
+	StartRebuildSearchIndex(ctx context.Context) (*JobRun, error)
 
 	GetPopularFakeNews(ctx context.Context, limit int, publishedAfter *time.Time, votes int) ([]FakeNewsDto, error)
 	GetRecentFakeNews(ctx context.Context, limit int, publishedAfter *time.Time) ([]FakeNewsDto, error)
@@ -62,8 +97,13 @@ type NewsService interface {
 	VoteFakeNews(ctx context.Context, siteId int, title string, votes int) (int, error)
 	CleanUpFakeNewsAndLogError(ctx context.Context)
 	CleanUpFakeNews(ctx context.Context) error
+	AutoGenerateFakeNews(ctx context.Context) (*FakeNewsDto, error)
 
 	FetchAndSaveNewItems(ctx context.Context) error
+	ArchiveItems(ctx context.Context) error
+	GetFeedHealth(ctx context.Context) ([]FeedHealth, error)
+	GetJobRuns(ctx context.Context, limit int) ([]JobRun, error)
+	GetJobRun(ctx context.Context, id int64) (*JobRun, error)
 	RefreshMetrics(ctx context.Context) error
 }
 
@@ -77,19 +117,77 @@ type SiteCount struct {
 	Count    int    `json:"count"`
 }
 
+// SiteRate is how much of what a site published over a period matched a query:
+// Matches among its Articles, and Rate, the matches per RatePer articles. It
+// compares outlets of any size, where a SiteCount favours the biggest.
+type SiteRate struct {
+	SiteId   int     `json:"siteId"`
+	SiteName string  `json:"siteName"`
+	Matches  int     `json:"matches"`
+	Articles int     `json:"articles"`
+	Rate     float64 `json:"rate"`
+}
+
+// RatePer is the number of articles a SiteRate's Rate is per.
+const RatePer = 1000
+
+// MinRateArticles is the fewest items a site must have published in a period to
+// get a rate for it. Below that, a single match would put a quiet site at the
+// top of the leaderboard.
+const MinRateArticles = 50
+
+// TrackedTerms are the queries whose matches in the l edition are rolled up per
+// site and day: the edition's default query, then the configured ones, each
+// once.
+func TrackedTerms(cfg *config.Config, l lang.Lang) []string {
+	terms := []string{l.DefaultQuery}
+	for _, term := range cfg.TrackedTerms {
+		if !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
 type SearchQueryCount struct {
 	Timestamp time.Time `json:"timestamp"`
 	Count     int       `json:"count"`
 }
 
+// SearchFilter narrows a search beyond its query. The zero value filters
+// nothing.
+type SearchFilter struct {
+	// Category keeps the items filed under it, ignoring case.
+	Category string
+	// CollapseDuplicates counts each cluster of duplicates once, and keeps only
+	// its earliest item in the results.
+	CollapseDuplicates bool
+}
+
 type RssSearchResult struct {
-	ItemId    string    `json:"itemId"`
-	SiteName  string    `json:"siteName"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Link      string    `json:"link"`
-	Published time.Time `json:"published"`
-	SiteId    int       `json:"siteId"`
+	ItemId     string    `json:"itemId"`
+	SiteName   string    `json:"siteName"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Link       string    `json:"link"`
+	Published  time.Time `json:"published"`
+	SiteId     int       `json:"siteId"`
+	Authors    []string  `json:"authors"`
+	Categories []string  `json:"categories"`
+	ImageUrl   string    `json:"imageUrl"`
+	// Duplicates is how many more copies of the story a collapsed search left
+	// out.
+	Duplicates int `json:"duplicates"`
+	// Snippet is a short piece of the content around what matched, with the
+	// matching words marked, or nil if the item has no content.
+	Snippet []SnippetPart `json:"snippet"`
+}
+
+// SnippetPart is a piece of a search result's snippet. Match marks a word that
+// matched the query, inflected forms included.
+type SnippetPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match"`
 }
 
 type NewsSite struct {
@@ -104,27 +202,181 @@ type NewsSite struct {
 	// Language is the language the site publishes in, and so the language its
 	// items are stemmed and searched in. Items carry no language of their own —
 	// they inherit this one. It must have an analyzer in internal/search, which
-	// the repository checks when it loads rss.json.
+	// the repository checks when it stores or loads a site.
 	Language string `json:"language"`
 
-	Id                   int      `json:"id"`
-	Disabled             bool     `json:"disabled"`
-	ArticleHasContent    bool     `json:"articleHasContent"`
-	UserAgentKey         string   `json:"userAgentKey"`
-	BlockedTitlePatterns []string `json:"blockedTitlePatterns"`
+	Id                int    `json:"id"`
+	Disabled          bool   `json:"disabled"`
+	ArticleHasContent bool   `json:"articleHasContent"`
+	UserAgentKey      string `json:"userAgentKey"`
+
+	// ContentRules decide which of the site's items are stored; see ContentRule.
+	ContentRules []ContentRule `json:"contentRules"`
+	// compiledRules are ContentRules ready to match, or nil until CompileRules.
+	compiledRules *[]compiledRule
+
+	// ArticleSelector is a CSS selector for the article body on the site's
+	// article pages. When it is set, and ArticleHasContent is not, each new item's
+	// content is replaced by the text extracted from its link.
+	// ArticleExcludeSelector matches elements inside the body to leave out.
+	ArticleSelector        string `json:"articleSelector"`
+	ArticleExcludeSelector string `json:"articleExcludeSelector"`
+
+	// NoAutoGenerate keeps the scheduled fake news generation away from the
+	// site. It can still be picked by hand in the title generator.
+	NoAutoGenerate bool `json:"noAutoGenerate"`
+
+	// Draft marks a site that was imported and not yet reviewed. A draft is
+	// always disabled, and is the one kind of site whose Language need not be
+	// one of the editions; enabling it ends the draft.
+	Draft bool `json:"draft"`
+
+	// ContentRetentionMonths is how many months the site's items keep their
+	// content before the archive job drops it. 0 follows the global
+	// config.ContentRetentionMonths, and a negative number keeps it forever.
+	ContentRetentionMonths int `json:"contentRetentionMonths"`
+
+	// SourceType is what Urls point at, and so how they are read: one of
+	// SourceTypes. Empty means SourceRss.
+	SourceType string `json:"sourceType"`
+	// HtmlSource says where the headlines are on the listing pages of a
+	// SourceHtml site. It is ignored for the other types.
+	HtmlSource HtmlSource `json:"htmlSource"`
 }
 
-func (n NewsSite) IsBlockedTitle(title string) (bool, error) {
-	for _, blockedTitlePattern := range n.BlockedTitlePatterns {
-		compiledPattern, err := regexp.Compile(blockedTitlePattern)
-		if err != nil {
-			return false, fmt.Errorf("error compiling pattern '%v': %w", blockedTitlePattern, err)
-		}
-		if compiledPattern.MatchString(strings.TrimSpace(title)) {
-			return true, nil
-		}
+// The types of source a site's Urls can be.
+const (
+	// SourceRss is an RSS or Atom feed.
+	SourceRss = "rss"
+	// SourceJsonFeed is a JSON Feed, version 1.0 or 1.1.
+	SourceJsonFeed = "jsonfeed"
+	// SourceHtml is an ordinary web page listing headlines, for outlets with no
+	// usable feed. Its items have a title, a link and perhaps a time, but no
+	// content unless the site also has an ArticleSelector.
+	SourceHtml = "html"
+)
+
+var SourceTypes = []string{SourceRss, SourceJsonFeed, SourceHtml}
+
+// HtmlSource holds the CSS selectors that pull items out of a listing page. All
+// but ItemSelector are matched within each element ItemSelector matches.
+type HtmlSource struct {
+	// ItemSelector matches one element per headline. It is required.
+	ItemSelector string `json:"itemSelector"`
+	// TitleSelector matches the headline within an item. Empty takes the text of
+	// the link, which on most listing pages is the headline.
+	TitleSelector string `json:"titleSelector"`
+	// LinkSelector matches the element whose href is the article. Empty takes
+	// the item's own href, or else that of the first link in it.
+	LinkSelector string `json:"linkSelector"`
+	// TimeSelector matches the publication time within an item, which is read
+	// from its datetime attribute if it has one and from its text otherwise.
+	// Empty dates items when they are first seen.
+	TimeSelector string `json:"timeSelector"`
+	// TimeLayout is the Go time layout the time is written in. Empty means RFC
+	// 3339, which is what datetime attributes hold. A time written without a
+	// zone is read in the time zone of the site's edition.
+	TimeLayout string `json:"timeLayout"`
+}
+
+// RetentionMonths returns how many months the site's items keep their content,
+// given the global policy, or 0 if they keep it forever.
+func (n NewsSite) RetentionMonths(global int) int {
+	switch {
+	case n.ContentRetentionMonths < 0:
+		return 0
+	case n.ContentRetentionMonths > 0:
+		return n.ContentRetentionMonths
+	default:
+		return max(global, 0)
 	}
-	return false, nil
+}
+
+// Source returns the site's source type, with the default filled in.
+func (n NewsSite) Source() string {
+	if n.SourceType == "" {
+		return SourceRss
+	}
+	return n.SourceType
+}
+
+// OpmlImport is what importing an OPML feed list, or a JSON list of sites,
+// did.
+type OpmlImport struct {
+	// Created are the draft sites it made, and Skipped the names of those it
+	// left out because their name, or a feed of theirs, belongs to a site.
+	Created []NewsSite
+	Skipped []string
+	// Unanalyzed are the created sites whose language has no analyzer in
+	// internal/search. They cannot be enabled until it is changed to one that
+	// has.
+	Unanalyzed []NewsSite
+}
+
+// FeedValidators are the cache validators a feed URL answered with, sent back on
+// the next fetch so that an unchanged feed can answer 304 Not Modified.
+type FeedValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// FeedHealth is how one feed URL has been doing. A feed with NextFetchAt in the
+// future is backing off after repeated failures and is not fetched until then.
+type FeedHealth struct {
+	SiteId              int        `json:"siteId"`
+	SiteName            string     `json:"siteName"`
+	Url                 string     `json:"url"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt"`
+	LastErrorAt         *time.Time `json:"lastErrorAt"`
+	LastError           string     `json:"lastError"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	NextFetchAt         *time.Time `json:"nextFetchAt"`
+}
+
+// Due reports whether the feed may be fetched at now.
+func (h FeedHealth) Due(now time.Time) bool {
+	return h.NextFetchAt == nil || !now.Before(*h.NextFetchAt)
+}
+
+// BackingOff reports whether the feed is being skipped at now.
+func (h FeedHealth) BackingOff(now time.Time) bool {
+	return !h.Due(now)
+}
+
+// The kinds of job that record a JobRun.
+const (
+	JobFetchItems           = "fetch-items"
+	JobRebuildSearchIndex   = "rebuild-search-index"
+	JobCleanFakeNews        = "clean-fake-news"
+	JobAutoGenerateFakeNews = "auto-generate-fake-news"
+	JobArchiveItems         = "archive-items"
+)
+
+// The states of a JobRun. A run is JobRunning from the moment it starts until it
+// returns, whether it was started by the scheduler or through the API.
+//
+// A run started while another of its kind is still going is not run at all: it
+// is recorded as JobSkipped, and fails with ErrJobBusy.
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobSkipped   = "skipped"
+)
+
+// ErrJobBusy is returned for a run of a job that is already running.
+var ErrJobBusy = errors.New("job is already running")
+
+// JobRun is one run of a background job. Counters are whatever the job counts,
+// such as items inserted per site or documents reindexed.
+type JobRun struct {
+	Id         int64          `json:"id"`
+	Kind       string         `json:"kind"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt *time.Time     `json:"finishedAt"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error"`
+	Counters   map[string]int `json:"counters"`
 }
 
 type RssItemDto struct {
@@ -136,6 +388,29 @@ type RssItemDto struct {
 	Published  time.Time  `db:"published" json:"published"`
 	InsertedAt *time.Time `db:"inserted_at" json:"insertedAt"`
 	SiteId     int        `db:"site_id" json:"siteId"`
+	Authors    []string   `db:"authors" json:"authors"`
+	Categories []string   `db:"categories" json:"categories"`
+	ImageUrl   string     `db:"image_url" json:"imageUrl"`
+	Guid       string     `db:"guid" json:"guid"`
+	// CanonicalLink is Link normalised to compare by: tracking parameters
+	// stripped, and scheme and host made uniform. Once the article page has been
+	// fetched, it is the page's own rel=canonical, if it names one.
+	CanonicalLink string `db:"canonical_link" json:"canonicalLink"`
+	// ClusterId groups the copies of one story: it is the id of the cluster's
+	// first item, and 0 for items stored before clustering.
+	ClusterId int64 `db:"cluster_id" json:"clusterId"`
+	// FeedLink is the link as the feed gave it, before its tracking parameters
+	// were dropped from Link. It is not stored: it finds the items stored
+	// before links were cleaned, under the feed's link.
+	FeedLink string `db:"-" json:"-"`
+}
+
+// RssItemRevision is one version of an item's title and content, and when it
+// was first seen.
+type RssItemRevision struct {
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	SeenAt  time.Time `json:"seenAt"`
 }
 
 type FakeNewsDto struct {
@@ -168,8 +443,8 @@ type SearchResult struct {
 }
 
 type ChartDataset struct {
-	Label string `json:"label"`
-	Data  []int  `json:"data"`
+	Label string    `json:"label"`
+	Data  []float64 `json:"data"`
 }
 
 type ChartResult struct {
@@ -177,53 +452,71 @@ type ChartResult struct {
 	Title    string         `json:"title"`
 	Labels   []string       `json:"labels"`
 	Datasets []ChartDataset `json:"datasets"`
+	// Stacked stacks the datasets of a bar chart on top of each other.
+	Stacked bool `json:"stacked,omitempty"`
 }
 
 type ChartsResult struct {
 	Charts []ChartResult `json:"charts"`
 }
 
-func MakeLineChartFromSearchQueryCount(searchQueryCounts []SearchQueryCount, title string, datasetLabel string) ChartResult {
-	dateFormat := "01-02"
-	labels := make([]string, 7)
-	data := make([]int, 7)
-	weekItemsGroupedByDate := make(map[int64]int, 0)
-	for _, v := range searchQueryCounts {
-		weekItemsGroupedByDate[v.Timestamp.Unix()] = v.Count
-	}
-
-	today := time.Now()
-	sevenDaysAgo := today.Add(-time.Hour * 24 * 6)
-	i := 0
-	for d := sevenDaysAgo; !d.After(today); d = d.AddDate(0, 0, 1) {
-		dKey := d.Truncate(time.Hour * 24)
-		labels[i] = dKey.Format(dateFormat)
-		datum, ok := weekItemsGroupedByDate[dKey.Unix()]
-		if ok {
-			data[i] = datum
+// MaxChartTerms is the most terms one chart compares.
+const MaxChartTerms = 5
+
+// ChartSeries is the counts behind one line of a line chart.
+type ChartSeries struct {
+	Label  string
+	Counts []SearchQueryCount
+}
+
+// MakeLineChartFromSearchQueryCounts lays each series out over the buckets of
+// the period, with 0 for a bucket with no count. A period with no start begins
+// at the earliest count of any of them.
+func MakeLineChartFromSearchQueryCounts(series []ChartSeries, period ChartPeriod, title string) ChartResult {
+	first := period.Start
+	if first.IsZero() {
+		for _, s := range series {
+			if len(s.Counts) > 0 && (first.IsZero() || s.Counts[0].Timestamp.Before(first)) {
+				first = s.Counts[0].Timestamp
+			}
 		}
-		i++
+	}
+	buckets := []time.Time{}
+	labels := []string{}
+	if !first.IsZero() {
+		buckets = period.buckets(first)
+		withYear := first.In(period.location()).Year() != period.End.Add(-time.Nanosecond).In(period.location()).Year()
+		for _, bucket := range buckets {
+			labels = append(labels, period.Bucket.label(bucket, withYear))
+		}
+	}
+	datasets := make([]ChartDataset, len(series))
+	for i, s := range series {
+		countsByBucket := make(map[int64]int, len(s.Counts))
+		for _, v := range s.Counts {
+			countsByBucket[period.Truncate(v.Timestamp).Unix()] += v.Count
+		}
+		data := make([]float64, len(buckets))
+		for j, bucket := range buckets {
+			data[j] = float64(countsByBucket[bucket.Unix()])
+		}
+		datasets[i] = ChartDataset{Label: s.Label, Data: data}
 	}
 	return ChartResult{
-		Type:   "line",
-		Title:  title,
-		Labels: labels,
-		Datasets: []ChartDataset{
-			{
-				Label: datasetLabel,
-				Data:  data,
-			},
-		},
+		Type:     "line",
+		Title:    title,
+		Labels:   labels,
+		Datasets: datasets,
 	}
 }
 
 func MakeDoughnutChartFromSiteCount(siteCounts []SiteCount, title string) ChartResult {
 
 	labels := make([]string, len(siteCounts))
-	data := make([]int, len(siteCounts))
+	data := make([]float64, len(siteCounts))
 	for i, siteCount := range siteCounts {
 		labels[i] = siteCount.SiteName
-		data[i] = siteCount.Count
+		data[i] = float64(siteCount.Count)
 	}
 
 	return ChartResult{
@@ -238,3 +531,71 @@ func MakeDoughnutChartFromSiteCount(siteCounts []SiteCount, title string) ChartR
 		},
 	}
 }
+
+// MakeStackedBarChartFromSiteCounts stacks the matches of each term per site,
+// one dataset per term, with the site with the most matches in all first.
+func MakeStackedBarChartFromSiteCounts(terms []string, siteCounts [][]SiteCount, title string) ChartResult {
+	totals := map[string]int{}
+	countsBySite := make([]map[string]int, len(terms))
+	for i := range terms {
+		countsBySite[i] = map[string]int{}
+		for _, siteCount := range siteCounts[i] {
+			countsBySite[i][siteCount.SiteName] += siteCount.Count
+			totals[siteCount.SiteName] += siteCount.Count
+		}
+	}
+	labels := make([]string, 0, len(totals))
+	for siteName := range totals {
+		labels = append(labels, siteName)
+	}
+	slices.SortFunc(labels, func(a, b string) int {
+		return cmp.Or(cmp.Compare(totals[b], totals[a]), cmp.Compare(a, b))
+	})
+	datasets := make([]ChartDataset, len(terms))
+	for i, term := range terms {
+		data := make([]float64, len(labels))
+		for j, siteName := range labels {
+			data[j] = float64(countsBySite[i][siteName])
+		}
+		datasets[i] = ChartDataset{Label: term, Data: data}
+	}
+	return ChartResult{
+		Type:     "bar",
+		Title:    title,
+		Labels:   labels,
+		Datasets: datasets,
+		Stacked:  true,
+	}
+}
+
+// MakeBarChartFromSiteRates charts the rates of each term side by side, one
+// dataset per term, rounded to a tenth. The sites are in the order of the first
+// term's rates.
+func MakeBarChartFromSiteRates(siteRates [][]SiteRate, datasetLabels []string, title string) ChartResult {
+	labels := []string{}
+	siteIds := []int{}
+	if len(siteRates) > 0 {
+		for _, siteRate := range siteRates[0] {
+			labels = append(labels, siteRate.SiteName)
+			siteIds = append(siteIds, siteRate.SiteId)
+		}
+	}
+	datasets := make([]ChartDataset, len(siteRates))
+	for i, rates := range siteRates {
+		rateBySite := make(map[int]float64, len(rates))
+		for _, siteRate := range rates {
+			rateBySite[siteRate.SiteId] = siteRate.Rate
+		}
+		data := make([]float64, len(siteIds))
+		for j, siteId := range siteIds {
+			data[j] = math.Round(rateBySite[siteId]*10) / 10
+		}
+		datasets[i] = ChartDataset{Label: datasetLabels[i], Data: data}
+	}
+	return ChartResult{
+		Type:     "bar",
+		Title:    title,
+		Labels:   labels,
+		Datasets: datasets,
+	}
+}
@@ -1,6 +1,10 @@
 package core
 
-import "testing"
+import (
+	"slices"
+	"testing"
+	"time"
+)
 
 func TestNewsBlockedTitlePattern(t *testing.T) {
 	t.Parallel()
@@ -23,17 +27,21 @@ func TestNewsBlockedTitlePattern(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.title, func(t *testing.T) {
+			var rules []ContentRule
+			for _, pattern := range tt.blockedTitlePatterns {
+				rules = append(rules, ContentRule{Action: RuleBlock, Field: RuleTitle, Pattern: pattern})
+			}
 			newsSite := NewsSite{
-				Name:                 "Test site",
-				Urls:                 []string{"https://example.org"},
-				Description:          "A test site",
-				Language:             "da",
-				Id:                   1,
-				Disabled:             false,
-				ArticleHasContent:    false,
-				BlockedTitlePatterns: tt.blockedTitlePatterns,
+				Name:              "Test site",
+				Urls:              []string{"https://example.org"},
+				Description:       "A test site",
+				Language:          "da",
+				Id:                1,
+				Disabled:          false,
+				ArticleHasContent: false,
+				ContentRules:      rules,
 			}
-			result, err := newsSite.IsBlockedTitle(tt.title)
+			result, err := newsSite.Blocks(RssItemDto{Title: tt.title})
 			if tt.expectedError && err == nil {
 				t.Errorf("got nil err, but expected err")
 			}
@@ -43,3 +51,59 @@ func TestNewsBlockedTitlePattern(t *testing.T) {
 		})
 	}
 }
+
+func TestRetentionMonths(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		site, global, want int
+	}{
+		{0, 0, 0},
+		{0, 12, 12},
+		{6, 12, 6},
+		{6, 0, 6},
+		{-1, 12, 0},
+	}
+	for _, tt := range tests {
+		site := NewsSite{ContentRetentionMonths: tt.site}
+		if got := site.RetentionMonths(tt.global); got != tt.want {
+			t.Errorf("RetentionMonths(%v) of a site with %v = %v, want %v", tt.global, tt.site, got, tt.want)
+		}
+	}
+}
+
+// Compared terms are charted side by side: a line each over the same buckets,
+// their matches stacked per site with the busiest site first, and their rates
+// in the order of the first term's.
+func TestCompareCharts(t *testing.T) {
+	t.Parallel()
+
+	period, _ := NewChartPeriod("custom", "day", "2024-03-01", "2024-03-03", time.Now(), time.UTC)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	line := MakeLineChartFromSearchQueryCounts([]ChartSeries{
+		{Label: "rasende", Counts: []SearchQueryCount{{Timestamp: day(1), Count: 2}}},
+		{Label: "vred", Counts: []SearchQueryCount{{Timestamp: day(2), Count: 1}, {Timestamp: day(3), Count: 4}}},
+	}, period, "title")
+	if len(line.Datasets) != 2 || !slices.Equal(line.Datasets[0].Data, []float64{2, 0, 0}) || !slices.Equal(line.Datasets[1].Data, []float64{0, 1, 4}) {
+		t.Errorf("line datasets = %+v, want a line per term over three days", line.Datasets)
+	}
+
+	stacked := MakeStackedBarChartFromSiteCounts([]string{"rasende", "vred"}, [][]SiteCount{
+		{{SiteName: "BT", Count: 1}, {SiteName: "DR", Count: 2}},
+		{{SiteName: "BT", Count: 5}},
+	}, "title")
+	if !stacked.Stacked || !slices.Equal(stacked.Labels, []string{"BT", "DR"}) {
+		t.Errorf("stacked = %v %v, want stacked bars with BT first", stacked.Stacked, stacked.Labels)
+	}
+	if !slices.Equal(stacked.Datasets[0].Data, []float64{1, 2}) || !slices.Equal(stacked.Datasets[1].Data, []float64{5, 0}) {
+		t.Errorf("stacked datasets = %+v", stacked.Datasets)
+	}
+
+	rates := MakeBarChartFromSiteRates([][]SiteRate{
+		{{SiteId: 2, SiteName: "DR", Rate: 12.34}, {SiteId: 1, SiteName: "BT", Rate: 3}},
+		{{SiteId: 1, SiteName: "BT", Rate: 8}, {SiteId: 2, SiteName: "DR", Rate: 0}},
+	}, []string{"rasende", "vred"}, "title")
+	if !slices.Equal(rates.Labels, []string{"DR", "BT"}) || !slices.Equal(rates.Datasets[0].Data, []float64{12.3, 3}) || !slices.Equal(rates.Datasets[1].Data, []float64{0, 8}) {
+		t.Errorf("rates = %v %+v", rates.Labels, rates.Datasets)
+	}
+}
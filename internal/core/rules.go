@@ -0,0 +1,169 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ContentRule keeps some of a site's items out. A site's items are kept unless
+// a block rule matches them, and, if the site has any allow rules, only when
+// one of those matches too. Block wins over allow.
+type ContentRule struct {
+	// Action is RuleBlock or RuleAllow.
+	Action string `json:"action"`
+	// Field is what Pattern is matched against: one of RuleFields.
+	Field string `json:"field"`
+	// Pattern is a path prefix for RuleLinkPath, such as "/annoncer/", and a
+	// regular expression for the other fields. Categories and authors match if
+	// any one of an item's does. Add (?i) to match regardless of case.
+	Pattern string `json:"pattern"`
+}
+
+// The actions a rule can take.
+const (
+	RuleBlock = "block"
+	RuleAllow = "allow"
+)
+
+// The fields of an item a rule can match.
+const (
+	RuleTitle    = "title"
+	RuleLinkPath = "path"
+	RuleCategory = "category"
+	RuleAuthor   = "author"
+)
+
+var RuleActions = []string{RuleBlock, RuleAllow}
+
+var RuleFields = []string{RuleTitle, RuleLinkPath, RuleCategory, RuleAuthor}
+
+// ParseContentRule reads a rule written the way String writes it: the action,
+// the field and the pattern, separated by whitespace, as in
+// "block title ^Quiz:". The pattern is the rest of the line and may contain
+// spaces. The rule is not checked; what is missing is left empty, for
+// CompileRules or Matches to report.
+func ParseContentRule(text string) ContentRule {
+	var parts [3]string
+	rest := strings.TrimSpace(text)
+	for i := range 2 {
+		word, after, _ := strings.Cut(rest, " ")
+		parts[i] = strings.ToLower(word)
+		rest = strings.TrimSpace(after)
+	}
+	parts[2] = rest
+	return ContentRule{Action: parts[0], Field: parts[1], Pattern: parts[2]}
+}
+
+func (c ContentRule) String() string {
+	return strings.TrimSpace(c.Action + " " + c.Field + " " + c.Pattern)
+}
+
+// Matches reports whether the rule's pattern matches item, whatever its action.
+func (c ContentRule) Matches(item RssItemDto) (bool, error) {
+	compiled, err := c.compile()
+	if err != nil {
+		return false, err
+	}
+	return compiled.matches(item), nil
+}
+
+// compiledRule is a rule with its regular expression compiled, which sites do
+// once when they are loaded rather than for every item.
+type compiledRule struct {
+	ContentRule
+	re *regexp.Regexp
+}
+
+func (c ContentRule) compile() (compiledRule, error) {
+	if !slices.Contains(RuleActions, c.Action) {
+		return compiledRule{}, fmt.Errorf("rule %q has action %q, which is not one of %v", c, c.Action, RuleActions)
+	}
+	if !slices.Contains(RuleFields, c.Field) {
+		return compiledRule{}, fmt.Errorf("rule %q has field %q, which is not one of %v", c, c.Field, RuleFields)
+	}
+	if c.Pattern == "" {
+		return compiledRule{}, fmt.Errorf("rule %q has no pattern", c)
+	}
+	if c.Field == RuleLinkPath {
+		if !strings.HasPrefix(c.Pattern, "/") {
+			return compiledRule{}, fmt.Errorf("rule %q has path %q, which does not start with /", c, c.Pattern)
+		}
+		return compiledRule{ContentRule: c}, nil
+	}
+	re, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return compiledRule{}, fmt.Errorf("error compiling pattern '%v': %w", c.Pattern, err)
+	}
+	return compiledRule{ContentRule: c, re: re}, nil
+}
+
+func (c compiledRule) matches(item RssItemDto) bool {
+	switch c.Field {
+	case RuleTitle:
+		return c.re.MatchString(strings.TrimSpace(item.Title))
+	case RuleLinkPath:
+		link, err := url.Parse(item.Link)
+		return err == nil && strings.HasPrefix(link.Path, c.Pattern)
+	case RuleCategory:
+		return c.matchesAny(item.Categories)
+	case RuleAuthor:
+		return c.matchesAny(item.Authors)
+	}
+	return false
+}
+
+func (c compiledRule) matchesAny(values []string) bool {
+	for _, value := range values {
+		if c.re.MatchString(strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompileRules compiles the site's content rules, so that Blocks does not have
+// to on every call. The repository does it as it loads sites; a site that was
+// never compiled, such as one built from a form, compiles on each call instead.
+func (n *NewsSite) CompileRules() error {
+	compiled := make([]compiledRule, 0, len(n.ContentRules))
+	var errs []error
+	for _, rule := range n.ContentRules {
+		c, err := rule.compile()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	n.compiledRules = &compiled
+	return nil
+}
+
+// Blocks reports whether the site's content rules keep item out.
+func (n NewsSite) Blocks(item RssItemDto) (bool, error) {
+	if n.compiledRules == nil {
+		if err := n.CompileRules(); err != nil {
+			return false, err
+		}
+	}
+	allowed, hasAllowRules := false, false
+	for _, rule := range *n.compiledRules {
+		switch rule.Action {
+		case RuleBlock:
+			if rule.matches(item) {
+				return true, nil
+			}
+		case RuleAllow:
+			hasAllowRules = true
+			allowed = allowed || rule.matches(item)
+		}
+	}
+	return hasAllowRules && !allowed, nil
+}
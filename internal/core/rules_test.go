@@ -0,0 +1,66 @@
+package core
+
+import "testing"
+
+func TestContentRules(t *testing.T) {
+	t.Parallel()
+
+	quiz := RssItemDto{Title: "Quiz: Kender du ugens nyheder?", Link: "https://example.org/quiz/123"}
+	ad := RssItemDto{Title: "Spar 50 %", Link: "https://example.org/annoncer/spar?utm_source=rss", Categories: []string{"Annonce"}}
+	sport := RssItemDto{Title: "Sejr i Parken", Link: "https://example.org/sport/1", Categories: []string{"Sport", "Fodbold"}, Authors: []string{"Ritzau"}}
+	news := RssItemDto{Title: "Regeringen falder", Link: "https://example.org/politik/2", Authors: []string{"Jens Jensen"}}
+
+	var tests = []struct {
+		name    string
+		rules   []string
+		blocked []RssItemDto
+		kept    []RssItemDto
+	}{
+		{"no rules", nil, nil, []RssItemDto{quiz, ad, sport, news}},
+		{"block title", []string{"block title ^Quiz:"}, []RssItemDto{quiz}, []RssItemDto{ad, sport, news}},
+		{"block path", []string{"block path /annoncer/"}, []RssItemDto{ad}, []RssItemDto{quiz, sport, news}},
+		{"block category", []string{"block category (?i)^annonce$"}, []RssItemDto{ad}, []RssItemDto{quiz, sport, news}},
+		{"block author", []string{"block author ^Ritzau$"}, []RssItemDto{sport}, []RssItemDto{quiz, ad, news}},
+		{"allow only", []string{"allow category ^Sport$", "allow path /politik/"}, []RssItemDto{quiz, ad}, []RssItemDto{sport, news}},
+		{"block wins", []string{"allow category ^Sport$", "block author Ritzau"}, []RssItemDto{quiz, ad, sport, news}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := NewsSite{Name: "Test site", Language: "da"}
+			for _, text := range tt.rules {
+				site.ContentRules = append(site.ContentRules, ParseContentRule(text))
+			}
+			if err := site.CompileRules(); err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			for _, item := range tt.blocked {
+				if blocked, err := site.Blocks(item); err != nil || !blocked {
+					t.Errorf("Blocks(%q) = %v, %v, want blocked", item.Title, blocked, err)
+				}
+			}
+			for _, item := range tt.kept {
+				if blocked, err := site.Blocks(item); err != nil || blocked {
+					t.Errorf("Blocks(%q) = %v, %v, want kept", item.Title, blocked, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseContentRule(t *testing.T) {
+	t.Parallel()
+
+	rule := ParseContentRule("  Block  title  ^Live: følg med  ")
+	want := ContentRule{Action: RuleBlock, Field: RuleTitle, Pattern: "^Live: følg med"}
+	if rule != want {
+		t.Errorf("got %+v, want %+v", rule, want)
+	}
+	if parsed := ParseContentRule(rule.String()); parsed != rule {
+		t.Errorf("round trip of %q = %+v", rule, parsed)
+	}
+	for _, text := range []string{"block title", "hide title x", "block body x", "block title (", "block path annoncer/"} {
+		if _, err := ParseContentRule(text).Matches(RssItemDto{}); err == nil {
+			t.Errorf("rule %q matched, want an error", text)
+		}
+	}
+}
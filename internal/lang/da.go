@@ -28,36 +28,106 @@ var danishTimeAgo = timeago.Config{
 }
 
 var daMsgs = map[string]string{
-	"brand":         "Rasende",
-	"nav.search":    "Søg",
-	"nav.fakeNews":  "Fake News",
-	"flash.close":   "Luk",
-	"footer.login":  "Login",
-	"footer.logout": "Logout",
+	"brand":           "Rasende",
+	"nav.search":      "Søg",
+	"nav.leaderboard": "Rasende-indekset",
+	"nav.fakeNews":    "Fake News",
+	"nav.admin":       "Admin",
+	"flash.close":     "Luk",
+	"footer.login":    "Login",
+	"footer.logout":   "Logout",
+
+	"flash.opmlImported":   "Importerede %d kladder og sprang %d over, der allerede er på listen",
+	"flash.opmlUnanalyzed": "Ingen analyzer til sproget for: %s. Ret det, før de slås til.",
 
 	"page.index":            "Raseri i de danske medier",
 	"page.search":           "Søg | Rasende",
+	"page.leaderboard":      "Rasende-indekset | Rasende",
 	"page.fakeNews":         "Fake News | Rasende",
 	"page.fakeNewsArticle":  "Fake News | Rasende",
 	"page.titleGenerator":   "Overskriftsgenerator | Rasende",
 	"page.articleGenerator": "Artikelgenerator | Rasende",
 	"page.login":            "Login | Rasende",
 	"page.error":            "Fejl | Rasende",
+	"page.adminFeeds":       "Feeds | Rasende",
+	"page.adminSites":       "Medier | Rasende",
+	"page.adminSite":        "Medie | Rasende",
+	"page.item":             "%v | Rasende",
 
 	"index.latest":  "Seneste raseri:",
 	"index.none":    "Ingen raseri!",
 	"index.earlier": "Tidligere raserier:",
 	"footer.credit": "Inspireret af",
 
+	"item.by":          "Af",
+	"item.history":     "Historik",
+	"item.noRevisions": "Overskriften er ikke ændret, siden vi så den første gang.",
+	"item.read":        "Læs hos mediet",
+	"item.content":     "Indhold",
+	"item.duplicates":  "+%v dubletter",
+
 	"search.content":  "Søg i artikel indhold",
 	"search.loadMore": "Hent flere",
-
-	"chart.line.title":        "Den seneste uges raserier",
-	"chart.line.dataset":      "Raseriudbrud",
-	"chart.pie.title":         "Raseri i de forskellige medier",
-	"chart.line.titleQuery":   "Den seneste uges brug af '%v'",
-	"chart.line.datasetQuery": "Antal '%v'",
-	"chart.pie.titleQuery":    "Brug af '%v' i de forskellige medier",
+	"search.category": "Kategori, fx sport",
+	"search.collapse": "Skjul dubletter",
+	"search.syntax":   "Brug \"anførselstegn\" til en frase, AND, OR og NOT, rase* til et præfiks, og title:, site:BT, after:2024-03-01 og before:2024-04-01",
+
+	"leaderboard.heading":  "Rasende-indekset",
+	"leaderboard.intro":    "Hvor mange af hver 1.000 artikler hvert medie er rasende i. Medier med færre end %v artikler i perioden er ikke med.",
+	"leaderboard.show":     "Vis",
+	"leaderboard.rank":     "#",
+	"leaderboard.site":     "Medie",
+	"leaderboard.rate":     "Per 1.000 artikler",
+	"leaderboard.matches":  "Raserier",
+	"leaderboard.articles": "Artikler",
+	"leaderboard.none":     "Intet medie udgav artikler nok i perioden.",
+
+	"search.compare":      "Sammenlign med, fx vred, harme",
+	"search.range":        "Grafens periode",
+	"search.range.7d":     "Seneste 7 dage",
+	"search.range.30d":    "Seneste 30 dage",
+	"search.range.90d":    "Seneste 90 dage",
+	"search.range.1y":     "Seneste år",
+	"search.range.all":    "Hele tiden",
+	"search.range.custom": "Fra og til",
+	"search.bucket":       "Tæl per",
+	"search.bucket.hour":  "Per time",
+	"search.bucket.day":   "Per dag",
+	"search.bucket.week":  "Per uge",
+	"search.bucket.month": "Per måned",
+	"search.start":        "Fra",
+	"search.end":          "Til",
+
+	"chart.line.title":         "Den seneste uges raserier",
+	"chart.line.dataset":       "Raseriudbrud",
+	"chart.pie.title":          "Raseri i de forskellige medier",
+	"chart.line.titleQuery":    "Den seneste uges brug af '%v'",
+	"chart.line.datasetQuery":  "Antal '%v'",
+	"chart.pie.titleQuery":     "Brug af '%v' i de forskellige medier",
+	"chart.line.titleCategory": "Den seneste uges brug af '%v' i kategorien '%v'",
+	"chart.pie.titleCategory":  "Brug af '%v' i kategorien '%v' i de forskellige medier",
+
+	"chart.bar.title":         "Raserier per 1.000 artikler",
+	"chart.bar.titleQuery":    "Brug af '%v' per 1.000 artikler",
+	"chart.bar.titleCategory": "Brug af '%v' i kategorien '%v' per 1.000 artikler",
+	"chart.bar.dataset":       "Per 1.000 artikler",
+
+	"chart.line.titleRange":         "Raserier %v",
+	"chart.line.titleQueryRange":    "Brug af '%v' %v",
+	"chart.line.titleCategoryRange": "Brug af '%v' i kategorien '%v' %v",
+
+	"chart.compare.list":           "%v og %v",
+	"chart.compare.inCategory":     "%v i kategorien '%v'",
+	"chart.line.titleCompare":      "Den seneste uges brug af %v",
+	"chart.line.titleCompareRange": "Brug af %v %v",
+	"chart.sites.titleCompare":     "Brug af %v i de forskellige medier",
+	"chart.bar.titleCompare":       "Brug af %v per 1.000 artikler",
+
+	"chart.range.30d":    "de seneste 30 dage",
+	"chart.range.90d":    "de seneste 90 dage",
+	"chart.range.1y":     "det seneste år",
+	"chart.range.all":    "gennem tiden",
+	"chart.range.custom": "fra %v til %v",
 
 	"fakeNews.heading": "Falske Nyheder",
 	"fakeNews.create":  "Opret en falsk nyhed",
@@ -80,11 +150,81 @@ var daMsgs = map[string]string{
 	"admin.resetContent":     "Nulstil indhold",
 	"admin.articleGenerator": "Artikelgenerator",
 
+	"admin.nav.feeds":         "Feeds",
+	"admin.nav.sites":         "Medier",
+	"admin.feeds.heading":     "Feeds",
+	"admin.feeds.site":        "Site",
+	"admin.feeds.url":         "URL",
+	"admin.feeds.lastSuccess": "Seneste succes",
+	"admin.feeds.failures":    "Fejl i træk",
+	"admin.feeds.lastError":   "Seneste fejl",
+	"admin.feeds.nextFetch":   "Næste hentning",
+	"admin.feeds.never":       "Aldrig",
+	"admin.feeds.nextRun":     "Næste kørsel",
+
+	"admin.sites.heading":                "Medier",
+	"admin.sites.new":                    "Nyt medie",
+	"admin.sites.id":                     "Id",
+	"admin.sites.name":                   "Navn",
+	"admin.sites.language":               "Sprog",
+	"admin.sites.sourceType":             "Kildetype",
+	"admin.sites.urls":                   "URL'er på feeds eller forsider, én per linje",
+	"admin.sites.htmlSource":             "Forsider (kildetype html)",
+	"admin.sites.htmlItemSelector":       "CSS-selektor for hver overskrift",
+	"admin.sites.htmlTitleSelector":      "CSS-selektor for titlen i en overskrift; tom tager linkets tekst",
+	"admin.sites.htmlLinkSelector":       "CSS-selektor for linket i en overskrift; tom tager det første link",
+	"admin.sites.htmlTimeSelector":       "CSS-selektor for tidspunktet i en overskrift; tom daterer artikler, når de ses første gang",
+	"admin.sites.htmlTimeLayout":         "Go-tidslayout for tidspunktet; tom betyder RFC 3339",
+	"admin.sites.description":            "Beskrivelse, på engelsk",
+	"admin.sites.userAgentKey":           "User agent-nøgle",
+	"admin.sites.contentRules":           "Indholdsregler, én per linje: block eller allow, så title, path, category eller author, så et regulært udtryk, eller for path et præfiks",
+	"admin.sites.contentRetentionMonths": "Måneder artiklerne beholder deres indhold: 0 følger den globale indstilling, -1 beholder det for altid",
+	"admin.sites.opmlExport":             "Eksportér som OPML",
+	"admin.sites.opmlImport":             "Importér feeds fra en OPML-fil, eller sites fra en JSON-fil, som kladder",
+	"admin.sites.opmlImportButton":       "Importér",
+	"admin.sites.draft":                  "Kladde",
+	"admin.sites.noAnalyzer":             "Ingen analyzer",
+	"admin.sites.tryRule":                "Prøv en regel på de gemte artikler",
+	"admin.sites.rule":                   "Regel",
+	"admin.sites.ruleDryRun":             "Vis artikler, reglen holder ude",
+	"admin.sites.rulePurge":              "Slet artikler, reglen holder ude",
+	"admin.sites.rulePurgeConfirm":       "Slet alle sidens gemte artikler, som reglen holder ude? Det kan ikke fortrydes.",
+	"admin.sites.rulePurged":             "Slettede %d artikler",
+	"admin.sites.ruleMatches":            "Reglen holder %d gemte artikler ude",
+	"admin.sites.ruleNoMatches":          "Reglen holder ingen gemte artikler ude",
+	"admin.sites.articleSelector":        "CSS-selektor for artiklens brødtekst, for at hente hele artikler",
+	"admin.sites.articleExcludeSelector": "CSS-selektor for dele af brødteksten, der skal udelades",
+	"admin.sites.articleHasContent":      "Feedet indeholder hele artiklen",
+	"admin.sites.noAutoGenerate":         "Ingen automatiske falske nyheder",
+	"admin.sites.disabled":               "Slået fra",
+	"admin.sites.disable":                "Slå fra",
+	"admin.sites.enable":                 "Slå til",
+	"admin.sites.save":                   "Gem",
+	"admin.sites.testFetch":              "Prøvehent",
+	"admin.sites.testUrl":                "Feed-URL",
+	"admin.sites.itemTitle":              "Overskrift",
+	"admin.sites.itemPublished":          "Udgivet",
+	"admin.sites.itemContent":            "Indhold",
+	"admin.sites.blocked":                "Blokeret af en indholdsregel",
+	"admin.sites.noItems":                "Feedet har ingen artikler",
+
 	"error.prefix":        "Fejl:",
 	"error.unknown":       "ukendt fejl",
 	"error.requiresAdmin": "Kræver admin",
 	"error.tryAgainLater": "Prøv igen senere",
 
+	"error.chartPeriod":           "Vælg en start- og en slutdato, slutdatoen samme dag som starten eller senere",
+	"error.query.noTerms":         "Skriv et ord at søge efter; filtre som site: indsnævrer kun en søgning",
+	"error.query.unclosedQuote":   "Et anførselstegn er ikke lukket",
+	"error.query.unclosedParen":   "En parentes er ikke lukket",
+	"error.query.unexpectedParen": "En slutparentes mangler sin startparentes",
+	"error.query.missingOperand":  "%v skal have et søgeord på begge sider, som i: rasende %v borgere",
+	"error.query.shortPrefix":     "%v er for kort; et præfiks skal have mindst %d bogstaver",
+	"error.query.filterValue":     "%v skal have en værdi lige efter sig, som i site:BT",
+	"error.query.nestedFilter":    "%v gælder hele søgningen, så det kan ikke stå i en parentes eller efter AND eller NOT",
+	"error.query.invalidDate":     "%v er ikke en dato; skriv den som 2024-03-01",
+	"error.query.unknownSite":     "Der er ingen side, der hedder %q",
+
 	"auth.invalidEmail": "Ugyldig email",
 	"auth.userNotFound": "Bruger ikke fundet. Registrering er deaktiveret.",
 	"auth.badCode":      "Koden virker ikke",
@@ -1,36 +1,106 @@
 package lang
 
 var enMsgs = map[string]string{
-	"brand":         "Outrage",
-	"nav.search":    "Search",
-	"nav.fakeNews":  "Fake News",
-	"flash.close":   "Close",
-	"footer.login":  "Login",
-	"footer.logout": "Logout",
+	"brand":           "Outrage",
+	"nav.search":      "Search",
+	"nav.leaderboard": "Outrage index",
+	"nav.fakeNews":    "Fake News",
+	"nav.admin":       "Admin",
+	"flash.close":     "Close",
+	"footer.login":    "Login",
+	"footer.logout":   "Logout",
+
+	"flash.opmlImported":   "Imported %d draft sites, and skipped %d already on the list",
+	"flash.opmlUnanalyzed": "No analyzer for the language of: %s. Change it before enabling them.",
 
 	"page.index":            "Outrage in the media",
 	"page.search":           "Search | Outrage",
+	"page.leaderboard":      "Outrage index | Outrage",
 	"page.fakeNews":         "Fake News | Outrage",
 	"page.fakeNewsArticle":  "Fake News | Outrage",
 	"page.titleGenerator":   "Title Generator | Outrage",
 	"page.articleGenerator": "Article Generator | Outrage",
 	"page.login":            "Login | Outrage",
 	"page.error":            "Error | Outrage",
+	"page.adminFeeds":       "Feeds | Outrage",
+	"page.adminSites":       "Sites | Outrage",
+	"page.adminSite":        "Site | Outrage",
+	"page.item":             "%v | Outrage",
 
 	"index.latest":  "Latest outrage:",
 	"index.none":    "No outrage!",
 	"index.earlier": "Earlier outrages:",
 	"footer.credit": "Inspired by",
 
+	"item.by":          "By",
+	"item.history":     "History",
+	"item.noRevisions": "The headline has not changed since we first saw it.",
+	"item.read":        "Read it at the outlet",
+	"item.content":     "Content",
+	"item.duplicates":  "+%v duplicates",
+
 	"search.content":  "Search article content",
 	"search.loadMore": "Load more",
-
-	"chart.line.title":        "This week's outrages",
-	"chart.line.dataset":      "Outbursts",
-	"chart.pie.title":         "Outrage across the media",
-	"chart.line.titleQuery":   "This week's use of '%v'",
-	"chart.line.datasetQuery": "Number of '%v'",
-	"chart.pie.titleQuery":    "Use of '%v' across the media",
+	"search.category": "Category, e.g. sport",
+	"search.collapse": "Hide duplicates",
+	"search.syntax":   "Use \"quotes\" for a phrase, AND, OR and NOT, rase* for a prefix, and title:, site:BT, after:2024-03-01 and before:2024-04-01",
+
+	"leaderboard.heading":  "The outrage index",
+	"leaderboard.intro":    "How many of every 1,000 articles each outlet is outraged in. Outlets with fewer than %v articles in the period are left out.",
+	"leaderboard.show":     "Show",
+	"leaderboard.rank":     "#",
+	"leaderboard.site":     "Outlet",
+	"leaderboard.rate":     "Per 1,000 articles",
+	"leaderboard.matches":  "Outrages",
+	"leaderboard.articles": "Articles",
+	"leaderboard.none":     "No outlet published enough articles in the period.",
+
+	"search.compare":      "Compare with, e.g. fury, anger",
+	"search.range":        "Chart period",
+	"search.range.7d":     "Last 7 days",
+	"search.range.30d":    "Last 30 days",
+	"search.range.90d":    "Last 90 days",
+	"search.range.1y":     "Last year",
+	"search.range.all":    "All time",
+	"search.range.custom": "From and to",
+	"search.bucket":       "Count per",
+	"search.bucket.hour":  "Per hour",
+	"search.bucket.day":   "Per day",
+	"search.bucket.week":  "Per week",
+	"search.bucket.month": "Per month",
+	"search.start":        "From",
+	"search.end":          "To",
+
+	"chart.line.title":         "This week's outrages",
+	"chart.line.dataset":       "Outbursts",
+	"chart.pie.title":          "Outrage across the media",
+	"chart.line.titleQuery":    "This week's use of '%v'",
+	"chart.line.datasetQuery":  "Number of '%v'",
+	"chart.pie.titleQuery":     "Use of '%v' across the media",
+	"chart.line.titleCategory": "This week's use of '%v' in the category '%v'",
+	"chart.pie.titleCategory":  "Use of '%v' in the category '%v' across the media",
+
+	"chart.bar.title":         "Outrages per 1,000 articles",
+	"chart.bar.titleQuery":    "Use of '%v' per 1,000 articles",
+	"chart.bar.titleCategory": "Use of '%v' in the category '%v' per 1,000 articles",
+	"chart.bar.dataset":       "Per 1,000 articles",
+
+	"chart.line.titleRange":         "Outrages %v",
+	"chart.line.titleQueryRange":    "Use of '%v' %v",
+	"chart.line.titleCategoryRange": "Use of '%v' in the category '%v' %v",
+
+	"chart.compare.list":           "%v and %v",
+	"chart.compare.inCategory":     "%v in the category '%v'",
+	"chart.line.titleCompare":      "This week's use of %v",
+	"chart.line.titleCompareRange": "Use of %v %v",
+	"chart.sites.titleCompare":     "Use of %v across the media",
+	"chart.bar.titleCompare":       "Use of %v per 1,000 articles",
+
+	"chart.range.30d":    "over the last 30 days",
+	"chart.range.90d":    "over the last 90 days",
+	"chart.range.1y":     "over the last year",
+	"chart.range.all":    "over all time",
+	"chart.range.custom": "from %v to %v",
 
 	"fakeNews.heading": "Fake News",
 	"fakeNews.create":  "Create a fake news article",
@@ -53,11 +123,81 @@ var enMsgs = map[string]string{
 	"admin.resetContent":     "Reset content",
 	"admin.articleGenerator": "Article generator",
 
+	"admin.nav.feeds":         "Feeds",
+	"admin.nav.sites":         "Sites",
+	"admin.feeds.heading":     "Feeds",
+	"admin.feeds.site":        "Site",
+	"admin.feeds.url":         "URL",
+	"admin.feeds.lastSuccess": "Last success",
+	"admin.feeds.failures":    "Failures in a row",
+	"admin.feeds.lastError":   "Last error",
+	"admin.feeds.nextFetch":   "Next fetch",
+	"admin.feeds.never":       "Never",
+	"admin.feeds.nextRun":     "Next run",
+
+	"admin.sites.heading":                "Sites",
+	"admin.sites.new":                    "New site",
+	"admin.sites.id":                     "Id",
+	"admin.sites.name":                   "Name",
+	"admin.sites.language":               "Language",
+	"admin.sites.sourceType":             "Source type",
+	"admin.sites.urls":                   "URLs of the feeds or listing pages, one per line",
+	"admin.sites.htmlSource":             "Listing pages (source type html)",
+	"admin.sites.htmlItemSelector":       "CSS selector for each headline",
+	"admin.sites.htmlTitleSelector":      "CSS selector for the title within a headline; empty takes the text of the link",
+	"admin.sites.htmlLinkSelector":       "CSS selector for the link within a headline; empty takes the first link",
+	"admin.sites.htmlTimeSelector":       "CSS selector for the time within a headline; empty dates items when first seen",
+	"admin.sites.htmlTimeLayout":         "Go time layout of the time; empty means RFC 3339",
+	"admin.sites.description":            "Description, in English",
+	"admin.sites.userAgentKey":           "User agent key",
+	"admin.sites.contentRules":           "Content rules, one per line: block or allow, then title, path, category or author, then a regular expression, or for path a prefix",
+	"admin.sites.contentRetentionMonths": "Months items keep their content: 0 follows the global setting, -1 keeps it forever",
+	"admin.sites.opmlExport":             "Export as OPML",
+	"admin.sites.opmlImport":             "Import feeds from an OPML file, or sites from a JSON file, as draft sites",
+	"admin.sites.opmlImportButton":       "Import",
+	"admin.sites.draft":                  "Draft",
+	"admin.sites.noAnalyzer":             "No analyzer",
+	"admin.sites.tryRule":                "Try a rule on the stored items",
+	"admin.sites.rule":                   "Rule",
+	"admin.sites.ruleDryRun":             "Show the items the rule keeps out",
+	"admin.sites.rulePurge":              "Delete the items the rule keeps out",
+	"admin.sites.rulePurgeConfirm":       "Delete every stored item of the site that the rule keeps out? This cannot be undone.",
+	"admin.sites.rulePurged":             "Deleted %d items",
+	"admin.sites.ruleMatches":            "The rule keeps out %d stored items",
+	"admin.sites.ruleNoMatches":          "The rule keeps out none of the stored items",
+	"admin.sites.articleSelector":        "CSS selector for the article body, to fetch whole articles",
+	"admin.sites.articleExcludeSelector": "CSS selector for parts of the body to leave out",
+	"admin.sites.articleHasContent":      "Feed items carry the whole article",
+	"admin.sites.noAutoGenerate":         "No automatic fake news",
+	"admin.sites.disabled":               "Disabled",
+	"admin.sites.disable":                "Disable",
+	"admin.sites.enable":                 "Enable",
+	"admin.sites.save":                   "Save",
+	"admin.sites.testFetch":              "Test fetch",
+	"admin.sites.testUrl":                "Feed URL",
+	"admin.sites.itemTitle":              "Title",
+	"admin.sites.itemPublished":          "Published",
+	"admin.sites.itemContent":            "Content",
+	"admin.sites.blocked":                "Blocked by a content rule",
+	"admin.sites.noItems":                "The feed has no items",
+
 	"error.prefix":        "Error:",
 	"error.unknown":       "unknown error",
 	"error.requiresAdmin": "Requires admin",
 	"error.tryAgainLater": "Try again later",
 
+	"error.chartPeriod":           "Pick a start and an end date, the end on or after the start",
+	"error.query.noTerms":         "Add a word to search for; filters like site: only narrow a search",
+	"error.query.unclosedQuote":   "A quotation mark is not closed",
+	"error.query.unclosedParen":   "A parenthesis is not closed",
+	"error.query.unexpectedParen": "A closing parenthesis has no opening one",
+	"error.query.missingOperand":  "%v needs a search term on both sides, as in: rasende %v borgere",
+	"error.query.shortPrefix":     "%v is too short; a prefix needs at least %d letters",
+	"error.query.filterValue":     "%v needs a value right after it, as in site:BT",
+	"error.query.nestedFilter":    "%v applies to the whole search, so it cannot be inside parentheses or after AND or NOT",
+	"error.query.invalidDate":     "%v is not a date; write it as 2024-03-01",
+	"error.query.unknownSite":     "There is no site called %q",
+
 	"auth.invalidEmail": "Invalid email",
 	"auth.userNotFound": "User not found. Sign-up is disabled.",
 	"auth.badCode":      "That code does not work",
@@ -16,6 +16,10 @@ package lang
 
 import (
 	"fmt"
+	"time"
+	// The zones are embedded so an edition's calendar never depends on the
+	// tzdata of the machine it runs on.
+	_ "time/tzdata"
 
 	"github.com/xeonx/timeago"
 )
@@ -51,6 +55,11 @@ type Lang struct {
 	// TimeAgo formats "3 hours ago" on the index page.
 	TimeAgo timeago.Config
 
+	// Location is the edition's IANA time zone, the one its readers live in.
+	// The charts count days, weeks and months on its calendar, so a headline
+	// from ten past midnight in Copenhagen counts on the day it was read.
+	Location *time.Location
+
 	msgs map[string]string
 }
 
@@ -83,6 +92,7 @@ var danish = Lang{
 	Endonym:      "Dansk",
 	DefaultQuery: "rasende",
 	TimeAgo:      danishTimeAgo,
+	Location:     mustLoadLocation("Europe/Copenhagen"),
 	msgs:         daMsgs,
 }
 
@@ -94,8 +104,17 @@ var english = Lang{
 	// timeago.English already carries the same Max (73h) and DefaultLayout
 	// ("2006-01-02") the Danish config sets, so the two editions agree on when
 	// to stop saying "ago" and print a date instead.
-	TimeAgo: timeago.English,
-	msgs:    enMsgs,
+	TimeAgo:  timeago.English,
+	Location: mustLoadLocation("Europe/London"),
+	msgs:     enMsgs,
+}
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(fmt.Sprintf("lang: unknown time zone %q: %v", name, err))
+	}
+	return loc
 }
 
 // All is every edition, in the order they appear in the language switcher.
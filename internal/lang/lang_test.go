@@ -3,6 +3,7 @@ package lang
 import (
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/bjarke-xyz/rasende2/internal/search"
 )
@@ -19,6 +20,16 @@ func TestEveryEditionHasAnAnalyzer(t *testing.T) {
 	}
 }
 
+// The charts count days on the edition's calendar, and an edition without a
+// zone would fall back to UTC's without anyone noticing.
+func TestEveryEditionHasATimeZone(t *testing.T) {
+	for _, l := range All {
+		if l.Location == nil || l.Location == time.UTC {
+			t.Errorf("edition %q has no time zone of its own", l.Code)
+		}
+	}
+}
+
 // Every edition must define every key. A key present in one catalog and missing
 // from another is invisible until someone loads that page in that language, and
 // T() would render the raw key into the page.
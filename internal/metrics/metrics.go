@@ -28,3 +28,6 @@ func AiCounterSelectTitleInc() {
 func AiCounterArticleContentInc() {
 	aiCounter.WithLabelValues("article_content").Inc()
 }
+func AiCounterSiteDescriptionInc() {
+	aiCounter.WithLabelValues("site_description").Inc()
+}
@@ -0,0 +1,169 @@
+package news
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/storage"
+)
+
+// archiveBatchSize is how many items are exported and stripped at a time. Each
+// batch is one file, written before any of its items lose their content.
+const archiveBatchSize = 500
+
+// archiveStore is where the archive job exports items before it strips them.
+type archiveStore interface {
+	// Put stores data under name, a slash-separated relative path.
+	Put(ctx context.Context, name string, data []byte) error
+}
+
+// dirArchive exports to a local directory.
+type dirArchive struct {
+	dir string
+}
+
+func (a *dirArchive) Put(ctx context.Context, name string, data []byte) error {
+	file := filepath.Join(a.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o644)
+}
+
+// bucketArchive exports to the image bucket, beside the fake news images.
+type bucketArchive struct {
+	client *s3.Client
+	bucket string
+}
+
+func (a *bucketArchive) Put(ctx context.Context, name string, data []byte) error {
+	key := "rasende2/archive/" + name
+	_, err := a.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &a.bucket,
+		Key:    &key,
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading %v: %w", key, err)
+	}
+	return nil
+}
+
+// newArchiveStore returns the store the config asks for: ArchiveDir if it is
+// set, else the image bucket if there is one, else nil, and then items are
+// stripped without being exported.
+func newArchiveStore(ctx context.Context, cfg *config.Config) (archiveStore, error) {
+	switch {
+	case cfg.ArchiveDir != "":
+		return &dirArchive{dir: cfg.ArchiveDir}, nil
+	case cfg.S3ImageBucket != "":
+		client, err := storage.NewImageClientFromConfig(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &bucketArchive{client: client, bucket: cfg.S3ImageBucket}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// ArchiveItems drops the content of the items that are past their site's
+// retention period, keeping their title, link and published time. Each batch is
+// first exported whole, as gzipped JSON Lines, so nothing is lost that was not
+// written somewhere first; a batch whose export fails is left as it is.
+func (r *RssService) ArchiveItems(ctx context.Context) error {
+	run, err := r.startJobRun(ctx, core.JobArchiveItems)
+	if err != nil {
+		return err
+	}
+	return run.finish(ctx, r.archiveItems(ctx, run, time.Now()))
+}
+
+func (r *RssService) archiveItems(ctx context.Context, run *jobRun, now time.Time) error {
+	sites, err := r.repository.GetSites(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get sites: %w", err)
+	}
+	store, err := newArchiveStore(ctx, r.context.Config)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	if store == nil {
+		slog.Warn("archive items: no ARCHIVE_DIR or image bucket, content is dropped without an export")
+	}
+	for _, site := range sites {
+		months := site.RetentionMonths(r.context.Config.ContentRetentionMonths)
+		if months == 0 {
+			continue
+		}
+		archived, err := r.archiveSiteItems(ctx, store, site, now.AddDate(0, -months, 0), now)
+		run.add("archivedItems", archived)
+		if err != nil {
+			return fmt.Errorf("failed to archive items of %v: %w", site.Name, err)
+		}
+	}
+	return nil
+}
+
+// archiveSiteItems archives the site's items published before cutoff, a batch
+// at a time, and returns how many it archived.
+func (r *RssService) archiveSiteItems(ctx context.Context, store archiveStore, site core.NewsSite, cutoff time.Time, now time.Time) (int, error) {
+	archived := 0
+	for batch := 0; ; batch++ {
+		if err := ctx.Err(); err != nil {
+			return archived, err
+		}
+		items, err := r.repository.GetItemsToArchive(ctx, site.Id, cutoff, archiveBatchSize)
+		if err != nil {
+			return archived, err
+		}
+		if len(items) == 0 {
+			return archived, nil
+		}
+		if store != nil {
+			data, err := encodeArchive(items)
+			if err != nil {
+				return archived, err
+			}
+			name := path.Join(siteDirName(site), fmt.Sprintf("%v-%03d.jsonl.gz", now.UTC().Format("20060102T150405Z"), batch))
+			if err := store.Put(ctx, name, data); err != nil {
+				return archived, fmt.Errorf("failed to export %v: %w", name, err)
+			}
+		}
+		itemIds := make([]string, len(items))
+		for i, item := range items {
+			itemIds[i] = item.ItemId
+		}
+		if err := r.repository.ArchiveItems(ctx, site, itemIds, now); err != nil {
+			return archived, err
+		}
+		archived += len(items)
+	}
+}
+
+// encodeArchive writes items as gzipped JSON Lines, one item per line.
+func encodeArchive(items []core.RssItemDto) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,108 @@
+package news
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+)
+
+// Items past the retention period are exported whole and then lose their
+// content, in the table and in the index; newer items are left alone.
+func TestArchiveItemsExportsThenStrips(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	service.context.Config.ContentRetentionMonths = 1
+	service.context.Config.ArchiveDir = dir
+	recent := item(t, "recent", "Frisk dessert", "En ny dessert.", time.Now().UTC().Format(time.RFC3339))
+	if _, err := service.repository.InsertItems(ctx, testSite, append(corpus(t), recent)); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	revised := corpus(t)[3]
+	revised.Content = "En rasende god dessert, nu med kirsebær."
+	if _, err := service.repository.ReviseItem(ctx, testSite, revised.ItemId, revised); err != nil {
+		t.Fatalf("revise: %v", err)
+	}
+
+	if err := service.ArchiveItems(ctx); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	results, err := service.search.Search(ctx, "da", "dessert", true, core.SearchFilter{}, nil, nil, "published", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got, want := itemIds(results), []string{"recent"}; !equal(got, want) {
+		t.Errorf("content search after archive = %v, want %v", got, want)
+	}
+	results, err = service.search.Search(ctx, "da", "rødgrød", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got, want := itemIds(results), []string{"d"}; !equal(got, want) {
+		t.Errorf("title search after archive = %v, want %v", got, want)
+	}
+	if stored, err := service.GetItem(ctx, "d"); err != nil || stored.Content != "" || stored.Title == "" {
+		t.Errorf("archived item = %+v, %v, want a title and no content", stored, err)
+	}
+	revisions, err := service.GetItemRevisions(ctx, "d")
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("revisions = %+v, %v, want two", revisions, err)
+	}
+	for _, revision := range revisions {
+		if revision.Content != "" || revision.Title == "" {
+			t.Errorf("archived revision = %+v, want a title and no content", revision)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, siteDirName(testSite), "*.jsonl.gz"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("exported files = %v, %v, want one", files, err)
+	}
+	exported := readArchive(t, files[0])
+	if len(exported) != 4 || exported[3].ItemId != "c" || exported[3].Content == "" {
+		t.Errorf("exported %+v, want the four old items, oldest first, with content", exported)
+	}
+
+	// A second run finds nothing left to do.
+	if err := service.ArchiveItems(ctx); err != nil {
+		t.Fatalf("archive again: %v", err)
+	}
+	runs, err := service.GetJobRuns(ctx, 1)
+	if err != nil || len(runs) != 1 || runs[0].Counters["archivedItems"] != 0 {
+		t.Errorf("second run = %+v, %v, want nothing archived", runs, err)
+	}
+}
+
+func readArchive(t *testing.T, file string) []core.RssItemDto {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gunzip archive: %v", err)
+	}
+	items := make([]core.RssItemDto, 0)
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var item core.RssItemDto
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("decode archive line: %v", err)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	return items
+}
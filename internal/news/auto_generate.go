@@ -0,0 +1,126 @@
+package news
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/lang"
+	"github.com/bjarke-xyz/rasende2/pkg"
+)
+
+// AutoGenerateFakeNews writes and publishes one fake news article for a random
+// site. It is what both the scheduler and /api/admin/auto-generate-fake-news run.
+func (r *RssService) AutoGenerateFakeNews(ctx context.Context) (*core.FakeNewsDto, error) {
+	run, err := r.startJobRun(ctx, core.JobAutoGenerateFakeNews)
+	if err != nil {
+		return nil, err
+	}
+	createdFakeNews, err := r.autoGenerateFakeNews(ctx)
+	return createdFakeNews, run.finish(ctx, err)
+}
+
+func (r *RssService) autoGenerateFakeNews(ctx context.Context) (*core.FakeNewsDto, error) {
+	aiClient := r.context.Deps.AiClient
+	// The job generates for every edition, not just one, so it samples across
+	// all of them. Each site carries its own language, and that is what decides
+	// the language the article comes back in.
+	allSites := make([]core.NewsSite, 0)
+	for _, l := range lang.All {
+		sites, err := r.GetSiteInfos(ctx, l)
+		if err != nil {
+			return nil, fmt.Errorf("getting site infos failed: %w", err)
+		}
+		allSites = append(allSites, sites...)
+	}
+	latestFakeNews, err := r.GetRecentFakeNews(ctx, 3, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting recent fake news failed: %w", err)
+	}
+	latestFakeNewsSites := make(map[int]any, len(latestFakeNews))
+	for _, fn := range latestFakeNews {
+		latestFakeNewsSites[fn.SiteId] = struct{}{}
+	}
+	sites := make([]core.NewsSite, 0)
+	for _, site := range allSites {
+		if site.Disabled {
+			continue
+		}
+		_, isInLatest := latestFakeNewsSites[site.Id]
+		if isInLatest {
+			continue
+		}
+		if site.NoAutoGenerate {
+			continue
+		}
+		sites = append(sites, site)
+	}
+	if len(sites) == 0 {
+		return nil, errors.New("sites list was empty")
+	}
+	site := sites[rand.IntN(len(sites))]
+	recentArticleTitles, err := r.GetRecentTitles(ctx, site, 10, true)
+	if err != nil {
+		return nil, fmt.Errorf("getting recent article titles failed: %w", err)
+	}
+	var temperature float32 = 1
+	var generatedTitleCount = 30
+	generatedArticleTitles, err := aiClient.GenerateArticleTitlesList(ctx, site, recentArticleTitles, generatedTitleCount, temperature)
+	if err != nil {
+		return nil, fmt.Errorf("getting generated article titles failed: %w", err)
+	}
+	slog.Debug("generated titles", "titles", strings.Join(generatedArticleTitles, ", "))
+	selectedTitle, err := aiClient.SelectBestArticleTitle(ctx, site, generatedArticleTitles)
+	if err != nil {
+		return nil, fmt.Errorf("selecting best article title failed: %w", err)
+	}
+	slog.Debug("selected title", "title", selectedTitle)
+	externalId := pkg.NewID()
+	err = r.CreateFakeNews(ctx, site.Id, selectedTitle, externalId)
+	if err != nil {
+		return nil, fmt.Errorf("creating fake news failed: %w", err)
+	}
+
+	articleImgPromise := pkg.NewPromise(func() (string, error) {
+		imgUrl, err := aiClient.GenerateImage(ctx, site, selectedTitle, true)
+		if err != nil {
+			slog.Error("making fake news img failed", "error", err)
+		}
+		if imgUrl != "" {
+			r.SetFakeNewsImgUrl(ctx, site.Id, selectedTitle, imgUrl)
+		}
+		return imgUrl, err
+	})
+
+	articleContent, err := aiClient.GenerateArticleContentStr(ctx, site, selectedTitle, temperature)
+	if err != nil {
+		return nil, fmt.Errorf("generating article content failed: %w", err)
+	}
+
+	err = r.UpdateFakeNews(ctx, site.Id, selectedTitle, articleContent)
+	if err != nil {
+		return nil, fmt.Errorf("updating fake news failed: %w", err)
+	}
+
+	slog.Debug("waiting for img")
+	articleImgPromise.Get()
+	slog.Debug("img done")
+
+	err = r.SetFakeNewsHighlighted(ctx, site.Id, selectedTitle, true)
+	if err != nil {
+		return nil, fmt.Errorf("setting highlighted failed: %w", err)
+	}
+
+	createdFakeNews, err := r.GetFakeNews(ctx, externalId)
+	if err != nil {
+		return nil, fmt.Errorf("getting fake news failed: %w", err)
+	}
+	if createdFakeNews == nil {
+		return nil, errors.New("fake news was nil")
+	}
+	return createdFakeNews, nil
+}
@@ -0,0 +1,123 @@
+package news
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that only ever say how a reader
+// arrived, not what they are reading. Anything starting with
+// trackingParamPrefixes goes too.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"ocid":    true,
+	"cmpid":   true,
+	"_ga":     true,
+}
+
+var trackingParamPrefixes = []string{"utm_", "at_", "xtor"}
+
+// refererParams usually say where a reader came from too, but are generic
+// enough that some sites use them for what the page shows. They are only
+// dropped from canonicalLink, which no one is sent to.
+var refererParams = map[string]bool{
+	"ref":      true,
+	"referrer": true,
+	"rss":      true,
+	"src":      true,
+}
+
+// cleanLink drops the tracking parameters from a link, and leaves the rest of
+// it as it came: it is the link the reader is sent to, and a site may depend
+// on the order and escaping of its query. A link that is not an absolute
+// http(s) URL is returned as it was.
+func cleanLink(link string) string {
+	link = strings.TrimSpace(link)
+	if _, ok := parseLink(link); !ok {
+		return link
+	}
+	rest, fragment, hasFragment := strings.Cut(link, "#")
+	base, query, hasQuery := strings.Cut(rest, "?")
+	if !hasQuery {
+		return link
+	}
+	kept := make([]string, 0)
+	for _, param := range strings.Split(query, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil && isTrackingParam(unescaped) {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	if len(kept) > 0 {
+		base += "?" + strings.Join(kept, "&")
+	}
+	if hasFragment {
+		base += "#" + fragment
+	}
+	return base
+}
+
+// canonicalLink normalises a link, so that the copies of an article that feeds
+// hand out over http and https, with and without "www.", come out the same. It
+// loses its fragment, its userinfo and its tracking and referer parameters, and
+// the rest of its query is sorted. The scheme becomes https, the host loses its
+// case, a "www." and a default port, and the path a trailing slash. It is a key to
+// compare links by, not one to send readers to.
+func canonicalLink(link string) string {
+	parsed, ok := parseLink(link)
+	if !ok {
+		return link
+	}
+	parsed.User = nil
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	query := parsed.Query()
+	for name := range query {
+		if isTrackingParam(name) || refererParams[strings.ToLower(name)] {
+			query.Del(name)
+		}
+	}
+	// Encode sorts by name.
+	parsed.RawQuery = query.Encode()
+	parsed.Scheme = "https"
+	host := strings.ToLower(parsed.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	if port := parsed.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	parsed.Host = host
+	if len(parsed.Path) > 1 {
+		parsed.Path = strings.TrimRight(parsed.Path, "/")
+		parsed.RawPath = ""
+	}
+	return parsed.String()
+}
+
+// parseLink parses an absolute http(s) link.
+func parseLink(link string) (*url.URL, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, false
+	}
+	return parsed, true
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	if trackingParams[name] {
+		return true
+	}
+	for _, prefix := range trackingParamPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
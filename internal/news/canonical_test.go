@@ -0,0 +1,47 @@
+package news
+
+import "testing"
+
+func TestCanonicalLink(t *testing.T) {
+	for link, want := range map[string]string{
+		"https://www.dr.dk/nyheder/indland/artikel?utm_source=rss&utm_medium=feed": "https://dr.dk/nyheder/indland/artikel",
+		"http://DR.dk:80/nyheder/indland/artikel/#kommentarer":                     "https://dr.dk/nyheder/indland/artikel",
+		"https://dr.dk/artikel?side=2&id=7&fbclid=abc":                             "https://dr.dk/artikel?id=7&side=2",
+		"https://dr.dk/artikel?id=7&ref=forside&rss=1":                             "https://dr.dk/artikel?id=7",
+		"https://dr.dk:8443/artikel":                                               "https://dr.dk:8443/artikel",
+		"https://dr.dk/":                                                           "https://dr.dk/",
+		"urn:uuid:1234":                                                            "urn:uuid:1234",
+		"/relativ/artikel":                                                         "/relativ/artikel",
+	} {
+		if got := canonicalLink(link); got != want {
+			t.Errorf("canonicalLink(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
+
+// The link a reader is sent to keeps its scheme and host: a site that only
+// serves http would break if it were rewritten to https.
+func TestCleanLinkKeepsSchemeAndHost(t *testing.T) {
+	link := "http://www.Example.dk/artikel/?utm_campaign=x#top"
+	if got, want := cleanLink(link), "http://www.Example.dk/artikel/#top"; got != want {
+		t.Errorf("cleanLink(%q) = %q, want %q", link, got, want)
+	}
+}
+
+// Only the tracking parameters go; the rest of the link is as the feed gave
+// it, query order, escaping, generic names and all.
+func TestCleanLinkOnlyDropsTracking(t *testing.T) {
+	for link, want := range map[string]string{
+		"https://dr.dk/artikel?b=2&a=1":                            "https://dr.dk/artikel?b=2&a=1",
+		"https://dr.dk/artikel?id=1,2&utm_source=rss":              "https://dr.dk/artikel?id=1,2",
+		"https://dr.dk/artikel?utm_source=rss&utm_medium=feed":     "https://dr.dk/artikel",
+		"https://dr.dk/artikel?ref=forside&src=a&rss&fbclid=x#top": "https://dr.dk/artikel?ref=forside&src=a&rss#top",
+		"https://dr.dk/artikel?q=%C3%A6&GCLID=x":                   "https://dr.dk/artikel?q=%C3%A6",
+		"https://dr.dk/artikel":                                    "https://dr.dk/artikel",
+		"urn:uuid:1234?utm_source=rss":                             "urn:uuid:1234?utm_source=rss",
+	} {
+		if got := cleanLink(link); got != want {
+			t.Errorf("cleanLink(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
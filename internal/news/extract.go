@@ -0,0 +1,203 @@
+package news
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+)
+
+// Extraction fetches one article page per new item, and a feed can hand over
+// dozens of new items at once. Pages on the same host are fetched at least
+// articleHostInterval apart, so a site sees a reader rather than a burst.
+const (
+	articleHostInterval = 2 * time.Second
+	maxArticleBytes     = 5 << 20
+)
+
+// hostLimiter hands out request slots per host, interval apart. It is shared by
+// every site, because two sites can live on the same host.
+type hostLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     map[string]time.Time
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{interval: interval, next: make(map[string]time.Time)}
+}
+
+// wait blocks until host's next slot, or until ctx is done.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// extracts reports whether new items of site get their content from the
+// article page.
+func extracts(site core.NewsSite) bool {
+	return site.ArticleSelector != "" && !site.ArticleHasContent
+}
+
+// extractArticles replaces the content of items with the text of their article
+// pages, and records the canonical link a page names. An item whose page cannot
+// be fetched or holds nothing the selector matches keeps the content it came
+// with; that is logged, not returned, since the item itself is already safely
+// stored. It returns how many were extracted.
+func (r *RssService) extractArticles(ctx context.Context, site core.NewsSite, items []core.RssItemDto) int {
+	if !extracts(site) {
+		return 0
+	}
+	extracted := 0
+	for _, item := range items {
+		article, err := r.extractArticle(ctx, site, item.Link)
+		if ctx.Err() != nil {
+			return extracted
+		}
+		if err != nil {
+			slog.Warn("extracting article failed", "site", site.Name, "link", item.Link, "error", err)
+			continue
+		}
+		// The teaser is kept if the page had less to say than the feed did, which is
+		// what a selector that matches the wrong element tends to look like.
+		content := article.text
+		if len(content) <= len(item.Content) {
+			content = item.Content
+		}
+		canonical := ""
+		if article.canonicalLink != item.CanonicalLink {
+			canonical = article.canonicalLink
+		}
+		if content == item.Content && canonical == "" {
+			continue
+		}
+		if err := r.repository.UpdateItemContent(ctx, site, item.ItemId, content, canonical); err != nil {
+			slog.Error("saving extracted article failed", "site", site.Name, "link", item.Link, "error", err)
+			continue
+		}
+		if content != item.Content {
+			extracted++
+		}
+	}
+	return extracted
+}
+
+// article is what is extracted from an article page: the text of its body, and
+// the canonical link the page names for itself, or "".
+type article struct {
+	text          string
+	canonicalLink string
+}
+
+// extractArticle fetches the article at link and extracts it.
+func (r *RssService) extractArticle(ctx context.Context, site core.NewsSite, link string) (article, error) {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return article{}, fmt.Errorf("invalid link %q", link)
+	}
+	if err := r.articleLimiter.wait(ctx, parsed.Host); err != nil {
+		return article{}, err
+	}
+	resp, err := r.source.Fetch(ctx, site, link, core.FeedValidators{})
+	if err != nil {
+		return article{}, err
+	}
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(bytes.NewReader(resp.Body), maxArticleBytes))
+	if err != nil {
+		return article{}, fmt.Errorf("error parsing article: %w", err)
+	}
+	text, err := articleText(doc, site.ArticleSelector, site.ArticleExcludeSelector)
+	if err != nil {
+		return article{}, err
+	}
+	// Relative links resolve against where the page ended up, after redirects.
+	pageUrl, err := url.Parse(resp.Url)
+	if err != nil || pageUrl.Host == "" {
+		pageUrl = parsed
+	}
+	return article{text: text, canonicalLink: pageCanonicalLink(doc, pageUrl)}, nil
+}
+
+// pageCanonicalLink returns the page's rel=canonical, resolved against the URL
+// the page was fetched from, since a relative one is allowed; or "" if the page
+// names none.
+func pageCanonicalLink(doc *goquery.Document, pageUrl *url.URL) string {
+	href := strings.TrimSpace(doc.Find(`link[rel="canonical"]`).First().AttrOr("href", ""))
+	if href == "" {
+		return ""
+	}
+	resolved, err := pageUrl.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return canonicalLink(resolved.String())
+}
+
+// extractText returns the text of the elements matching selector, minus those
+// matching exclude, as one paragraph per line: the same shape as the generated
+// fake news content. Paragraph-level elements become lines of their own; a body
+// that has none is taken as a single block of text.
+func extractText(html io.Reader, selector, exclude string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(html)
+	if err != nil {
+		return "", fmt.Errorf("error parsing article: %w", err)
+	}
+	return articleText(doc, selector, exclude)
+}
+
+func articleText(doc *goquery.Document, selector, exclude string) (string, error) {
+	body := doc.Find(selector)
+	if body.Length() == 0 {
+		return "", fmt.Errorf("selector %q matched nothing", selector)
+	}
+	body.Find("script, style, noscript").Remove()
+	if exclude != "" {
+		body.Find(exclude).Remove()
+	}
+	var paragraphs []string
+	add := func(s *goquery.Selection) {
+		if text := strings.Join(strings.Fields(s.Text()), " "); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	blocks := body.Find("p, h2, h3, h4, li, blockquote")
+	if blocks.Length() == 0 {
+		body.Each(func(_ int, s *goquery.Selection) { add(s) })
+	} else {
+		// A blockquote or a list item can hold paragraphs of its own; only the
+		// innermost block is taken, or its text would appear twice.
+		blocks.Each(func(_ int, s *goquery.Selection) {
+			if s.Find("p, h2, h3, h4, li, blockquote").Length() == 0 {
+				add(s)
+			}
+		})
+	}
+	return strings.Join(paragraphs, "\n"), nil
+}
@@ -0,0 +1,118 @@
+package news
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+)
+
+func TestExtractText(t *testing.T) {
+	html := `<html><body>
+<nav><p>Forside</p></nav>
+<article class="body">
+	<h2>Mellemrubrik</h2>
+	<p>Første   afsnit
+	med linjeskift.</p>
+	<div class="ad"><p>Køb nu</p></div>
+	<blockquote><p>Et citat</p></blockquote>
+	<script>var x = 1;</script>
+	<p>Andet afsnit.</p>
+</article>
+</body></html>`
+	got, err := extractText(strings.NewReader(html), "article.body", ".ad")
+	if err != nil {
+		t.Fatalf("extractText: %v", err)
+	}
+	want := "Mellemrubrik\nFørste afsnit med linjeskift.\nEt citat\nAndet afsnit."
+	if got != want {
+		t.Errorf("extractText = %q, want %q", got, want)
+	}
+
+	if _, err := extractText(strings.NewReader(html), "main", ""); err == nil {
+		t.Error("a selector that matches nothing must be an error, not an empty article")
+	}
+}
+
+func TestHostLimiterSpacesRequestsPerHost(t *testing.T) {
+	limiter := newHostLimiter(30 * time.Millisecond)
+	ctx := context.Background()
+	start := time.Now()
+	for range 3 {
+		if err := limiter.wait(ctx, "a.example"); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("three requests to one host took %v, want at least two intervals", elapsed)
+	}
+
+	// Another host has slots of its own.
+	start = time.Now()
+	if err := limiter.wait(ctx, "b.example"); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("first request to a new host waited %v", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	limiter.wait(cancelled, "c.example") // takes the free slot
+	if err := limiter.wait(cancelled, "c.example"); err == nil {
+		t.Error("wait ignored a cancelled context")
+	}
+}
+
+// New items of an extracted site get the article text as their content, and it
+// is searchable as content straight away.
+func TestFetchExtractsArticles(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed" {
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Test</title>
+<item><title>Rasende borgere</title><link>%v/artikel/1</link><pubDate>Mon, 06 Jan 2025 10:00:00 +0000</pubDate></item>
+</channel></rss>`, server.URL)
+			return
+		}
+		w.Write([]byte(`<html><body><div class="article-body"><p>Borgerne er vrede over cykelstierne.</p><p class="ad">Annonce</p></div></body></html>`))
+	}))
+	defer server.Close()
+
+	service := newTestService(t)
+	service.articleLimiter = newHostLimiter(0)
+	site := testSite
+	site.Urls = []string{server.URL + "/feed"}
+	site.ArticleSelector = ".article-body"
+	site.ArticleExcludeSelector = ".ad"
+	ctx := context.Background()
+
+	if _, _, err := service.fetchAndSaveNewItemsForSite(ctx, site); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	items, err := service.GetRecentItems(ctx, site.Id, 10, nil)
+	if err != nil {
+		t.Fatalf("recent items: %v", err)
+	}
+	if len(items) != 1 || items[0].Content != "Borgerne er vrede over cykelstierne." {
+		t.Fatalf("items = %+v, want the article text as content", items)
+	}
+
+	titleOnly, err := service.search.Search(ctx, "da", "cykelstier", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	withContent, err := service.search.Search(ctx, "da", "cykelstier", true, core.SearchFilter{}, nil, nil, "published", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(titleOnly) != 0 || len(withContent) != 1 {
+		t.Errorf("found %v by title and %v by content, want 0 and 1", len(titleOnly), len(withContent))
+	}
+}
@@ -0,0 +1,89 @@
+package news
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A feed server that accepts the connection and then never answers would
+// otherwise stall the whole fetch job, so every request the job makes goes
+// through one client that gives up on each stage.
+const (
+	fetchConnectTimeout  = 10 * time.Second
+	fetchResponseTimeout = 30 * time.Second
+	// fetchTimeout caps the whole request, reading the body included.
+	fetchTimeout = 60 * time.Second
+)
+
+// The defaults for config.FetchWorkers and config.FetchPerHost.
+const (
+	defaultFetchWorkers = 8
+	defaultFetchPerHost = 2
+)
+
+// newFetchClient returns the client feeds and article pages are fetched with.
+// It is shared, so connections to a host are reused across sites and runs.
+func newFetchClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   fetchConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = fetchConnectTimeout
+	transport.ResponseHeaderTimeout = fetchResponseTimeout
+	return &http.Client{Transport: transport, Timeout: fetchTimeout}
+}
+
+// fetchWorkers is how many sites are fetched at once.
+func (r *RssService) fetchWorkers() int {
+	if workers := r.context.Config.FetchWorkers; workers > 0 {
+		return workers
+	}
+	return defaultFetchWorkers
+}
+
+// hostSlots caps the requests in flight per host. Several sites can share a
+// domain — a paper's sections, or a publisher's titles — and the workers would
+// otherwise hit it with all of their feeds at once. Where hostLimiter spaces
+// requests out in time, hostSlots bounds how many overlap.
+type hostSlots struct {
+	perHost int
+	mu      sync.Mutex
+	slots   map[string]chan struct{}
+}
+
+func newHostSlots(perHost int) *hostSlots {
+	if perHost <= 0 {
+		perHost = defaultFetchPerHost
+	}
+	return &hostSlots{perHost: perHost, slots: make(map[string]chan struct{})}
+}
+
+// acquire blocks until host has a free slot, or until ctx is done. The returned
+// func frees the slot.
+func (s *hostSlots) acquire(ctx context.Context, host string) (func(), error) {
+	s.mu.Lock()
+	slot, ok := s.slots[host]
+	if !ok {
+		slot = make(chan struct{}, s.perHost)
+		s.slots[host] = slot
+	}
+	s.mu.Unlock()
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// hostOf is the host a URL's slots are counted against. "www." is dropped, as it
+// is the same server as the bare domain.
+func hostOf(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,110 @@
+package news
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/mmcdole/gofeed"
+)
+
+// parseItems reads the items out of a fetched feed or page, the way the site's
+// source type says to. Every type comes out as gofeed items, so that ids,
+// metadata and conversion are the same whatever the site is read from.
+func parseItems(site core.NewsSite, resp FeedResponse) ([]*gofeed.Item, error) {
+	switch site.Source() {
+	case core.SourceJsonFeed:
+		if gofeed.DetectFeedType(bytes.NewReader(resp.Body)) != gofeed.FeedTypeJSON {
+			return nil, errors.New("not a JSON Feed")
+		}
+		return parseFeed(resp.Body)
+	case core.SourceHtml:
+		return parseListing(site.HtmlSource, editionLocation(site.Language), resp)
+	default:
+		return parseFeed(resp.Body)
+	}
+}
+
+func parseFeed(body []byte) ([]*gofeed.Item, error) {
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return feed.Items, nil
+}
+
+// parseListing pulls the headlines out of a listing page. A headline without a
+// title or a link is skipped, as are the teaser boxes and ads an item selector
+// tends to catch too. A page with no headlines at all is an error: it is what a
+// redesign of the page looks like, and it should show up in the feed's health
+// rather than as a quiet site. A time that names no zone is read in loc.
+func parseListing(source core.HtmlSource, loc *time.Location, resp FeedResponse) ([]*gofeed.Item, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, err
+	}
+	pageUrl, err := url.Parse(resp.Url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page url %q: %w", resp.Url, err)
+	}
+	items := make([]*gofeed.Item, 0)
+	doc.Find(source.ItemSelector).Each(func(_ int, sel *goquery.Selection) {
+		linkSel := listingLink(sel, source.LinkSelector)
+		href, ok := linkSel.Attr("href")
+		title := linkSel
+		if source.TitleSelector != "" {
+			title = sel.Find(source.TitleSelector).First()
+		}
+		titleText := strings.Join(strings.Fields(title.Text()), " ")
+		if titleText == "" || !ok {
+			return
+		}
+		link, err := pageUrl.Parse(href)
+		if err != nil {
+			return
+		}
+		item := &gofeed.Item{Title: titleText, Link: link.String()}
+		if source.TimeSelector != "" {
+			if published, ok := listingTime(sel.Find(source.TimeSelector).First(), source.TimeLayout, loc); ok {
+				item.PublishedParsed = &published
+			}
+		}
+		items = append(items, item)
+	})
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no headlines matched %q", source.ItemSelector)
+	}
+	return items, nil
+}
+
+// listingLink returns the element linkSelector matches within item, or with no
+// selector, item itself if it is a link and else the first link in it.
+func listingLink(item *goquery.Selection, linkSelector string) *goquery.Selection {
+	if linkSelector != "" {
+		return item.Find(linkSelector).First()
+	}
+	if _, ok := item.Attr("href"); ok {
+		return item
+	}
+	return item.Find("a[href]").First()
+}
+
+// listingTime reads a time from sel's datetime attribute, or from its text, in
+// layout or RFC 3339. A layout without a zone is read in loc: listing pages
+// print the local time of their readers, "17.10.2026 00:30", not UTC.
+func listingTime(sel *goquery.Selection, layout string, loc *time.Location) (time.Time, bool) {
+	value, ok := sel.Attr("datetime")
+	if !ok {
+		value = sel.Text()
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	published, err := time.ParseInLocation(layout, strings.TrimSpace(value), loc)
+	return published, err == nil
+}
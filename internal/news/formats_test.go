@@ -0,0 +1,121 @@
+package news
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+)
+
+const testListing = `<html><body>
+<section class="front">
+	<article class="teaser">
+		<a class="headline" href="/kongehuset/dronningen-rasende"> Dronningen
+			er rasende </a>
+		<time datetime="2025-01-06T10:00:00+01:00">i går</time>
+	</article>
+	<article class="teaser">
+		<a class="headline" href="https://www.example.dk/tv/vært-raser">Vært raser</a>
+		<time>mandag</time>
+	</article>
+	<article class="teaser ad"><span>Annonce</span></article>
+</section>
+</body></html>`
+
+func TestParseListing(t *testing.T) {
+	source := core.HtmlSource{ItemSelector: "article.teaser", LinkSelector: "a.headline", TimeSelector: "time"}
+	items, err := parseListing(source, time.UTC, FeedResponse{Url: "https://www.example.dk/", Body: []byte(testListing)})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %v items, want 2 (the ad has no link)", len(items))
+	}
+	first := items[0]
+	if first.Title != "Dronningen er rasende" || first.Link != "https://www.example.dk/kongehuset/dronningen-rasende" {
+		t.Errorf("first item = %q %q", first.Title, first.Link)
+	}
+	if want := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC); first.PublishedParsed == nil || !first.PublishedParsed.Equal(want) {
+		t.Errorf("first item published %v, want %v", first.PublishedParsed, want)
+	}
+	// "mandag" is no time; the item is dated when it is first seen instead.
+	if items[1].PublishedParsed != nil {
+		t.Errorf("second item published %v, want none", items[1].PublishedParsed)
+	}
+
+	// A selector that matches nothing is what a redesign looks like.
+	if _, err := parseListing(core.HtmlSource{ItemSelector: "li.story"}, time.UTC, FeedResponse{Url: "https://www.example.dk/", Body: []byte(testListing)}); err == nil {
+		t.Error("a listing without headlines parsed without error")
+	}
+}
+
+// A listing that prints the local time, with no zone, is read in the time zone
+// of the site's edition: half past midnight in Copenhagen is the evening
+// before in UTC, and must stay on its own day there.
+func TestParseListingLocalTime(t *testing.T) {
+	listing := `<ul><li><a href="/a">Rasende borgere</a> <span class="tid">17.10.2026 00:30</span></li></ul>`
+	source := core.HtmlSource{ItemSelector: "li", TimeSelector: "span.tid", TimeLayout: "02.01.2006 15:04"}
+	site := core.NewsSite{Language: "da", SourceType: core.SourceHtml, HtmlSource: source}
+	items, err := parseItems(site, FeedResponse{Url: "https://www.example.dk/", Body: []byte(listing)})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := time.Date(2026, 10, 16, 22, 30, 0, 0, time.UTC)
+	if len(items) != 1 || items[0].PublishedParsed == nil || !items[0].PublishedParsed.Equal(want) {
+		t.Fatalf("items = %+v, want one published %v", items, want)
+	}
+
+	// A language with no edition has no zone to go by but UTC.
+	site.Language = "de"
+	items, err = parseItems(site, FeedResponse{Url: "https://www.example.dk/", Body: []byte(listing)})
+	if err != nil || len(items) != 1 || !items[0].PublishedParsed.Equal(want.Add(2*time.Hour)) {
+		t.Errorf("items in an unknown language = %+v, %v, want them read as UTC", items, err)
+	}
+}
+
+const testJsonFeed = `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"title": "Test",
+	"items": [
+		{"id": "1", "url": "https://example.dk/1", "title": "Rasende borgere", "content_text": "Vrede.",
+		 "date_published": "2025-01-06T10:00:00Z", "authors": [{"name": "Anna Hansen"}], "tags": ["Indland"]}
+	]
+}`
+
+// A JSON Feed site is read as one, and its items carry the same metadata as an
+// RSS site's would. A site typed as JSON Feed that serves RSS fails, rather than
+// being read as whatever it turned out to be.
+func TestFetchReadsJsonFeeds(t *testing.T) {
+	body := testJsonFeed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	service := newTestService(t)
+	site := testSite
+	site.Urls = []string{server.URL}
+	site.SourceType = core.SourceJsonFeed
+	ctx := context.Background()
+	if _, _, err := service.fetchAndSaveNewItemsForSite(ctx, site); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	items, err := service.GetRecentItems(ctx, site.Id, 10, nil)
+	if err != nil {
+		t.Fatalf("recent items: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %v items, want 1", len(items))
+	}
+	if item := items[0]; item.Title != "Rasende borgere" || item.Guid != "1" || !equal(item.Authors, []string{"Anna Hansen"}) || !equal(item.Categories, []string{"Indland"}) {
+		t.Errorf("item = %+v", item)
+	}
+
+	body = testFeed
+	if _, _, err := service.fetchAndSaveNewItemsForSite(ctx, site); err == nil {
+		t.Error("an RSS feed was read as a JSON Feed")
+	}
+}
@@ -0,0 +1,164 @@
+package news
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// A feed is retried on the next run for its first few failures, because most
+// failures are a timeout or a deploy on the other end and clear by themselves.
+// After that it backs off exponentially, so a feed that has been dead for days is
+// asked about once a day rather than on every run.
+const (
+	backoffAfterFailures = 3
+	backoffBase          = 15 * time.Minute
+	backoffMax           = 24 * time.Hour
+)
+
+var (
+	feedConsecutiveFailures = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rasende2_feed_consecutive_failures",
+		Help: "The number of failed fetches of a feed since its last success",
+	}, []string{
+		"name", "url",
+	})
+
+	feedLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rasende2_feed_last_success_timestamp_seconds",
+		Help: "When a feed was last fetched successfully, as a unix timestamp",
+	}, []string{
+		"name", "url",
+	})
+
+	feedBackoffUntil = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rasende2_feed_backoff_until_timestamp_seconds",
+		Help: "When a backing-off feed will next be fetched, as a unix timestamp; 0 when it is not backing off",
+	}, []string{
+		"name", "url",
+	})
+)
+
+// backoff is how long to wait before fetching a feed that has failed
+// consecutiveFailures times in a row.
+func backoff(consecutiveFailures int) time.Duration {
+	if consecutiveFailures < backoffAfterFailures {
+		return 0
+	}
+	wait := backoffBase
+	for i := backoffAfterFailures; i < consecutiveFailures; i++ {
+		wait *= 2
+		if wait >= backoffMax {
+			return backoffMax
+		}
+	}
+	return wait
+}
+
+// feedSucceeded records a successful fetch. One success is all it takes to
+// recover: the failure count and the backoff are cleared.
+func feedSucceeded(health core.FeedHealth, now time.Time) core.FeedHealth {
+	health.LastSuccessAt = &now
+	health.ConsecutiveFailures = 0
+	health.NextFetchAt = nil
+	return health
+}
+
+// feedFailed records a failed fetch, and backs the feed off if it has failed
+// often enough.
+func feedFailed(health core.FeedHealth, err error, now time.Time) core.FeedHealth {
+	health.LastErrorAt = &now
+	health.LastError = err.Error()
+	health.ConsecutiveFailures++
+	health.NextFetchAt = nil
+	if wait := backoff(health.ConsecutiveFailures); wait > 0 {
+		next := now.Add(wait)
+		health.NextFetchAt = &next
+	}
+	return health
+}
+
+func setFeedHealthMetrics(health core.FeedHealth) {
+	feedConsecutiveFailures.WithLabelValues(health.SiteName, health.Url).Set(float64(health.ConsecutiveFailures))
+	if health.LastSuccessAt != nil {
+		feedLastSuccess.WithLabelValues(health.SiteName, health.Url).Set(float64(health.LastSuccessAt.Unix()))
+	}
+	var backoffUntil float64
+	if health.NextFetchAt != nil {
+		backoffUntil = float64(health.NextFetchAt.Unix())
+	}
+	feedBackoffUntil.WithLabelValues(health.SiteName, health.Url).Set(backoffUntil)
+}
+
+// feedHealthByUrl returns the stored health records, keyed by feed URL.
+func (r *RssService) feedHealthByUrl(ctx context.Context) (map[string]core.FeedHealth, error) {
+	records, err := r.repository.GetFeedHealth(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byUrl := make(map[string]core.FeedHealth, len(records))
+	for _, health := range records {
+		byUrl[health.Url] = health
+	}
+	return byUrl, nil
+}
+
+// GetFeedHealth returns one record per configured feed URL, sorted by site. A
+// feed that has never been fetched gets an empty record rather than no row, so
+// the admin page shows every feed. Records of URLs that are no longer configured
+// are left out.
+func (r *RssService) GetFeedHealth(ctx context.Context) ([]core.FeedHealth, error) {
+	sites, err := r.repository.GetSites(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byUrl, err := r.feedHealthByUrl(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]core.FeedHealth, 0)
+	for _, site := range sites {
+		for _, url := range site.Urls {
+			health, ok := byUrl[url]
+			if !ok {
+				health = core.FeedHealth{Url: url}
+			}
+			health.SiteId = site.Id
+			health.SiteName = site.Name
+			result = append(result, health)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b core.FeedHealth) int {
+		return cmp.Compare(a.SiteName, b.SiteName)
+	})
+	return result, nil
+}
+
+// refreshFeedHealthMetrics seeds the gauges from the stored records, so a restart
+// does not blank them until each feed has been fetched again.
+func (r *RssService) refreshFeedHealthMetrics(ctx context.Context) error {
+	feeds, err := r.GetFeedHealth(ctx)
+	if err != nil {
+		return err
+	}
+	for _, health := range feeds {
+		setFeedHealthMetrics(health)
+	}
+	return nil
+}
+
+// saveFeedHealth stores a feed's new health and updates its gauges. A failure
+// to store is only logged: it must not fail the fetch whose items were already
+// saved.
+func (r *RssService) saveFeedHealth(ctx context.Context, health core.FeedHealth) {
+	if err := r.repository.SaveFeedHealth(ctx, health); err != nil {
+		slog.Error("saving feed health failed", "url", health.Url, "error", err)
+	}
+	setFeedHealthMetrics(health)
+}
@@ -0,0 +1,161 @@
+package news
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+)
+
+// jobRun is a run in progress. It is recorded in job_runs as running when it
+// starts, and its counters are stored with the outcome when it finishes. add may
+// be called from several goroutines, as the per-site fetches do.
+type jobRun struct {
+	r   *RssService
+	mu  sync.Mutex
+	run core.JobRun
+	// lock is the kind's lock from jobLocks, held until the run finishes.
+	lock *sync.Mutex
+}
+
+// jobLocks holds a lock per kind of job. The scheduler and the API both start
+// runs through startJobRun, so a run triggered by hand while the scheduled one
+// is still going, or the other way round, is skipped instead of racing it.
+type jobLocks struct {
+	mu     sync.Mutex
+	byKind map[string]*sync.Mutex
+}
+
+func (l *jobLocks) of(kind string) *sync.Mutex {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.byKind == nil {
+		l.byKind = make(map[string]*sync.Mutex)
+	}
+	lock, ok := l.byKind[kind]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.byKind[kind] = lock
+	}
+	return lock
+}
+
+// startJobRun records the start of a run of kind. If one is already running,
+// the run is recorded as skipped instead, and the error is core.ErrJobBusy.
+func (r *RssService) startJobRun(ctx context.Context, kind string) (*jobRun, error) {
+	now := time.Now()
+	lock := r.jobLocks.of(kind)
+	if !lock.TryLock() {
+		r.recordSkippedRun(ctx, kind, now)
+		return nil, fmt.Errorf("not starting %v: %w", kind, core.ErrJobBusy)
+	}
+	id, err := r.repository.CreateJobRun(ctx, kind, now)
+	if err != nil {
+		lock.Unlock()
+		return nil, fmt.Errorf("failed to record start of %v: %w", kind, err)
+	}
+	return &jobRun{r: r, lock: lock, run: core.JobRun{
+		Id:        id,
+		Kind:      kind,
+		StartedAt: now,
+		Status:    core.JobRunning,
+		Counters:  make(map[string]int),
+	}}, nil
+}
+
+// recordSkippedRun records a run of kind that was not started, so the job
+// history shows the trigger was not lost.
+func (r *RssService) recordSkippedRun(ctx context.Context, kind string, now time.Time) {
+	id, err := r.repository.CreateJobRun(ctx, kind, now)
+	if err == nil {
+		err = r.repository.FinishJobRun(ctx, core.JobRun{
+			Id:         id,
+			Kind:       kind,
+			StartedAt:  now,
+			FinishedAt: &now,
+			Status:     core.JobSkipped,
+			Error:      core.ErrJobBusy.Error(),
+			Counters:   map[string]int{},
+		})
+	}
+	if err != nil {
+		slog.Error("recording skipped job run failed", "kind", kind, "error", err)
+	}
+}
+
+func (j *jobRun) add(counter string, n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.run.Counters[counter] += n
+}
+
+func (j *jobRun) snapshot() core.JobRun {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	run := j.run
+	run.Counters = maps.Clone(j.run.Counters)
+	return run
+}
+
+// finish records the outcome and hands err back, so a job can end with
+// "return run.finish(ctx, err)". The outcome is written even if ctx has been
+// cancelled: a run cut short by shutdown should say so, not stay running.
+func (j *jobRun) finish(ctx context.Context, err error) error {
+	now := time.Now()
+	j.mu.Lock()
+	j.run.FinishedAt = &now
+	j.run.Status = core.JobSucceeded
+	if err != nil {
+		j.run.Status = core.JobFailed
+		j.run.Error = err.Error()
+	}
+	j.mu.Unlock()
+	if saveErr := j.r.repository.FinishJobRun(context.WithoutCancel(ctx), j.snapshot()); saveErr != nil {
+		slog.Error("recording job run failed", "id", j.run.Id, "kind", j.run.Kind, "error", saveErr)
+	}
+	j.lock.Unlock()
+	return err
+}
+
+// failInterruptedJobRuns closes off the runs a previous process left running,
+// which it can only have done by stopping mid-run.
+func (r *RssService) failInterruptedJobRuns(ctx context.Context) {
+	if err := r.repository.FailRunningJobRuns(ctx, "interrupted: the app stopped before the run finished"); err != nil {
+		slog.Error("failing interrupted job runs failed", "error", err)
+	}
+}
+
+func (r *RssService) GetJobRuns(ctx context.Context, limit int) ([]core.JobRun, error) {
+	return r.repository.GetJobRuns(ctx, limit)
+}
+
+func (r *RssService) GetJobRun(ctx context.Context, id int64) (*core.JobRun, error) {
+	return r.repository.GetJobRun(ctx, id)
+}
+
+// StartRebuildSearchIndex discards rss_items_fts and reindexes every item in the
+// background. It returns as soon as the run is recorded, so the caller can poll
+// the run until it finishes.
+func (r *RssService) StartRebuildSearchIndex(ctx context.Context) (*core.JobRun, error) {
+	run, err := r.startJobRun(ctx, core.JobRebuildSearchIndex)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		// Not tied to the caller: the request that started the rebuild ends long
+		// before the rebuild does.
+		ctx := context.WithoutCancel(ctx)
+		indexed, skipped, err := r.search.Rebuild(ctx)
+		run.add("documents", indexed)
+		run.add("skipped", skipped)
+		if run.finish(ctx, err) != nil {
+			slog.Error("rebuilding search index failed", "error", err)
+		}
+	}()
+	started := run.snapshot()
+	return &started, nil
+}
@@ -0,0 +1,88 @@
+package news
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// itemAuthors returns the names of the item's authors. gofeed has already
+// looked in the places a feed may put them — <author>, dc:creator, itunes:author
+// — and an author given only as an e-mail address keeps the address.
+func itemAuthors(feedItem *gofeed.Item) []string {
+	authors := make([]string, 0, len(feedItem.Authors))
+	for _, person := range feedItem.Authors {
+		if person == nil {
+			continue
+		}
+		name := person.Name
+		if name == "" {
+			name = person.Email
+		}
+		authors = append(authors, name)
+	}
+	return cleanStrings(authors)
+}
+
+// itemCategories returns the item's categories or tags.
+func itemCategories(feedItem *gofeed.Item) []string {
+	return cleanStrings(feedItem.Categories)
+}
+
+// itemImage returns the URL of the item's picture, or "". The feed's own
+// statements about an image come first: an image enclosure, then a
+// media:content image, then a media:thumbnail. gofeed's Image is only the
+// fallback, because when none of those are there it settles for the first <img>
+// in the content, which is as likely to be a tracking pixel as a photo.
+func itemImage(feedItem *gofeed.Item) string {
+	for _, enclosure := range feedItem.Enclosures {
+		if enclosure != nil && strings.HasPrefix(enclosure.Type, "image/") && enclosure.URL != "" {
+			return enclosure.URL
+		}
+	}
+	if media, ok := feedItem.Extensions["media"]; ok {
+		for _, content := range media["content"] {
+			if (strings.HasPrefix(content.Attrs["type"], "image/") || content.Attrs["medium"] == "image") && content.Attrs["url"] != "" {
+				return content.Attrs["url"]
+			}
+		}
+		for _, thumbnail := range media["thumbnail"] {
+			if thumbnail.Attrs["url"] != "" {
+				return thumbnail.Attrs["url"]
+			}
+		}
+	}
+	if feedItem.Image != nil {
+		return feedItem.Image.URL
+	}
+	return ""
+}
+
+// cleanStrings trims the values and drops empty ones and repeats. Feeds pad
+// their categories with whitespace and, when they merge several taxonomies,
+// repeat them.
+func cleanStrings(values []string) []string {
+	cleaned := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		cleaned = append(cleaned, value)
+	}
+	return cleaned
+}
+
+// unmarshalStrings reads the authors or categories column of a search result:
+// a JSON array of strings, or NULL for an item stored before the column existed.
+func unmarshalStrings(value *string) ([]string, error) {
+	values := []string{}
+	if value == nil {
+		return values, nil
+	}
+	err := json.Unmarshal([]byte(*value), &values)
+	return values, err
+}
@@ -0,0 +1,177 @@
+package news
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/search"
+)
+
+// opml is an OPML 2.0 document, as far as a feed list uses one.
+type opml struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Head    opmlHead      `xml:"head"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+// opmlOutline is one feed, or a folder of them in the lists feed readers
+// export. language and description are attributes OPML 2.0 defines for feeds.
+type opmlOutline struct {
+	Text        string        `xml:"text,attr"`
+	Title       string        `xml:"title,attr,omitempty"`
+	Type        string        `xml:"type,attr,omitempty"`
+	XmlUrl      string        `xml:"xmlUrl,attr,omitempty"`
+	Url         string        `xml:"url,attr,omitempty"`
+	Language    string        `xml:"language,attr,omitempty"`
+	Description string        `xml:"description,attr,omitempty"`
+	Outlines    []opmlOutline `xml:"outline"`
+}
+
+// ExportOpml writes every site as OPML 2.0, one outline per URL, so that a site
+// with several feeds comes back as one when the list is imported again. A site
+// read from html pages has no feed, and is written as a link; one with no URLs
+// at all as a bare outline. Neither is imported again.
+func (r *RssService) ExportOpml(ctx context.Context) ([]byte, error) {
+	sites, err := r.repository.GetSites(ctx)
+	if err != nil {
+		return nil, err
+	}
+	doc := opml{
+		Version: "2.0",
+		Head:    opmlHead{Title: "rasende2", DateCreated: time.Now().UTC().Format(time.RFC1123Z)},
+		Body:    make([]opmlOutline, 0),
+	}
+	for _, site := range sites {
+		outline := opmlOutline{Text: site.Name, Title: site.Name, Language: site.Language, Description: site.Description}
+		if len(site.Urls) == 0 {
+			// Kept in the list, with nothing to subscribe to.
+			doc.Body = append(doc.Body, outline)
+		}
+		for _, url := range site.Urls {
+			if site.Source() == core.SourceHtml {
+				outline.Type, outline.Url = "link", url
+			} else {
+				outline.Type, outline.XmlUrl = "rss", url
+			}
+			doc.Body = append(doc.Body, outline)
+		}
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// ImportOpml creates a disabled draft site for each feed in an OPML list, to be
+// reviewed before it is enabled. The feeds of outlines with the same name make
+// one site, as ExportOpml writes them. A site whose name and feeds are all new
+// is created; one that shares either with a stored site is skipped.
+func (r *RssService) ImportOpml(ctx context.Context, body io.Reader) (core.OpmlImport, error) {
+	var doc opml
+	if err := xml.NewDecoder(body).Decode(&doc); err != nil {
+		return core.OpmlImport{}, fmt.Errorf("invalid OPML: %w", err)
+	}
+	return r.importSites(ctx, opmlSites(doc.Body))
+}
+
+// ImportSitesJson creates a disabled draft site for each entry in a JSON array
+// of sites, as the duda command writes them, skipping those ImportOpml would.
+// An entry keeps every field it sets but its id, since the drafts are numbered
+// as they are created.
+func (r *RssService) ImportSitesJson(ctx context.Context, body io.Reader) (core.OpmlImport, error) {
+	var sites []core.NewsSite
+	if err := json.NewDecoder(body).Decode(&sites); err != nil {
+		return core.OpmlImport{}, fmt.Errorf("invalid site JSON: %w", err)
+	}
+	for i := range sites {
+		sites[i].Id = 0
+		sites[i].Disabled = true
+		sites[i].Draft = true
+	}
+	return r.importSites(ctx, sites)
+}
+
+// importSites creates the sites whose name and feeds are all new.
+func (r *RssService) importSites(ctx context.Context, sites []core.NewsSite) (core.OpmlImport, error) {
+	result := core.OpmlImport{}
+	existing, err := r.repository.GetSites(ctx)
+	if err != nil {
+		return result, err
+	}
+	known := make(map[string]bool)
+	for _, site := range existing {
+		known[site.Name] = true
+		for _, url := range site.Urls {
+			known[url] = true
+		}
+	}
+	for _, site := range sites {
+		if known[site.Name] || slices.ContainsFunc(site.Urls, func(url string) bool { return known[url] }) {
+			result.Skipped = append(result.Skipped, site.Name)
+			continue
+		}
+		site.Id, err = r.CreateSite(ctx, site)
+		if err != nil {
+			return result, fmt.Errorf("creating %q failed: %w", site.Name, err)
+		}
+		known[site.Name] = true
+		for _, url := range site.Urls {
+			known[url] = true
+		}
+		result.Created = append(result.Created, site)
+		if !search.Supported(site.Language) {
+			result.Unanalyzed = append(result.Unanalyzed, site)
+		}
+	}
+	return result, nil
+}
+
+// opmlSites collects the feeds in outlines, folders included, into draft sites
+// in the order they first appear. The language is reduced to its primary tag,
+// "en-US" to "en", which is what the editions are named by.
+func opmlSites(outlines []opmlOutline) []core.NewsSite {
+	sites := make([]core.NewsSite, 0)
+	byName := make(map[string]int)
+	var walk func([]opmlOutline)
+	walk = func(outlines []opmlOutline) {
+		for _, outline := range outlines {
+			walk(outline.Outlines)
+			url := strings.TrimSpace(outline.XmlUrl)
+			if url == "" {
+				continue
+			}
+			name := cmp.Or(strings.TrimSpace(outline.Text), strings.TrimSpace(outline.Title), url)
+			if i, ok := byName[name]; ok {
+				sites[i].Urls = append(sites[i].Urls, url)
+				continue
+			}
+			language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(outline.Language)), "-")
+			byName[name] = len(sites)
+			sites = append(sites, core.NewsSite{
+				Name:        name,
+				Urls:        []string{url},
+				Description: strings.TrimSpace(outline.Description),
+				Language:    language,
+				Disabled:    true,
+				Draft:       true,
+			})
+		}
+	}
+	walk(outlines)
+	return sites
+}
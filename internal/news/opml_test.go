@@ -0,0 +1,100 @@
+package news
+
+import (
+	"context"
+	"encoding/xml"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+)
+
+const testOpml = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>Feeds</title></head>
+  <body>
+    <outline text="Danish">
+      <outline type="rss" text="Arbejderen" xmlUrl="https://arbejderen.dk/feed/" language="da"/>
+      <outline type="rss" text="Ny Avis" xmlUrl="https://nyavis.dk/rss" language="da-DK" description="A new paper"/>
+      <outline type="rss" text="Ny Avis" xmlUrl="https://nyavis.dk/sport/rss" language="da-DK"/>
+    </outline>
+    <outline type="rss" text="Die Zeit" xmlUrl="https://zeit.de/rss" language="de-DE"/>
+    <outline type="rss" text="BBC News" xmlUrl="https://bbc.example/rss" language="en"/>
+    <outline type="link" text="Homepage" url="https://example.org/"/>
+  </body>
+</opml>`
+
+// An import makes disabled drafts of the feeds not already on the list, one
+// site per name, and flags the ones in a language with no analyzer.
+func TestImportOpml(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	result, err := service.ImportOpml(ctx, strings.NewReader(testOpml))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(result.Created) != 2 || len(result.Skipped) != 2 || result.Skipped[0] != "Arbejderen" || result.Skipped[1] != "BBC News" {
+		t.Fatalf("import = %+v, want Ny Avis and Die Zeit created, and Arbejderen and BBC News skipped", result)
+	}
+	if len(result.Unanalyzed) != 1 || result.Unanalyzed[0].Name != "Die Zeit" {
+		t.Errorf("unanalyzed = %+v, want Die Zeit", result.Unanalyzed)
+	}
+	stored, err := service.GetSiteInfoById(ctx, result.Created[0].Id)
+	if err != nil || stored == nil {
+		t.Fatalf("get created site: %v, %v", stored, err)
+	}
+	want := core.NewsSite{Name: "Ny Avis", Language: "da", Description: "A new paper", Disabled: true, Draft: true}
+	if stored.Name != want.Name || stored.Language != want.Language || stored.Description != want.Description ||
+		!stored.Disabled || !stored.Draft || len(stored.Urls) != 2 {
+		t.Errorf("created site = %+v, want %+v with both feeds", stored, want)
+	}
+
+	again, err := service.ImportOpml(ctx, strings.NewReader(testOpml))
+	if err != nil || len(again.Created) != 0 || len(again.Skipped) != 4 {
+		t.Errorf("second import = %+v, %v, want everything skipped", again, err)
+	}
+}
+
+// What is exported imports again as the same sites.
+func TestExportOpmlRoundTrips(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	sites, err := service.GetSites(ctx)
+	if err != nil {
+		t.Fatalf("sites: %v", err)
+	}
+	sites = slices.DeleteFunc(sites, func(site core.NewsSite) bool { return len(site.Urls) == 0 })
+	out, err := service.ExportOpml(ctx)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if !strings.Contains(string(out), `xmlUrl="https://arbejderen.dk/feed/"`) || !strings.Contains(string(out), `language="da"`) {
+		t.Errorf("export is missing Arbejderen:\n%s", out)
+	}
+
+	importer := newTestService(t)
+	imported := opmlSitesOf(t, out)
+	if len(imported) != len(sites) {
+		t.Fatalf("export has %v sites, want %v", len(imported), len(sites))
+	}
+	for i, site := range sites {
+		got := imported[i]
+		if got.Name != site.Name || got.Language != site.Language || got.Description != site.Description || strings.Join(got.Urls, " ") != strings.Join(site.Urls, " ") {
+			t.Errorf("site %v exported as %+v", site.Name, got)
+		}
+	}
+	if result, err := importer.ImportOpml(ctx, strings.NewReader(string(out))); err != nil || len(result.Created) != 0 {
+		t.Errorf("importing the export into the same seeded sites = %+v, %v, want all skipped", result, err)
+	}
+}
+
+func opmlSitesOf(t *testing.T, out []byte) []core.NewsSite {
+	t.Helper()
+	var doc opml
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("parse export: %v", err)
+	}
+	return opmlSites(doc.Body)
+}
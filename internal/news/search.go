@@ -4,12 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"html"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/lang"
 	"github.com/bjarke-xyz/rasende2/internal/repository/db"
 	"github.com/bjarke-xyz/rasende2/internal/search"
 	"github.com/prometheus/client_golang/prometheus"
@@ -23,7 +27,7 @@ import (
 // The index holds every language in one table, stemmed by whichever analyzer the
 // publishing site's language selected. That is safe because a search is always
 // scoped to one language: the tokens are matched with that language's analyzer,
-// and the results are restricted to that language's sites (see siteFilter). An
+// and the results are restricted to that language's sites (see compileQuery). An
 // item carries no language column — it inherits its site's, which is why the
 // repository is needed here.
 type RssSearch struct {
@@ -35,28 +39,89 @@ func NewRssSearch(context *core.AppContext, repository core.NewsRepository) *Rss
 	return &RssSearch{context: context, repository: repository}
 }
 
-// siteFilter restricts a query to the sites publishing in lang. It is the only
-// thing keeping the languages apart in the shared index: without it, an English
-// query could match a Danish row whose stem happened to collide.
+// compiledQuery is a user query made ready to run: the FTS5 MATCH expression
+// of its terms, and the SQL conditions of its filters with their arguments.
+type compiledQuery struct {
+	query  search.Query
+	expr   string
+	clause string
+	args   []any
+}
+
+// compileQuery parses query and compiles it for the lang edition. Syntax errors
+// are *search.QueryError, as is a site: filter naming no site of the edition.
+//
+// The conditions always restrict the query to the sites publishing in lang. It
+// is the only thing keeping the languages apart in the shared index: without
+// it, an English query could match a Danish row whose stem happened to collide.
+// A site: filter narrows that list further. The dates of after: and before:
+// are days of the edition's calendar.
 //
-// Returns false when the language has no sites, which callers must treat as
-// "match nothing" — an empty IN () list is a syntax error.
-func (s *RssSearch) siteFilter(ctx context.Context, lang string) (string, []any, bool, error) {
+// Returns false when the query can match nothing: when its terms carry nothing
+// searchable, or the edition has no sites. Callers must return no results then
+// — an empty MATCH expression, like an empty IN () list, is a syntax error.
+func (s *RssSearch) compileQuery(ctx context.Context, lang string, query string, searchContent bool) (compiledQuery, bool, error) {
+	parsed, err := search.ParseQueryIn(query, editionLocation(lang))
+	if err != nil {
+		return compiledQuery{}, false, err
+	}
+	expr, ok := parsed.Match(lang, searchContent)
+	if !ok {
+		return compiledQuery{}, false, nil
+	}
 	sites, err := s.repository.GetSites(ctx)
 	if err != nil {
-		return "", nil, false, err
+		return compiledQuery{}, false, err
+	}
+	sites = slices.DeleteFunc(sites, func(site core.NewsSite) bool { return site.Language != lang })
+	if len(parsed.Sites) > 0 {
+		named := make([]core.NewsSite, 0, len(parsed.Sites))
+		for _, name := range parsed.Sites {
+			i := slices.IndexFunc(sites, func(site core.NewsSite) bool { return siteNameKey(site.Name) == siteNameKey(name) })
+			if i < 0 {
+				return compiledQuery{}, false, &search.QueryError{Key: "error.query.unknownSite", Args: []any{name}}
+			}
+			named = append(named, sites[i])
+		}
+		sites = named
 	}
-	args := []any{}
+	if len(sites) == 0 {
+		return compiledQuery{}, false, nil
+	}
+	compiled := compiledQuery{query: parsed, expr: expr}
 	for _, site := range sites {
-		if site.Language == lang {
-			args = append(args, site.Id)
-		}
+		compiled.args = append(compiled.args, site.Id)
 	}
-	if len(args) == 0 {
-		return "", nil, false, nil
+	compiled.clause = " AND i.site_id IN (" + strings.TrimSuffix(strings.Repeat("?,", len(sites)), ",") + ")"
+	if parsed.After != nil {
+		compiled.clause += " AND datetime(i.published) >= datetime(?)"
+		compiled.args = append(compiled.args, parsed.After.UTC().Format(time.RFC3339))
 	}
-	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
-	return " AND i.site_id IN (" + placeholders + ")", args, true, nil
+	if parsed.Before != nil {
+		compiled.clause += " AND datetime(i.published) < datetime(?)"
+		compiled.args = append(compiled.args, parsed.Before.UTC().Format(time.RFC3339))
+	}
+	return compiled, true, nil
+}
+
+// editionLocation is the zone of the lang edition's calendar, which the days of
+// a query's date filters are in.
+func editionLocation(code string) *time.Location {
+	if l, ok := lang.Get(code); ok {
+		return l.Location
+	}
+	return time.UTC
+}
+
+// siteNameKey is what a site: filter is compared on: the name in lower case,
+// without spaces or punctuation, so that site:bbcnews finds "BBC News".
+func siteNameKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
 }
 
 // siteLanguages maps site id to language, for stemming rows on the rebuild path,
@@ -79,45 +144,21 @@ var dbSizeGauge = promauto.NewGauge(prometheus.GaugeOpts{
 })
 
 // orderByClauses whitelists the sort values the web layer may pass through.
-// bm25() returns increasingly negative scores for better matches, so ascending
-// bm25 is descending relevance.
+// They order the outer query of Search, over the columns of its matches: score
+// is bm25(), which returns increasingly negative scores for better matches, so
+// ascending score is descending relevance.
 var orderByClauses = map[string]string{
-	"-published": "i.published DESC",
-	"published":  "i.published ASC",
-	"-_score":    "bm25(rss_items_fts) ASC",
-	"_score":     "bm25(rss_items_fts) DESC",
+	"-published": "published DESC",
+	"published":  "published ASC",
+	"-_score":    "score ASC",
+	"_score":     "score DESC",
 }
 
 func orderByClause(orderBy string) string {
 	if clause, ok := orderByClauses[orderBy]; ok {
 		return clause
 	}
-	return "i.published DESC"
-}
-
-// matchExpr renders a user query as an FTS5 MATCH expression over the stemmed
-// tokens held in the index, using lang's analyzer — the same one that stemmed
-// the rows it will be matched against. Tokens are quoted so that FTS5 operators
-// appearing in user input are treated as literal text.
-//
-// Reports false when the query carries no searchable terms — for example a
-// query of nothing but stop words. Callers must return no results in that case;
-// an empty MATCH expression is a syntax error.
-func matchExpr(lang string, query string, searchContent bool) (string, bool) {
-	tokens := search.Analyze(lang, query)
-	if len(tokens) == 0 {
-		return "", false
-	}
-	quoted := make([]string, len(tokens))
-	for i, token := range tokens {
-		quoted[i] = `"` + strings.ReplaceAll(token, `"`, `""`) + `"`
-	}
-	expr := "(" + strings.Join(quoted, " OR ") + ")"
-	if searchContent {
-		// Unqualified: matches either column.
-		return expr, true
-	}
-	return "{title} : " + expr, true
+	return "published DESC"
 }
 
 // publishedBetween appends the optional date range. published is TEXT with a
@@ -137,17 +178,61 @@ func publishedBetween(start *time.Time, end *time.Time) (string, []any) {
 	return clause.String(), args
 }
 
+// hoursBetween is publishedBetween for article_counts: the hours from start's
+// up to, but not including, end's. It reports false unless both are on the
+// hour, or nil.
+func hoursBetween(start *time.Time, end *time.Time) (string, []any, bool) {
+	for _, t := range []*time.Time{start, end} {
+		if t != nil && !t.Equal(t.Truncate(time.Hour)) {
+			return "", nil, false
+		}
+	}
+	clause := strings.Builder{}
+	args := []any{}
+	if start != nil {
+		clause.WriteString(" AND hour >= ?")
+		args = append(args, start.Unix())
+	}
+	if end != nil {
+		clause.WriteString(" AND hour < ?")
+		args = append(args, end.Unix())
+	}
+	return clause.String(), args, true
+}
+
+// inCategory appends the optional category filter. An item matches when any of
+// its categories equals category, ignoring case, since feeds are not consistent
+// about "Sport" and "sport".
+func inCategory(category string) (string, []any) {
+	if category == "" {
+		return "", nil
+	}
+	return " AND EXISTS (SELECT 1 FROM json_each(i.categories) WHERE json_each.value = ? COLLATE NOCASE)", []any{category}
+}
+
 // Note: FTS5 auxiliary functions such as bm25() must name the table directly,
 // so rss_items_fts is never aliased. rss_items is aliased as i.
 const searchFrom = " FROM rss_items_fts JOIN rss_items i ON i.id = rss_items_fts.rowid WHERE rss_items_fts MATCH ?"
 
-func (s *RssSearch) Search(ctx context.Context, lang string, query string, searchContent bool, start *time.Time, end *time.Time, orderBy string, limit int, offset int) ([]core.RssSearchResult, error) {
-	results := []core.RssSearchResult{}
-	expr, ok := matchExpr(lang, query, searchContent)
-	if !ok {
-		return results, nil
+// clusterKey names the duplicate cluster of an item. Items stored before
+// clustering have no cluster_id, and are each a cluster of their own.
+const clusterKey = "coalesce(i.cluster_id, i.id)"
+
+// countExpr counts the matches, or with collapse the clusters among them, so
+// that a story carried by several feeds of a site counts once.
+func countExpr(collapse bool) string {
+	if collapse {
+		return "count(DISTINCT " + clusterKey + ")"
 	}
-	siteClause, siteArgs, ok, err := s.siteFilter(ctx, lang)
+	return "count(*)"
+}
+
+// Search returns a page of the matches. With filter.CollapseDuplicates, each
+// cluster of duplicates among the matches is returned as its earliest item,
+// carrying the number of the others.
+func (s *RssSearch) Search(ctx context.Context, lang string, query string, searchContent bool, filter core.SearchFilter, start *time.Time, end *time.Time, orderBy string, limit int, offset int) ([]core.RssSearchResult, error) {
+	results := []core.RssSearchResult{}
+	compiled, ok, err := s.compileQuery(ctx, lang, query, searchContent)
 	if err != nil || !ok {
 		return results, err
 	}
@@ -156,41 +241,102 @@ func (s *RssSearch) Search(ctx context.Context, lang string, query string, searc
 		return results, err
 	}
 	rangeClause, args := publishedBetween(start, end)
-	sqlQuery := "SELECT i.item_id, i.title, i.content, i.link, i.published, i.site_id" + searchFrom + siteClause + rangeClause +
-		" ORDER BY " + orderByClause(orderBy) + " LIMIT ? OFFSET ?"
-	args = append(append([]any{expr}, siteArgs...), args...)
-	args = append(args, limit, offset)
+	categoryClause, categoryArgs := inCategory(filter.Category)
+	// The matches are materialized first: bm25() can only be evaluated in the
+	// query that runs the MATCH, not under the window functions that collapse.
+	// It is only evaluated when the results are ordered by it, as ranking every
+	// match costs more than the search itself.
+	score := "0"
+	if strings.Contains(orderByClause(orderBy), "score") {
+		score = "bm25(rss_items_fts)"
+	}
+	matches := "WITH matches AS MATERIALIZED (" +
+		"SELECT i.item_id, i.title, i.content, i.link, i.published, i.site_id, i.authors, i.categories, i.image_url, i.id, " +
+		clusterKey + " AS cluster, " + score + " AS score" + searchFrom + compiled.clause + rangeClause + categoryClause + ")"
+	source := "(SELECT *, 0 AS duplicates FROM matches)"
+	if filter.CollapseDuplicates {
+		source = "(SELECT *, row_number() OVER (PARTITION BY cluster ORDER BY published, id) AS cluster_rank, " +
+			"count(*) OVER (PARTITION BY cluster) - 1 AS duplicates FROM matches) WHERE cluster_rank = 1"
+	}
+	sqlQuery := matches + " SELECT item_id, title, content, link, published, site_id, authors, categories, image_url, duplicates FROM " +
+		source + " ORDER BY " + orderByClause(orderBy) + " LIMIT ? OFFSET ?"
+	args = append(append([]any{compiled.expr}, compiled.args...), args...)
+	args = append(append(args, categoryArgs...), limit, offset)
 
 	rows, err := dbConn.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
 		return results, fmt.Errorf("error searching: %w", err)
 	}
 	defer rows.Close()
+	terms := compiled.query.Terms(lang)
 	for rows.Next() {
 		var result core.RssSearchResult
-		var content, link *string
-		if err := rows.Scan(&result.ItemId, &result.Title, &content, &link, &result.Published, &result.SiteId); err != nil {
+		var content, link, authors, categories, imageUrl *string
+		if err := rows.Scan(&result.ItemId, &result.Title, &content, &link, &result.Published, &result.SiteId,
+			&authors, &categories, &imageUrl, &result.Duplicates); err != nil {
 			return results, fmt.Errorf("error scanning search result: %w", err)
 		}
 		if content != nil {
 			result.Content = *content
+			result.Snippet = snippet(lang, *content, terms)
 		}
 		if link != nil {
 			result.Link = *link
 		}
+		if imageUrl != nil {
+			result.ImageUrl = *imageUrl
+		}
+		if result.Authors, err = unmarshalStrings(authors); err != nil {
+			return results, fmt.Errorf("error reading authors of %v: %w", result.ItemId, err)
+		}
+		if result.Categories, err = unmarshalStrings(categories); err != nil {
+			return results, fmt.Errorf("error reading categories of %v: %w", result.ItemId, err)
+		}
 		results = append(results, result)
 	}
 	return results, rows.Err()
 }
 
-// CountByDay returns the number of matches per calendar day, oldest first.
-func (s *RssSearch) CountByDay(ctx context.Context, lang string, query string, searchContent bool, start *time.Time, end *time.Time) ([]core.SearchQueryCount, error) {
+// snippetRunes is about how long a search result's snippet is.
+const snippetRunes = 200
+
+// snippet cuts the snippet of a result out of its content, which is stored as
+// sanitized HTML text and so has its entities escaped.
+func snippet(lang string, content string, terms []search.Term) []core.SnippetPart {
+	fragments := search.Snippet(lang, html.UnescapeString(content), terms, snippetRunes)
+	if len(fragments) == 0 {
+		return nil
+	}
+	parts := make([]core.SnippetPart, len(fragments))
+	for i, fragment := range fragments {
+		parts[i] = core.SnippetPart{Text: fragment.Text, Match: fragment.Match}
+	}
+	return parts
+}
+
+// countSlot is the span matches are first counted in, before they are added up
+// into the chart's buckets in Go. SQLite knows no time zones but UTC and the
+// server's own, so the buckets of an edition's calendar cannot be cut in SQL.
+// Every zone's offset from UTC is a whole number of quarter hours, so a quarter
+// hour slot never straddles two buckets in any of them.
+const countSlot = 15 * time.Minute
+
+// CountByBucket returns the number of matches per bucket of time, oldest first.
+// A bucket is dated by its start, and days, weeks and months are the calendar's
+// in loc. A tracked term is summed from its term_counts, when they are in the
+// edition's calendar and the buckets are days or longer.
+func (s *RssSearch) CountByBucket(ctx context.Context, lang string, query string, searchContent bool, filter core.SearchFilter, start *time.Time, end *time.Time, bucket core.ChartBucket, loc *time.Location) ([]core.SearchQueryCount, error) {
 	counts := []core.SearchQueryCount{}
-	expr, ok := matchExpr(lang, query, searchContent)
-	if !ok {
-		return counts, nil
+	if bucket != core.BucketHour && loc != nil {
+		compiled, l, ok, err := s.rolledUp(ctx, lang, query, searchContent, filter, start, end)
+		if err != nil {
+			return counts, err
+		}
+		if ok && loc.String() == l.Location.String() {
+			return s.sumByBucket(ctx, compiled, query, start, end, bucket, loc)
+		}
 	}
-	siteClause, siteArgs, ok, err := s.siteFilter(ctx, lang)
+	compiled, ok, err := s.compileQuery(ctx, lang, query, searchContent)
 	if err != nil || !ok {
 		return counts, err
 	}
@@ -198,40 +344,61 @@ func (s *RssSearch) CountByDay(ctx context.Context, lang string, query string, s
 	if err != nil {
 		return counts, err
 	}
+	if loc == nil {
+		loc = time.UTC
+	}
 	rangeClause, args := publishedBetween(start, end)
-	sqlQuery := "SELECT date(i.published) AS day, count(*) AS count" + searchFrom + siteClause + rangeClause +
-		" GROUP BY day ORDER BY day ASC"
-	args = append(append([]any{expr}, siteArgs...), args...)
+	categoryClause, categoryArgs := inCategory(filter.Category)
+	slot := fmt.Sprintf("unixepoch(i.published) / %d * %d", int(countSlot.Seconds()), int(countSlot.Seconds()))
+	sqlQuery := "SELECT " + slot + " AS slot, count(*) AS count" + searchFrom + compiled.clause + rangeClause + categoryClause +
+		" GROUP BY slot ORDER BY slot ASC"
+	if filter.CollapseDuplicates {
+		// A cluster counts once, in the slot of its earliest match, which is
+		// the item a collapsed search shows for it. Counted per slot, it
+		// would count again in every bucket its copies spread over.
+		sqlQuery = "SELECT slot, count(*) AS count FROM (SELECT min(" + slot + ") AS slot" + searchFrom + compiled.clause + rangeClause + categoryClause +
+			" GROUP BY " + clusterKey + ") GROUP BY slot ORDER BY slot ASC"
+	}
+	args = append(append([]any{compiled.expr}, compiled.args...), args...)
+	args = append(args, categoryArgs...)
 
 	rows, err := dbConn.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return counts, fmt.Errorf("error counting by day: %w", err)
+		return counts, fmt.Errorf("error counting by %v: %w", bucket, err)
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var day string
+		var slot *int64
 		var count int
-		if err := rows.Scan(&day, &count); err != nil {
-			return counts, fmt.Errorf("error scanning day count: %w", err)
+		if err := rows.Scan(&slot, &count); err != nil {
+			return counts, fmt.Errorf("error scanning %v count: %w", bucket, err)
 		}
-		timestamp, err := time.Parse(time.DateOnly, day)
-		if err != nil {
-			slog.Warn("parsing day failed", "day", day, "error", err)
+		if slot == nil {
 			continue
 		}
-		counts = append(counts, core.SearchQueryCount{Timestamp: timestamp, Count: count})
+		// The slots come in order, so a bucket's slots are next to each other.
+		bucketStart := bucket.Truncate(time.Unix(*slot, 0).In(loc))
+		if last := len(counts) - 1; last >= 0 && counts[last].Timestamp.Equal(bucketStart) {
+			counts[last].Count += count
+			continue
+		}
+		counts = append(counts, core.SearchQueryCount{Timestamp: bucketStart, Count: count})
 	}
 	return counts, rows.Err()
 }
 
-// CountBySite returns the number of matches per site.
-func (s *RssSearch) CountBySite(ctx context.Context, lang string, query string, searchContent bool) ([]core.SiteCount, error) {
+// CountBySite returns the number of matches per site, within the optional date
+// range. A tracked term over whole days is summed from its term_counts.
+func (s *RssSearch) CountBySite(ctx context.Context, lang string, query string, searchContent bool, filter core.SearchFilter, start *time.Time, end *time.Time) ([]core.SiteCount, error) {
 	counts := []core.SiteCount{}
-	expr, ok := matchExpr(lang, query, searchContent)
-	if !ok {
-		return counts, nil
+	rolled, l, ok, err := s.rolledUp(ctx, lang, query, searchContent, filter, start, end)
+	if err != nil {
+		return counts, err
+	}
+	if ok {
+		return s.sumBySite(ctx, rolled, query, start, end, l.Location)
 	}
-	siteClause, siteArgs, ok, err := s.siteFilter(ctx, lang)
+	compiled, ok, err := s.compileQuery(ctx, lang, query, searchContent)
 	if err != nil || !ok {
 		return counts, err
 	}
@@ -239,9 +406,13 @@ func (s *RssSearch) CountBySite(ctx context.Context, lang string, query string,
 	if err != nil {
 		return counts, err
 	}
-	sqlQuery := "SELECT i.site_id, count(*) AS count" + searchFrom + siteClause + " GROUP BY i.site_id"
+	rangeClause, args := publishedBetween(start, end)
+	categoryClause, categoryArgs := inCategory(filter.Category)
+	sqlQuery := "SELECT i.site_id, " + countExpr(filter.CollapseDuplicates) + " AS count" + searchFrom + compiled.clause + rangeClause + categoryClause + " GROUP BY i.site_id"
+	args = append(append([]any{compiled.expr}, compiled.args...), args...)
+	args = append(args, categoryArgs...)
 
-	rows, err := dbConn.QueryContext(ctx, sqlQuery, append([]any{expr}, siteArgs...)...)
+	rows, err := dbConn.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
 		return counts, fmt.Errorf("error counting by site: %w", err)
 	}
@@ -256,30 +427,82 @@ func (s *RssSearch) CountBySite(ctx context.Context, lang string, query string,
 	return counts, rows.Err()
 }
 
+// CountArticlesBySite returns the number of items each of the sites published
+// within the optional date range, matching or not: what a rate of matches is
+// out of. The category and collapse filters narrow it as they narrow the
+// matches, so that the two are counted alike. A site with no items is left out.
+//
+// Without a filter, over whole hours, as the periods of the charts are, the
+// items are summed from article_counts, which then excludes end.
+func (s *RssSearch) CountArticlesBySite(ctx context.Context, siteIds []int, filter core.SearchFilter, start *time.Time, end *time.Time) (map[int]int, error) {
+	counts := make(map[int]int, len(siteIds))
+	if len(siteIds) == 0 {
+		return counts, nil
+	}
+	dbConn, err := db.Open(s.context.Config)
+	if err != nil {
+		return counts, err
+	}
+	args := make([]any, 0, len(siteIds))
+	for _, id := range siteIds {
+		args = append(args, id)
+	}
+	inSites := " IN (" + strings.TrimSuffix(strings.Repeat("?,", len(siteIds)), ",") + ")"
+	var sqlQuery string
+	if hoursClause, hoursArgs, ok := hoursBetween(start, end); ok && filter == (core.SearchFilter{}) {
+		sqlQuery = "SELECT site_id, sum(count) AS count FROM article_counts WHERE site_id" + inSites + hoursClause + " GROUP BY site_id HAVING sum(count) > 0"
+		args = append(args, hoursArgs...)
+	} else {
+		rangeClause, rangeArgs := publishedBetween(start, end)
+		categoryClause, categoryArgs := inCategory(filter.Category)
+		sqlQuery = "SELECT i.site_id, " + countExpr(filter.CollapseDuplicates) + " AS count FROM rss_items i WHERE i.site_id" + inSites +
+			rangeClause + categoryClause + " GROUP BY i.site_id"
+		args = append(append(args, rangeArgs...), categoryArgs...)
+	}
+
+	rows, err := dbConn.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return counts, fmt.Errorf("error counting articles by site: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var siteId, count int
+		if err := rows.Scan(&siteId, &count); err != nil {
+			return counts, fmt.Errorf("error scanning article count: %w", err)
+		}
+		counts[siteId] = count
+	}
+	return counts, rows.Err()
+}
+
 const rebuildBatchSize = 5000
 
 // Rebuild discards the index and reindexes every rss_item. It is the recovery
 // path after an analyzer change, since the stemmed tokens on disk are only
 // meaningful relative to the analyzer that produced them.
+// The term counts are regenerated from the new index after.
 //
 // Each row is re-stemmed in the language of the site that published it, not in
 // one language for the whole table: rebuilding everything as Danish would leave
 // the English edition matching nothing, silently.
-func (s *RssSearch) Rebuild(ctx context.Context) error {
+//
+// It returns the number of rows indexed and the number skipped for having no
+// known site.
+func (s *RssSearch) Rebuild(ctx context.Context) (int, int, error) {
 	dbConn, err := db.Open(s.context.Config)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	languages, err := s.siteLanguages(ctx)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	startTime := time.Now()
 	slog.Info("rebuilding search index")
 
 	// 'delete-all' is the FTS5 command for emptying a contentless table.
 	if _, err := dbConn.ExecContext(ctx, "INSERT INTO rss_items_fts(rss_items_fts) VALUES('delete-all')"); err != nil {
-		return fmt.Errorf("error clearing search index: %w", err)
+		return 0, 0, fmt.Errorf("error clearing search index: %w", err)
 	}
 
 	count, skipped := 0, 0
@@ -287,7 +510,7 @@ func (s *RssSearch) Rebuild(ctx context.Context) error {
 	for {
 		indexed, skippedInBatch, nextId, err := s.indexBatch(ctx, dbConn, languages, lastId)
 		if err != nil {
-			return err
+			return count, skipped, err
 		}
 		if indexed == 0 && skippedInBatch == 0 {
 			break
@@ -301,8 +524,14 @@ func (s *RssSearch) Rebuild(ctx context.Context) error {
 		"documents", count,
 		"duration_s", time.Since(startTime).Seconds(),
 		"skipped_no_known_site", skipped)
+	// The term counts are only as good as the index they were counted in.
+	terms, err := s.RollUpTerms(ctx, true)
+	if err != nil {
+		return count, skipped, err
+	}
+	slog.Info("rolled up tracked terms", "terms", terms)
 	s.RefreshMetrics()
-	return nil
+	return count, skipped, nil
 }
 
 // indexBatch indexes up to rebuildBatchSize rows with id > afterId, keyset
@@ -310,9 +539,9 @@ func (s *RssSearch) Rebuild(ctx context.Context) error {
 // number indexed, the number skipped, and the id to resume from.
 //
 // site_id is nullable (it was added by a later migration), and a row may also
-// name a site that no longer exists in rss.json. Such a row has no language, so
-// it cannot be stemmed — and siteFilter already excludes it from every search,
-// so indexing it would only add tokens nothing can ever match. Skip it, and
+// name a site that no longer exists in the sites table. Such a row has no
+// language, so it cannot be stemmed — and compileQuery already excludes it from
+// every search, so indexing it would only add tokens nothing can ever match. Skip it, and
 // report how many, rather than guessing at a language.
 func (s *RssSearch) indexBatch(ctx context.Context, dbConn *sql.DB, languages map[int]string, afterId int64) (int, int, int64, error) {
 	rows, err := dbConn.QueryContext(ctx,
@@ -2,6 +2,8 @@ package news
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"path/filepath"
 	"testing"
 	"time"
@@ -10,11 +12,12 @@ import (
 	"github.com/bjarke-xyz/rasende2/internal/core"
 	"github.com/bjarke-xyz/rasende2/internal/repository"
 	"github.com/bjarke-xyz/rasende2/internal/repository/db"
+	"github.com/bjarke-xyz/rasende2/internal/search"
 )
 
-// testSite and englishSite are real ids from rss.json: the site filter resolves
-// ids through the repository, so a made-up id would belong to no edition and be
-// filtered out of every search.
+// testSite and englishSite are real ids, seeded by the sites migration: the
+// site filter resolves ids through the repository, so a made-up id would belong
+// to no edition and be filtered out of every search.
 var testSite = core.NewsSite{Id: 1, Name: "Arbejderen", Language: "da"}
 var englishSite = core.NewsSite{Id: 23, Name: "BBC News", Language: "en"}
 
@@ -99,7 +102,7 @@ func TestSearchMatchesDanishInflections(t *testing.T) {
 	ctx := context.Background()
 
 	for _, query := range []string{"raser", "rasende", "rase"} {
-		results, err := rssSearch.Search(ctx, "da", query, false, nil, nil, "published", 10, 0)
+		results, err := rssSearch.Search(ctx, "da", query, false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 		if err != nil {
 			t.Fatalf("search %q: %v", query, err)
 		}
@@ -114,7 +117,7 @@ func TestSearchTitleOnlyExcludesContentMatches(t *testing.T) {
 	ctx := context.Background()
 
 	// "d" matches only in content, "c" not at all.
-	titleOnly, err := rssSearch.Search(ctx, "da", "rasende", false, nil, nil, "published", 10, 0)
+	titleOnly, err := rssSearch.Search(ctx, "da", "rasende", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("title-only search: %v", err)
 	}
@@ -122,7 +125,7 @@ func TestSearchTitleOnlyExcludesContentMatches(t *testing.T) {
 		t.Errorf("title-only = %v, want %v", got, want)
 	}
 
-	withContent, err := rssSearch.Search(ctx, "da", "rasende", true, nil, nil, "published", 10, 0)
+	withContent, err := rssSearch.Search(ctx, "da", "rasende", true, core.SearchFilter{}, nil, nil, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("content search: %v", err)
 	}
@@ -138,7 +141,7 @@ func TestSearchStopWordsOnlyReturnsEmpty(t *testing.T) {
 	rssSearch := newTestSearch(t, corpus(t))
 	ctx := context.Background()
 
-	results, err := rssSearch.Search(ctx, "da", "og i er det", false, nil, nil, "published", 10, 0)
+	results, err := rssSearch.Search(ctx, "da", "og i er det", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("stop word search returned error: %v", err)
 	}
@@ -146,12 +149,99 @@ func TestSearchStopWordsOnlyReturnsEmpty(t *testing.T) {
 		t.Errorf("stop word search = %v, want no results", itemIds(results))
 	}
 
-	counts, err := rssSearch.CountByDay(ctx, "da", "og i er det", false, nil, nil)
+	counts, err := rssSearch.CountByBucket(ctx, "da", "og i er det", false, core.SearchFilter{}, nil, nil, core.BucketDay, time.UTC)
 	if err != nil {
-		t.Fatalf("stop word CountByDay returned error: %v", err)
+		t.Fatalf("stop word CountByBucket returned error: %v", err)
 	}
 	if len(counts) != 0 {
-		t.Errorf("stop word CountByDay = %v, want none", counts)
+		t.Errorf("stop word CountByBucket = %v, want none", counts)
+	}
+}
+
+// Every construct of the query syntax has to compile to something FTS5
+// accepts, and find what it says.
+func TestSearchQuerySyntax(t *testing.T) {
+	rssSearch := newTestSearch(t, corpus(t))
+	ctx := context.Background()
+
+	tests := []struct {
+		query         string
+		searchContent bool
+		want          []string
+	}{
+		{`"rasende politiker"`, true, []string{"a"}},
+		{`"politiker rasende"`, true, []string{}},
+		{"raser NOT politiker", false, []string{"b"}},
+		{"rasende AND dessert", true, []string{"d"}},
+		{"title:rasende dessert", true, []string{"d", "a", "b"}},
+		{"title:dessert", true, []string{}},
+		{"minist*", false, []string{"a", "b"}},
+		{"rasende after:2024-03-02", false, []string{"b"}},
+		{"rasende before:2024-03-02", false, []string{"a"}},
+		{"rasende site:arbejderen", false, []string{"a", "b"}},
+		{"rasende site:BT", false, []string{}},
+	}
+	for _, tt := range tests {
+		results, err := rssSearch.Search(ctx, "da", tt.query, tt.searchContent, core.SearchFilter{}, nil, nil, "published", 10, 0)
+		if err != nil {
+			t.Errorf("search %q: %v", tt.query, err)
+			continue
+		}
+		if got := itemIds(results); !equal(got, tt.want) {
+			t.Errorf("search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// A site is found by name only in its own edition.
+	for _, query := range []string{"rasende site:nowhere", `rasende site:"BBC News"`, `"rasende`} {
+		var queryErr *search.QueryError
+		if _, err := rssSearch.Search(ctx, "da", query, false, core.SearchFilter{}, nil, nil, "published", 10, 0); !errors.As(err, &queryErr) {
+			t.Errorf("search %q = %v, want a query error", query, err)
+		}
+	}
+}
+
+// after: and before: are days of the edition's calendar: on the Danish edition,
+// an item from half past midnight Copenhagen time is on the day it says, though
+// it is the day before in UTC.
+func TestSearchDateFiltersUseEditionCalendar(t *testing.T) {
+	rssSearch := newTestSearch(t, []core.RssItemDto{
+		item(t, "edge", "Rasende ved midnat", "", "2024-03-01T00:30:00+01:00"),
+		item(t, "eve", "Rasende aften", "", "2024-02-29T23:30:00+01:00"),
+	})
+	ctx := context.Background()
+	tests := map[string][]string{
+		"rasende after:2024-03-01":  {"edge"},
+		"rasende before:2024-03-01": {"eve"},
+	}
+	for query, want := range tests {
+		results, err := rssSearch.Search(ctx, "da", query, false, core.SearchFilter{}, nil, nil, "published", 10, 0)
+		if err != nil {
+			t.Fatalf("search %q: %v", query, err)
+		}
+		if got := itemIds(results); !equal(got, want) {
+			t.Errorf("search(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+// The index cannot say where an item matched, so the snippet is cut from the
+// stored content in Go, with the inflected forms the search found marked.
+func TestSearchSnippets(t *testing.T) {
+	items := corpus(t)
+	items[3].Content = "Årets dessert: en rasende god &amp; kold rødgrød."
+	rssSearch := newTestSearch(t, items)
+
+	results, err := rssSearch.Search(context.Background(), "da", "raser", true, core.SearchFilter{}, nil, nil, "published", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got, want := itemIds(results), []string{"d", "a", "b"}; !equal(got, want) {
+		t.Fatalf("search = %v, want %v", got, want)
+	}
+	want := []core.SnippetPart{{Text: "Årets dessert: en "}, {Text: "rasende", Match: true}, {Text: " god & kold rødgrød."}}
+	if got := results[0].Snippet; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("snippet = %+v, want %+v", got, want)
 	}
 }
 
@@ -162,7 +252,7 @@ func TestSearchDateRangeAndOrdering(t *testing.T) {
 	start := mustTime(t, "2024-02-01T00:00:00Z")
 	end := mustTime(t, "2024-12-31T00:00:00Z")
 	// "d" is published in January and must fall outside the range.
-	results, err := rssSearch.Search(ctx, "da", "rasende", true, &start, &end, "published", 10, 0)
+	results, err := rssSearch.Search(ctx, "da", "rasende", true, core.SearchFilter{}, &start, &end, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("ranged search: %v", err)
 	}
@@ -170,7 +260,7 @@ func TestSearchDateRangeAndOrdering(t *testing.T) {
 		t.Errorf("ranged = %v, want %v", got, want)
 	}
 
-	descending, err := rssSearch.Search(ctx, "da", "rasende", false, nil, nil, "-published", 10, 0)
+	descending, err := rssSearch.Search(ctx, "da", "rasende", false, core.SearchFilter{}, nil, nil, "-published", 10, 0)
 	if err != nil {
 		t.Fatalf("descending search: %v", err)
 	}
@@ -179,7 +269,7 @@ func TestSearchDateRangeAndOrdering(t *testing.T) {
 	}
 
 	// Offset paginates rather than re-returning the first row.
-	page2, err := rssSearch.Search(ctx, "da", "rasende", false, nil, nil, "published", 1, 1)
+	page2, err := rssSearch.Search(ctx, "da", "rasende", false, core.SearchFilter{}, nil, nil, "published", 1, 1)
 	if err != nil {
 		t.Fatalf("paged search: %v", err)
 	}
@@ -192,16 +282,16 @@ func TestCountsAggregate(t *testing.T) {
 	rssSearch := newTestSearch(t, corpus(t))
 	ctx := context.Background()
 
-	byDay, err := rssSearch.CountByDay(ctx, "da", "rasende", true, nil, nil)
+	byDay, err := rssSearch.CountByBucket(ctx, "da", "rasende", true, core.SearchFilter{}, nil, nil, core.BucketDay, time.UTC)
 	if err != nil {
-		t.Fatalf("CountByDay: %v", err)
+		t.Fatalf("CountByBucket: %v", err)
 	}
 	if len(byDay) != 3 {
-		t.Fatalf("CountByDay returned %d days, want 3: %v", len(byDay), byDay)
+		t.Fatalf("CountByBucket returned %d days, want 3: %v", len(byDay), byDay)
 	}
 	// Oldest first: d (Jan 5), a (Mar 1), b (Mar 2).
 	if !byDay[0].Timestamp.Before(byDay[1].Timestamp) {
-		t.Errorf("CountByDay not ordered oldest first: %v", byDay)
+		t.Errorf("CountByBucket not ordered oldest first: %v", byDay)
 	}
 	for _, day := range byDay {
 		if day.Count != 1 {
@@ -209,7 +299,7 @@ func TestCountsAggregate(t *testing.T) {
 		}
 	}
 
-	bySite, err := rssSearch.CountBySite(ctx, "da", "rasende", true)
+	bySite, err := rssSearch.CountBySite(ctx, "da", "rasende", true, core.SearchFilter{}, nil, nil)
 	if err != nil {
 		t.Fatalf("CountBySite: %v", err)
 	}
@@ -218,6 +308,166 @@ func TestCountsAggregate(t *testing.T) {
 	}
 }
 
+// Weeks start on Monday and months on the first, and the range bounds the
+// buckets counted: a (Fri Mar 1) and b (Sat Mar 2) share a week, d (Jan 5) is
+// weeks before.
+func TestCountsByWeekAndMonth(t *testing.T) {
+	rssSearch := newTestSearch(t, corpus(t))
+	ctx := context.Background()
+
+	counted := func(counts []core.SearchQueryCount) []string {
+		got := make([]string, len(counts))
+		for i, count := range counts {
+			got[i] = fmt.Sprintf("%v=%v", count.Timestamp.Format(time.DateOnly), count.Count)
+		}
+		return got
+	}
+	byWeek, err := rssSearch.CountByBucket(ctx, "da", "rasende", true, core.SearchFilter{}, nil, nil, core.BucketWeek, time.UTC)
+	if err != nil {
+		t.Fatalf("CountByBucket: %v", err)
+	}
+	if got, want := counted(byWeek), []string{"2024-01-01=1", "2024-02-26=2"}; !equal(got, want) {
+		t.Errorf("by week = %v, want %v", got, want)
+	}
+	start := mustTime(t, "2024-02-01T00:00:00Z")
+	byMonth, err := rssSearch.CountByBucket(ctx, "da", "rasende", true, core.SearchFilter{}, &start, nil, core.BucketMonth, time.UTC)
+	if err != nil {
+		t.Fatalf("CountByBucket: %v", err)
+	}
+	if got, want := counted(byMonth), []string{"2024-03-01=2"}; !equal(got, want) {
+		t.Errorf("by month from February = %v, want %v", got, want)
+	}
+}
+
+// Days are the edition's: a headline from half past midnight in Copenhagen
+// counts on that day there, and on the day before in London and in UTC. The
+// offset the feed gave the time in makes no difference.
+func TestCountsByDayInZone(t *testing.T) {
+	items := []core.RssItemDto{
+		item(t, "late", "Rasende sent", "", "2024-03-01T23:30:00Z"),
+		item(t, "early", "Rasende tidligt", "", "2024-03-02T07:00:00+01:00"),
+		item(t, "summer", "Rasende sommer", "", "2024-06-30T22:10:00Z"),
+	}
+	rssSearch := newTestSearch(t, items)
+	ctx := context.Background()
+
+	var tests = []struct {
+		zone string
+		want []string
+	}{
+		{"Europe/Copenhagen", []string{"2024-03-02T00:00:00+01:00=2", "2024-07-01T00:00:00+02:00=1"}},
+		{"Europe/London", []string{"2024-03-01T00:00:00Z=1", "2024-03-02T00:00:00Z=1", "2024-06-30T00:00:00+01:00=1"}},
+		{"UTC", []string{"2024-03-01T00:00:00Z=1", "2024-03-02T00:00:00Z=1", "2024-06-30T00:00:00Z=1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.zone)
+			if err != nil {
+				t.Fatalf("load zone: %v", err)
+			}
+			byDay, err := rssSearch.CountByBucket(ctx, "da", "rasende", false, core.SearchFilter{}, nil, nil, core.BucketDay, loc)
+			if err != nil {
+				t.Fatalf("CountByBucket: %v", err)
+			}
+			got := make([]string, len(byDay))
+			for i, day := range byDay {
+				got[i] = fmt.Sprintf("%v=%v", day.Timestamp.Format(time.RFC3339), day.Count)
+			}
+			if !equal(got, tt.want) {
+				t.Errorf("by day = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A category narrows the matches to the items filed under it, and the counts
+// behind the charts with them. Feeds disagree about case, so the filter does not
+// care about it.
+func TestSearchFiltersByCategory(t *testing.T) {
+	items := corpus(t)
+	items[0].Categories = []string{"Politik"}
+	items[1].Categories = []string{"Sport", "Politik"}
+	items[3].Categories = []string{"Mad"}
+	rssSearch := newTestSearch(t, items)
+	ctx := context.Background()
+
+	results, err := rssSearch.Search(ctx, "da", "rasende", true, core.SearchFilter{Category: "politik"}, nil, nil, "published", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got, want := itemIds(results), []string{"a", "b"}; !equal(got, want) {
+		t.Errorf("in politik = %v, want %v", got, want)
+	}
+	if got := results[1].Categories; !equal(got, []string{"Sport", "Politik"}) {
+		t.Errorf("categories of b = %v, want [Sport Politik]", got)
+	}
+
+	bySite, err := rssSearch.CountBySite(ctx, "da", "rasende", true, core.SearchFilter{Category: "sport"}, nil, nil)
+	if err != nil {
+		t.Fatalf("CountBySite: %v", err)
+	}
+	if len(bySite) != 1 || bySite[0].Count != 1 {
+		t.Errorf("CountBySite in sport = %v, want one entry with count 1", bySite)
+	}
+	byDay, err := rssSearch.CountByBucket(ctx, "da", "rasende", true, core.SearchFilter{Category: "Kultur"}, nil, nil, core.BucketDay, time.UTC)
+	if err != nil {
+		t.Fatalf("CountByBucket: %v", err)
+	}
+	if len(byDay) != 0 {
+		t.Errorf("CountByBucket in an unused category = %v, want none", byDay)
+	}
+}
+
+// Copies of a story fall into one cluster, whether they share a canonical link or
+// a near-identical title, and collapsing shows and counts each cluster once.
+func TestSearchCollapsesDuplicates(t *testing.T) {
+	items := corpus(t)
+	items[1].CanonicalLink = "https://example.dk/minister-raser"
+	retitled := item(t, "e", "RASENDE politiker råber ad ministeren!", "", "2024-03-01T12:00:00Z")
+	relinked := item(t, "f", "Ny rasende kritik af ministeren", "", "2024-03-02T11:00:00Z")
+	relinked.CanonicalLink = items[1].CanonicalLink
+	rssSearch := newTestSearch(t, append(items, retitled, relinked))
+	ctx := context.Background()
+
+	all, err := rssSearch.Search(ctx, "da", "rasende", true, core.SearchFilter{}, nil, nil, "published", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got, want := itemIds(all), []string{"d", "a", "e", "b", "f"}; !equal(got, want) {
+		t.Errorf("uncollapsed = %v, want %v", got, want)
+	}
+
+	collapsed, err := rssSearch.Search(ctx, "da", "rasende", true, core.SearchFilter{CollapseDuplicates: true}, nil, nil, "published", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got, want := itemIds(collapsed), []string{"d", "a", "b"}; !equal(got, want) {
+		t.Fatalf("collapsed = %v, want %v", got, want)
+	}
+	for i, want := range []int{0, 1, 1} {
+		if got := collapsed[i].Duplicates; got != want {
+			t.Errorf("duplicates of %v = %d, want %d", collapsed[i].ItemId, got, want)
+		}
+	}
+
+	bySite, err := rssSearch.CountBySite(ctx, "da", "rasende", true, core.SearchFilter{CollapseDuplicates: true}, nil, nil)
+	if err != nil {
+		t.Fatalf("CountBySite: %v", err)
+	}
+	if len(bySite) != 1 || bySite[0].Count != 3 {
+		t.Errorf("collapsed CountBySite = %v, want one entry with count 3", bySite)
+	}
+	byDay, err := rssSearch.CountByBucket(ctx, "da", "rasende", true, core.SearchFilter{CollapseDuplicates: true}, nil, nil, core.BucketDay, time.UTC)
+	if err != nil {
+		t.Fatalf("CountByBucket: %v", err)
+	}
+	for _, day := range byDay {
+		if day.Count != 1 {
+			t.Errorf("collapsed day %v count = %d, want 1", day.Timestamp, day.Count)
+		}
+	}
+}
+
 // Indexing happens inside the InsertItems transaction, so a re-fetch that
 // re-inserts the same items (on conflict do nothing) must not duplicate index rows.
 func TestReinsertDoesNotDuplicateIndexRows(t *testing.T) {
@@ -230,7 +480,7 @@ func TestReinsertDoesNotDuplicateIndexRows(t *testing.T) {
 		t.Fatalf("re-insert: %v", err)
 	}
 
-	results, err := rssSearch.Search(ctx, "da", "rasende", false, nil, nil, "published", 10, 0)
+	results, err := rssSearch.Search(ctx, "da", "rasende", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("search after re-insert: %v", err)
 	}
@@ -243,7 +493,7 @@ func TestRebuildRepopulatesIndex(t *testing.T) {
 	rssSearch := newTestSearch(t, corpus(t))
 	ctx := context.Background()
 
-	if err := rssSearch.Rebuild(ctx); err != nil {
+	if _, _, err := rssSearch.Rebuild(ctx); err != nil {
 		t.Fatalf("rebuild: %v", err)
 	}
 	empty, err := rssSearch.IsEmpty(ctx)
@@ -253,7 +503,7 @@ func TestRebuildRepopulatesIndex(t *testing.T) {
 	if empty {
 		t.Fatal("index is empty after rebuild")
 	}
-	results, err := rssSearch.Search(ctx, "da", "raser", false, nil, nil, "published", 10, 0)
+	results, err := rssSearch.Search(ctx, "da", "raser", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("search after rebuild: %v", err)
 	}
@@ -310,7 +560,7 @@ func TestSearchIsIsolatedPerLanguage(t *testing.T) {
 		})
 	ctx := context.Background()
 
-	danish, err := rssSearch.Search(ctx, "da", "rasende", false, nil, nil, "published", 10, 0)
+	danish, err := rssSearch.Search(ctx, "da", "rasende", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("danish search: %v", err)
 	}
@@ -318,7 +568,7 @@ func TestSearchIsIsolatedPerLanguage(t *testing.T) {
 		t.Errorf("danish search = %v, want %v", got, want)
 	}
 
-	english, err := rssSearch.Search(ctx, "en", "outrage", false, nil, nil, "published", 10, 0)
+	english, err := rssSearch.Search(ctx, "en", "outrage", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("english search: %v", err)
 	}
@@ -339,7 +589,7 @@ func TestSearchMatchesEnglishInflections(t *testing.T) {
 	ctx := context.Background()
 
 	for _, query := range []string{"outrage", "outraged", "outrages"} {
-		results, err := rssSearch.Search(ctx, "en", query, false, nil, nil, "published", 10, 0)
+		results, err := rssSearch.Search(ctx, "en", query, false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 		if err != nil {
 			t.Fatalf("search %q: %v", query, err)
 		}
@@ -358,11 +608,11 @@ func TestRebuildKeepsBothLanguagesSearchable(t *testing.T) {
 		[]core.RssItemDto{englishItem(t, "en1", "Public outrage as prices rise", "Furious.", "2024-03-04T10:00:00Z")})
 	ctx := context.Background()
 
-	if err := rssSearch.Rebuild(ctx); err != nil {
+	if _, _, err := rssSearch.Rebuild(ctx); err != nil {
 		t.Fatalf("rebuild: %v", err)
 	}
 
-	danish, err := rssSearch.Search(ctx, "da", "raser", false, nil, nil, "published", 10, 0)
+	danish, err := rssSearch.Search(ctx, "da", "raser", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("danish search: %v", err)
 	}
@@ -370,7 +620,7 @@ func TestRebuildKeepsBothLanguagesSearchable(t *testing.T) {
 		t.Errorf("after rebuild, danish search = %v, want %v", got, want)
 	}
 
-	english, err := rssSearch.Search(ctx, "en", "outraged", false, nil, nil, "published", 10, 0)
+	english, err := rssSearch.Search(ctx, "en", "outraged", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
 	if err != nil {
 		t.Fatalf("english search: %v", err)
 	}
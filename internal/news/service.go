@@ -5,14 +5,14 @@ import (
 	"context"
 	"crypto/md5"
 	"database/sql"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"math/rand"
-	"net/http"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -29,10 +29,13 @@ import (
 )
 
 type RssService struct {
-	context    *core.AppContext
-	repository core.NewsRepository
-	sanitizer  *bluemonday.Policy
-	search     *RssSearch
+	context        *core.AppContext
+	repository     core.NewsRepository
+	sanitizer      *bluemonday.Policy
+	search         *RssSearch
+	articleLimiter *hostLimiter
+	source         FeedSource
+	jobLocks       jobLocks
 }
 
 var (
@@ -52,11 +55,19 @@ var (
 )
 
 func NewRssService(context *core.AppContext, repository core.NewsRepository, search *RssSearch) core.NewsService {
+	source := newFeedSource(context.Config)
+	articleInterval := articleHostInterval
+	if _, replaying := source.(*replaySource); replaying {
+		// A replay asks no one, so there is no one to be polite to.
+		articleInterval = 0
+	}
 	return &RssService{
-		context:    context,
-		repository: repository,
-		sanitizer:  bluemonday.StrictPolicy(),
-		search:     search,
+		context:        context,
+		repository:     repository,
+		sanitizer:      bluemonday.StrictPolicy(),
+		search:         search,
+		articleLimiter: newHostLimiter(articleInterval),
+		source:         source,
 	}
 }
 
@@ -70,11 +81,11 @@ func (r *RssService) GetIndexPageData(ctx context.Context, l lang.Lang) (*core.I
 	indexPageData := &core.IndexPageData{}
 
 	chartsPromise := pkg.NewPromise(func() (core.ChartsResult, error) {
-		chartData, err := r.GetChartData(ctx, l, query)
+		chartData, err := r.GetChartData(ctx, l, []string{query}, core.SearchFilter{}, core.DefaultChartPeriod(time.Now(), l.Location))
 		return chartData, err
 	})
 
-	results, err := r.SearchItems(ctx, l, query, searchContent, offset, limit, orderBy)
+	results, err := r.SearchItems(ctx, l, query, searchContent, core.SearchFilter{}, offset, limit, orderBy)
 	if err != nil {
 		slog.Error("getting items failed", "query", query, "error", err)
 		return &core.IndexPageData{}, err
@@ -95,56 +106,210 @@ func (r *RssService) GetIndexPageData(ctx context.Context, l lang.Lang) (*core.I
 	return indexPageData, nil
 }
 
-// GetChartData builds the two charts for a query. The edition's own word gets
-// the editorial titles ("Den seneste uges raserier"); anything else the visitor
-// typed gets neutral ones naming the query back to them.
-func (r *RssService) GetChartData(ctx context.Context, l lang.Lang, query string) (core.ChartsResult, error) {
-	isDefaultQuery := query == l.DefaultQuery
+// GetChartData builds the charts for the terms, over period: their matches over
+// time, their split across the sites, and each site's rate of them. Each term
+// is counted in parallel with the others, and gets its own dataset.
+//
+// A single term is charted as the search page always has: the edition's own word
+// over the default week gets the editorial titles ("Den seneste uges
+// raserier"); anything else gets neutral ones naming the query and the period
+// back to the visitor, and the category too when the search was limited to one.
+// Several terms are compared, with the sites' matches stacked per term.
+func (r *RssService) GetChartData(ctx context.Context, l lang.Lang, terms []string, filter core.SearchFilter, period core.ChartPeriod) (core.ChartsResult, error) {
+	if len(terms) == 0 {
+		return core.ChartsResult{}, nil
+	}
+	start, end := chartBounds(period)
 
-	siteCountPromise := pkg.NewPromise(func() ([]core.SiteCount, error) {
-		return r.GetSiteCountForSearchQuery(ctx, l, query, false)
+	siteCountPromises := make([]*pkg.Promise[[]core.SiteCount], len(terms))
+	for i, term := range terms {
+		siteCountPromises[i] = pkg.NewPromise(func() ([]core.SiteCount, error) {
+			return r.GetSiteCountForSearchQuery(ctx, l, term, false, filter, start, end)
+		})
+	}
+	articlesPromise := pkg.NewPromise(func() (siteArticles, error) {
+		return r.siteArticles(ctx, l, filter, start, end)
 	})
 
-	now := time.Now()
-	sevenDaysAgo := now.Add(-time.Hour * 24 * 6)
-	tomorrow := now.Add(time.Hour * 24)
-	itemCount, err := r.GetItemCountForSearchQuery(ctx, l, query, false, &sevenDaysAgo, &tomorrow, "published")
+	period = period.Fit(period.Start)
+	itemCounts, err := r.countTerms(ctx, l, terms, filter, start, end, period.Bucket)
 	if err != nil {
-		slog.Error("getting items failed", "query", query, "error", err)
+		slog.Error("getting items failed", "terms", terms, "error", err)
 		return core.ChartsResult{}, err
 	}
+	// A period from the oldest match only knows how long it is once counted.
+	if period.Start.IsZero() {
+		if first, ok := firstCount(itemCounts); ok {
+			if fitted := period.Fit(first); fitted.Bucket != period.Bucket {
+				period = fitted
+				itemCounts, err = r.countTerms(ctx, l, terms, filter, start, end, period.Bucket)
+				if err != nil {
+					slog.Error("getting items failed", "terms", terms, "error", err)
+					return core.ChartsResult{}, err
+				}
+			}
+		}
+	}
 
-	siteCount, err := siteCountPromise.Get()
+	siteCounts := make([][]core.SiteCount, len(terms))
+	for i, promise := range siteCountPromises {
+		if siteCounts[i], err = promise.Get(); err != nil {
+			slog.Error("getting site count failed", "terms", terms, "error", err)
+			return core.ChartsResult{}, err
+		}
+	}
+	articles, err := articlesPromise.Get()
 	if err != nil {
-		slog.Error("getting site count failed", "query", query, "error", err)
+		slog.Error("getting site rates failed", "terms", terms, "error", err)
 		return core.ChartsResult{}, err
 	}
+	siteRates := make([][]core.SiteRate, len(terms))
+	for i := range terms {
+		siteRates[i] = articles.rates(siteCounts[i])
+	}
 
+	if len(terms) > 1 {
+		return compareCharts(l, terms, filter, period, itemCounts, siteCounts, siteRates), nil
+	}
+	query := terms[0]
+	isDefaultQuery := query == l.DefaultQuery && filter.Category == ""
 	lineTitle := l.T("chart.line.title")
 	lineDatasetLabel := l.T("chart.line.dataset")
 	doughnutTitle := l.T("chart.pie.title")
+	barTitle := l.T("chart.bar.title")
 	if !isDefaultQuery {
 		lineTitle = l.T("chart.line.titleQuery", query)
 		lineDatasetLabel = l.T("chart.line.datasetQuery", query)
 		doughnutTitle = l.T("chart.pie.titleQuery", query)
+		barTitle = l.T("chart.bar.titleQuery", query)
+	}
+	if filter.Category != "" {
+		lineTitle = l.T("chart.line.titleCategory", query, filter.Category)
+		doughnutTitle = l.T("chart.pie.titleCategory", query, filter.Category)
+		barTitle = l.T("chart.bar.titleCategory", query, filter.Category)
+	}
+	if period.Range != core.DefaultRange {
+		during := chartRangeText(l, period)
+		switch {
+		case filter.Category != "":
+			lineTitle = l.T("chart.line.titleCategoryRange", query, filter.Category, during)
+		case isDefaultQuery:
+			lineTitle = l.T("chart.line.titleRange", during)
+		default:
+			lineTitle = l.T("chart.line.titleQueryRange", query, during)
+		}
 	}
 	chartsResult := core.ChartsResult{
 		Charts: []core.ChartResult{
-			core.MakeLineChartFromSearchQueryCount(itemCount, lineTitle, lineDatasetLabel),
-			core.MakeDoughnutChartFromSiteCount(siteCount, doughnutTitle),
+			core.MakeLineChartFromSearchQueryCounts([]core.ChartSeries{{Label: lineDatasetLabel, Counts: itemCounts[0]}}, period, lineTitle),
+			core.MakeDoughnutChartFromSiteCount(siteCounts[0], doughnutTitle),
+			core.MakeBarChartFromSiteRates(siteRates, []string{l.T("chart.bar.dataset")}, barTitle),
 		},
 	}
 	return chartsResult, nil
 }
 
+// compareCharts is GetChartData's charts for several terms: a line per term, the
+// sites' matches stacked per term, and the sites' rates side by side.
+func compareCharts(l lang.Lang, terms []string, filter core.SearchFilter, period core.ChartPeriod, itemCounts [][]core.SearchQueryCount, siteCounts [][]core.SiteCount, siteRates [][]core.SiteRate) core.ChartsResult {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = "'" + term + "'"
+	}
+	compared := l.T("chart.compare.list", strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
+	if filter.Category != "" {
+		compared = l.T("chart.compare.inCategory", compared, filter.Category)
+	}
+	lineTitle := l.T("chart.line.titleCompare", compared)
+	if period.Range != core.DefaultRange {
+		lineTitle = l.T("chart.line.titleCompareRange", compared, chartRangeText(l, period))
+	}
+	series := make([]core.ChartSeries, len(terms))
+	for i, term := range terms {
+		series[i] = core.ChartSeries{Label: l.T("chart.line.datasetQuery", term), Counts: itemCounts[i]}
+	}
+	return core.ChartsResult{
+		Charts: []core.ChartResult{
+			core.MakeLineChartFromSearchQueryCounts(series, period, lineTitle),
+			core.MakeStackedBarChartFromSiteCounts(terms, siteCounts, l.T("chart.sites.titleCompare", compared)),
+			core.MakeBarChartFromSiteRates(siteRates, terms, l.T("chart.bar.titleCompare", compared)),
+		},
+	}
+}
+
+// countTerms counts the matches of each term per bucket, all at once.
+func (r *RssService) countTerms(ctx context.Context, l lang.Lang, terms []string, filter core.SearchFilter, start *time.Time, end *time.Time, bucket core.ChartBucket) ([][]core.SearchQueryCount, error) {
+	promises := make([]*pkg.Promise[[]core.SearchQueryCount], len(terms))
+	for i, term := range terms {
+		promises[i] = pkg.NewPromise(func() ([]core.SearchQueryCount, error) {
+			return r.GetItemCountForSearchQuery(ctx, l, term, false, filter, start, end, bucket)
+		})
+	}
+	counts := make([][]core.SearchQueryCount, len(terms))
+	var errs []error
+	for i, promise := range promises {
+		var err error
+		counts[i], err = promise.Get()
+		errs = append(errs, err)
+	}
+	return counts, errors.Join(errs...)
+}
+
+// firstCount is the earliest bucket any of the terms has a count in.
+func firstCount(counts [][]core.SearchQueryCount) (time.Time, bool) {
+	var first time.Time
+	for _, termCounts := range counts {
+		if len(termCounts) > 0 && (first.IsZero() || termCounts[0].Timestamp.Before(first)) {
+			first = termCounts[0].Timestamp
+		}
+	}
+	return first, !first.IsZero()
+}
+
+// chartBounds is the date range the charts of period count matches in.
+func chartBounds(period core.ChartPeriod) (*time.Time, *time.Time) {
+	end := period.End
+	if period.Start.IsZero() {
+		return nil, &end
+	}
+	start := period.Start
+	return &start, &end
+}
+
+// chartRangeText names the period in a chart title: "de seneste 30 dage".
+func chartRangeText(l lang.Lang, period core.ChartPeriod) string {
+	if period.Range == core.RangeCustom {
+		return l.T("chart.range.custom", period.Start.Format(time.DateOnly), period.End.AddDate(0, 0, -1).Format(time.DateOnly))
+	}
+	return l.T("chart.range." + string(period.Range))
+}
+
 func (r *RssService) Initialise(ctx context.Context) {
+	// The sites are validated as they load, so loading them now reports a site
+	// with a broken source at startup rather than at its first fetch.
+	if _, err := r.repository.GetSites(ctx); err != nil {
+		slog.Error("loading sites failed", "error", err)
+	}
+
+	r.failInterruptedJobRuns(ctx)
+
 	// The migration creates rss_items_fts empty. Backfill it once, in the
 	// background, so a fresh database becomes searchable without operator action.
 	indexEmpty, err := r.search.IsEmpty(ctx)
 	if err != nil {
 		slog.Error("checking search index failed", "error", err)
 	} else if indexEmpty {
-		go r.RebuildSearchIndexAndLogError(context.Background())
+		if _, err := r.StartRebuildSearchIndex(context.Background()); err != nil {
+			slog.Error("starting search index rebuild failed", "error", err)
+		}
+	} else {
+		// A term added to TRACKED_TERMS is charted through the index until it
+		// has been counted from it once; one taken out loses its rollup.
+		go func() {
+			if _, err := r.search.RollUpTerms(context.WithoutCancel(ctx), false); err != nil {
+				slog.Error("rolling up tracked terms failed", "error", err)
+			}
+		}()
 	}
 
 	err = r.RefreshMetrics(ctx)
@@ -156,12 +321,6 @@ func (r *RssService) Initialise(ctx context.Context) {
 func (r *RssService) Dispose() {
 }
 
-func (r *RssService) RebuildSearchIndexAndLogError(ctx context.Context) {
-	if err := r.search.Rebuild(ctx); err != nil {
-		slog.Error("rebuilding search index failed", "error", err)
-	}
-}
-
 func (r *RssService) RefreshMetrics(ctx context.Context) error {
 	rssUrls, err := r.repository.GetSites(ctx)
 	if err != nil {
@@ -177,12 +336,27 @@ func (r *RssService) RefreshMetrics(ctx context.Context) error {
 			rssArticleCount.WithLabelValues(rssUrl.Name).Set(float64(articleCount))
 		}
 	}
+	if err := r.refreshFeedHealthMetrics(ctx); err != nil {
+		return err
+	}
 	r.search.RefreshMetrics()
 	return nil
 }
 
-func getItemId(item *gofeed.Item) string {
-	str := item.Title + ":" + item.Link
+// getItemId identifies an item by what stays put when the outlet edits it: its
+// GUID, or failing that its canonical link. Only an item with neither falls back to
+// hashing the title, as every item used to. The site is part of the hash because
+// GUIDs are only unique within a feed, and often just a number.
+func getItemId(siteId int, item *gofeed.Item) string {
+	var str string
+	switch {
+	case item.GUID != "":
+		str = fmt.Sprintf("%v:guid:%v", siteId, item.GUID)
+	case item.Link != "":
+		str = fmt.Sprintf("%v:link:%v", siteId, canonicalLink(item.Link))
+	default:
+		str = item.Title + ":" + item.Link
+	}
 	bytes := []byte(str)
 	hashedBytes := md5.Sum(bytes)
 	hashStr := fmt.Sprintf("%x", hashedBytes)
@@ -196,12 +370,18 @@ func (r *RssService) convertToDto(feedItem *gofeed.Item, rssUrl core.NewsSite) c
 		published = &now
 	}
 	return core.RssItemDto{
-		ItemId:    getItemId(feedItem),
-		SiteName:  rssUrl.Name,
-		Title:     feedItem.Title,
-		Content:   strings.TrimSpace(r.sanitizer.Sanitize(feedItem.Content)),
-		Link:      feedItem.Link,
-		Published: *published,
+		ItemId:        getItemId(rssUrl.Id, feedItem),
+		SiteName:      rssUrl.Name,
+		Title:         feedItem.Title,
+		Content:       strings.TrimSpace(r.sanitizer.Sanitize(feedItem.Content)),
+		Link:          cleanLink(feedItem.Link),
+		FeedLink:      feedItem.Link,
+		CanonicalLink: canonicalLink(feedItem.Link),
+		Published:     *published,
+		Authors:       itemAuthors(feedItem),
+		Categories:    itemCategories(feedItem),
+		ImageUrl:      itemImage(feedItem),
+		Guid:          feedItem.GUID,
 	}
 }
 func (r *RssService) GetSiteNames(ctx context.Context) ([]string, error) {
@@ -246,12 +426,16 @@ func (r *RssService) GetSiteInfoById(ctx context.Context, id int) (*core.NewsSit
 	return nil, nil
 }
 
-func (r *RssService) SearchItems(ctx context.Context, l lang.Lang, query string, searchContent bool, offset int, limit int, orderBy string) ([]core.RssSearchResult, error) {
+// maxQueryLength is the longest query searched. It leaves room for a phrase or
+// two and the filters of the query syntax, see search.Query.
+const maxQueryLength = 100
+
+func (r *RssService) SearchItems(ctx context.Context, l lang.Lang, query string, searchContent bool, filter core.SearchFilter, offset int, limit int, orderBy string) ([]core.RssSearchResult, error) {
 	var items []core.RssSearchResult = []core.RssSearchResult{}
-	if len(query) > 50 || len(query) <= 2 {
+	if len(query) > maxQueryLength || len(query) <= 2 {
 		return items, nil
 	}
-	items, err := r.search.Search(ctx, string(l.Code), query, searchContent, nil, nil, orderBy, limit, offset)
+	items, err := r.search.Search(ctx, string(l.Code), query, searchContent, filter, nil, nil, orderBy, limit, offset)
 	if err != nil {
 		return items, fmt.Errorf("failed to search: %w", err)
 	}
@@ -259,24 +443,24 @@ func (r *RssService) SearchItems(ctx context.Context, l lang.Lang, query string,
 	return items, nil
 }
 
-func (r *RssService) GetItemCountForSearchQuery(ctx context.Context, l lang.Lang, query string, searchContent bool, start *time.Time, end *time.Time, orderBy string) ([]core.SearchQueryCount, error) {
+func (r *RssService) GetItemCountForSearchQuery(ctx context.Context, l lang.Lang, query string, searchContent bool, filter core.SearchFilter, start *time.Time, end *time.Time, bucket core.ChartBucket) ([]core.SearchQueryCount, error) {
 	searchQueryCounts := make([]core.SearchQueryCount, 0)
-	if len(query) > 50 || len(query) <= 2 {
+	if len(query) > maxQueryLength || len(query) <= 2 {
 		return searchQueryCounts, nil
 	}
-	searchQueryCounts, err := r.search.CountByDay(ctx, string(l.Code), query, searchContent, start, end)
+	searchQueryCounts, err := r.search.CountByBucket(ctx, string(l.Code), query, searchContent, filter, start, end, bucket, l.Location)
 	if err != nil {
 		return searchQueryCounts, fmt.Errorf("failed to search: %w", err)
 	}
 	return searchQueryCounts, nil
 }
 
-func (r *RssService) GetSiteCountForSearchQuery(ctx context.Context, l lang.Lang, query string, searchContent bool) ([]core.SiteCount, error) {
+func (r *RssService) GetSiteCountForSearchQuery(ctx context.Context, l lang.Lang, query string, searchContent bool, filter core.SearchFilter, start *time.Time, end *time.Time) ([]core.SiteCount, error) {
 	var items []core.SiteCount = []core.SiteCount{}
-	if len(query) > 50 || len(query) <= 2 {
+	if len(query) > maxQueryLength || len(query) <= 2 {
 		return items, nil
 	}
-	items, err := r.search.CountBySite(ctx, string(l.Code), query, searchContent)
+	items, err := r.search.CountBySite(ctx, string(l.Code), query, searchContent, filter, start, end)
 	if err != nil {
 		return items, fmt.Errorf("failed to search: %w", err)
 	}
@@ -287,144 +471,399 @@ func (r *RssService) GetSiteCountForSearchQuery(ctx context.Context, l lang.Lang
 	return items, nil
 }
 
-func (r *RssService) fetchAndSaveNewItemsForSite(ctx context.Context, rssUrl core.NewsSite) error {
+// GetSiteRates returns the rate of matches per core.RatePer articles for every
+// site of the edition that published at least core.MinRateArticles items in
+// the date range, highest rate first.
+func (r *RssService) GetSiteRates(ctx context.Context, l lang.Lang, query string, filter core.SearchFilter, start *time.Time, end *time.Time) ([]core.SiteRate, error) {
+	siteCounts, err := r.GetSiteCountForSearchQuery(ctx, l, query, false, filter, start, end)
+	if err != nil {
+		return []core.SiteRate{}, err
+	}
+	articles, err := r.siteArticles(ctx, l, filter, start, end)
+	if err != nil {
+		return []core.SiteRate{}, err
+	}
+	return articles.rates(siteCounts), nil
+}
+
+// siteArticles is what the edition's sites published in a period, which every
+// term's rates are out of.
+type siteArticles struct {
+	sites  []core.NewsSite
+	counts map[int]int
+}
+
+func (r *RssService) siteArticles(ctx context.Context, l lang.Lang, filter core.SearchFilter, start *time.Time, end *time.Time) (siteArticles, error) {
+	sites, err := r.GetSiteInfos(ctx, l)
+	if err != nil {
+		return siteArticles{}, err
+	}
+	siteIds := make([]int, len(sites))
+	for i, site := range sites {
+		siteIds[i] = site.Id
+	}
+	counts, err := r.search.CountArticlesBySite(ctx, siteIds, filter, start, end)
+	if err != nil {
+		return siteArticles{}, fmt.Errorf("failed to count articles: %w", err)
+	}
+	return siteArticles{sites: sites, counts: counts}, nil
+}
+
+// rates turns one term's matches per site into rates, highest first.
+func (a siteArticles) rates(siteCounts []core.SiteCount) []core.SiteRate {
+	matches := make(map[int]int, len(siteCounts))
+	for _, siteCount := range siteCounts {
+		matches[siteCount.SiteId] = siteCount.Count
+	}
+	rates := []core.SiteRate{}
+	for _, site := range a.sites {
+		if a.counts[site.Id] < core.MinRateArticles {
+			continue
+		}
+		rates = append(rates, core.SiteRate{
+			SiteId:   site.Id,
+			SiteName: site.Name,
+			Matches:  matches[site.Id],
+			Articles: a.counts[site.Id],
+			Rate:     float64(matches[site.Id]) * core.RatePer / float64(a.counts[site.Id]),
+		})
+	}
+	slices.SortFunc(rates, func(a, b core.SiteRate) int {
+		return cmp.Or(cmp.Compare(b.Rate, a.Rate), cmp.Compare(a.SiteName, b.SiteName))
+	})
+	return rates
+}
+
+// feedResult is the outcome of fetching and parsing one of a site's feeds. Each
+// feed succeeds or fails on its own, so one broken feed of a site with several
+// does not cost the site the items of the others.
+type feedResult struct {
+	url         string
+	items       []core.RssItemDto
+	notModified bool
+	validators  core.FeedValidators
+	err         error
+}
+
+// fetchAndSaveNewItemsForSite fetches the site's feeds, stores the new items and
+// revises the stored ones the outlet has edited since. It returns how many items
+// were new and how many were revised.
+func (r *RssService) fetchAndSaveNewItemsForSite(ctx context.Context, rssUrl core.NewsSite) (int, int, error) {
 	now := time.Now()
-	fromFeed, err := r.parse(rssUrl)
+	healthByUrl, err := r.feedHealthByUrl(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get feed health for %v: %w", rssUrl.Name, err)
+	}
+	urls := make([]string, 0, len(rssUrl.Urls))
+	for _, url := range rssUrl.Urls {
+		if health, ok := healthByUrl[url]; ok && health.BackingOff(now) {
+			slog.Debug("fetch and save new items: feed is backing off", "site", rssUrl.Name, "url", url, "until", health.NextFetchAt)
+			continue
+		}
+		urls = append(urls, url)
+	}
+	if len(urls) == 0 {
+		return 0, 0, nil
+	}
+	validators, err := r.repository.GetFeedValidators(ctx, urls)
 	if err != nil {
-		return fmt.Errorf("failed to get items from feed %v: %w", rssUrl.Name, err)
+		return 0, 0, fmt.Errorf("failed to get feed validators for %v: %w", rssUrl.Name, err)
 	}
+	results := r.parse(ctx, rssUrl, urls, validators)
 	slog.Debug("fetch and save new items: parsed", "site", rssUrl.Name, "duration_ms", float64(time.Since(now).Microseconds())/1000)
 
-	fromFeedItemIds := make([]string, len(fromFeed))
-	for i, fromFeedItem := range fromFeed {
-		fromFeedItemIds[i] = fromFeedItem.ItemId
-	}
+	fromFeed := mergeFeedItems(results)
 
 	dbNow := time.Now()
-	existingIds, err := r.repository.GetExistingItemsByIds(ctx, fromFeedItemIds)
+	existing, err := r.repository.GetExistingItems(ctx, rssUrl.Id, fromFeed)
 	if err != nil {
-		return fmt.Errorf("failed to get items for %v: %w", rssUrl.Name, err)
+		return 0, 0, fmt.Errorf("failed to get items for %v: %w", rssUrl.Name, err)
 	}
 	toInsert := make([]core.RssItemDto, 0)
+	revised := 0
+	// Two items from the feed can lead to the same stored item through its link,
+	// when the feed now gives them different GUIDs. Only the first gets it.
+	matched := make(map[string]bool)
 	for _, item := range fromFeed {
-		_, exists := existingIds[item.ItemId]
-		isBlockedTitle, err := rssUrl.IsBlockedTitle(item.Title)
+		item.InsertedAt = &now
+		item.SiteId = rssUrl.Id
+		if stored, exists := existing[item.ItemId]; exists && !matched[stored.ItemId] {
+			matched[stored.ItemId] = true
+			if extracts(rssUrl) {
+				// The stored content came from the article page, the feed's is the
+				// teaser. Comparing them would record a change on every run.
+				item.Content = stored.Content
+			}
+			if stored.ItemId == item.ItemId && stored.Title == item.Title && stored.Content == item.Content {
+				continue
+			}
+			wasRevised, err := r.repository.ReviseItem(ctx, rssUrl, stored.ItemId, item)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to revise item for %v: %w", rssUrl.Name, err)
+			}
+			if wasRevised {
+				slog.Debug("fetch and save new items: item revised", "site", rssUrl.Name, "title", item.Title, "was", stored.Title)
+				revised++
+			}
+			continue
+		}
+		isBlocked, err := rssUrl.Blocks(item)
 		if err != nil {
-			return fmt.Errorf("error checking if title is blocked: %w", err)
+			return 0, 0, fmt.Errorf("error checking content rules: %w", err)
 		}
-		if !exists && !isBlockedTitle {
-			item.InsertedAt = &now
-			item.SiteId = rssUrl.Id
+		if !isBlocked {
 			toInsert = append(toInsert, item)
 		}
 	}
 
-	slog.Debug("fetch and save new items: inserted", "site", rssUrl.Name, "count", len(toInsert), "duration_ms", float64(time.Since(dbNow).Microseconds())/1000)
+	slog.Debug("fetch and save new items: inserted", "site", rssUrl.Name, "count", len(toInsert), "revised", revised, "duration_ms", float64(time.Since(dbNow).Microseconds())/1000)
 	// InsertItems indexes each new row into rss_items_fts in the same transaction.
 	articleCount, err := r.repository.InsertItems(ctx, rssUrl, toInsert)
 	if err != nil {
-		return fmt.Errorf("failed to insert items for %v: %w", rssUrl.Name, err)
+		return 0, 0, fmt.Errorf("failed to insert items for %v: %w", rssUrl.Name, err)
 	}
 	if articleCount > 0 {
 		rssArticleCount.WithLabelValues(rssUrl.Name).Set(float64(articleCount))
 	}
-	return nil
+	if extracted := r.extractArticles(ctx, rssUrl, toInsert); extracted > 0 {
+		slog.Debug("fetch and save new items: extracted articles", "site", rssUrl.Name, "count", extracted)
+	}
+
+	// Only now that the items are stored: saving the validators first would turn a
+	// failed insert into a 304 on the next run, and those items would never be seen.
+	freshValidators := make(map[string]core.FeedValidators)
+	for _, result := range results {
+		if result.err == nil && !result.notModified {
+			freshValidators[result.url] = result.validators
+		}
+	}
+	if err := r.repository.SaveFeedValidators(ctx, freshValidators); err != nil {
+		return 0, 0, fmt.Errorf("failed to save feed validators for %v: %w", rssUrl.Name, err)
+	}
+
+	var errs []error
+	for _, result := range results {
+		health := healthByUrl[result.url]
+		health.Url = result.url
+		health.SiteId = rssUrl.Id
+		health.SiteName = rssUrl.Name
+		if result.err != nil {
+			health = feedFailed(health, result.err, now)
+			errs = append(errs, result.err)
+		} else {
+			health = feedSucceeded(health, now)
+		}
+		r.saveFeedHealth(ctx, health)
+	}
+	return len(toInsert), revised, errors.Join(errs...)
+}
+
+// mergeFeedItems flattens the items of the feeds that succeeded. A site's feeds
+// overlap — a front page feed and a section feed carry the same story — so only
+// the first copy of each item is kept.
+func mergeFeedItems(results []feedResult) []core.RssItemDto {
+	merged := make([]core.RssItemDto, 0)
+	seenIds := make(map[string]bool)
+	for _, result := range results {
+		for _, item := range result.items {
+			if !seenIds[item.ItemId] {
+				merged = append(merged, item)
+				seenIds[item.ItemId] = true
+			}
+		}
+	}
+	return merged
 }
 
 func (r *RssService) GetSites(ctx context.Context) ([]core.NewsSite, error) {
 	return r.repository.GetSites(ctx)
 }
 
-func (r *RssService) FetchAndSaveNewItems(ctx context.Context) error {
-	rssUrls, err := r.repository.GetSites(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get rss urls: %w", err)
+// checkUserAgentKey rejects a user agent key that getContent would silently
+// ignore, which would leave the site fetched with Go's default user agent.
+func checkUserAgentKey(site core.NewsSite) error {
+	if _, ok := userAgents[site.UserAgentKey]; site.UserAgentKey != "" && !ok {
+		return fmt.Errorf("site %q has unknown user agent key %q", site.Name, site.UserAgentKey)
 	}
-	var wg sync.WaitGroup
-	for _, rssUrl := range rssUrls {
-		if (len(rssUrl.Urls)) == 0 {
-			slog.Warn("not getting items: urls list is empty", "site", rssUrl.Name)
-			continue
+	return nil
+}
+
+func (r *RssService) CreateSite(ctx context.Context, site core.NewsSite) (int, error) {
+	if err := checkUserAgentKey(site); err != nil {
+		return 0, err
+	}
+	return r.repository.CreateSite(ctx, site)
+}
+
+func (r *RssService) UpdateSite(ctx context.Context, site core.NewsSite) error {
+	if err := checkUserAgentKey(site); err != nil {
+		return err
+	}
+	return r.repository.UpdateSite(ctx, site)
+}
+
+// TestFetch runs url through the same fetch and parse as a real run would for
+// site, and returns the items without saving them. It sends no cache validators,
+// so the feed always comes back in full. If the site is extracted, the first
+// item's content is extracted too, to show what the selectors pick out.
+func (r *RssService) TestFetch(ctx context.Context, site core.NewsSite, url string) ([]core.RssItemDto, error) {
+	result := r.parse(ctx, site, []string{url}, nil)[0]
+	if result.err != nil {
+		return nil, result.err
+	}
+	items := result.items
+	if extracts(site) && len(items) > 0 {
+		article, err := r.extractArticle(ctx, site, items[0].Link)
+		if err != nil {
+			return items, fmt.Errorf("extracting %v: %w", items[0].Link, err)
+		}
+		items[0].Content = article.text
+		if article.canonicalLink != "" {
+			items[0].CanonicalLink = article.canonicalLink
 		}
-		wg.Add(1)
-		rssUrl := rssUrl
-		go func() {
-			defer wg.Done()
-			siteErr := r.fetchAndSaveNewItemsForSite(ctx, rssUrl)
-			if siteErr != nil {
-				slog.Error("fetch and save new items for site failed", "site", rssUrl.Name, "error", siteErr)
-			}
-		}()
 	}
-	wg.Wait()
-	// No index reconciliation needed: InsertItems indexes each new row in the same
-	// transaction that inserts it.
-	return nil
+	return items, nil
 }
 
-func (r *RssService) parse(rssUrl core.NewsSite) ([]core.RssItemDto, error) {
-	contents, err := r.getContents(rssUrl)
+// MatchContentRule returns the site's stored items that rule keeps out, newest
+// first: those a block rule matches, and those an allow rule does not. It is the
+// dry run of PurgeContentRule, and lists exactly what that would delete.
+func (r *RssService) MatchContentRule(ctx context.Context, site core.NewsSite, rule core.ContentRule) ([]core.RssItemDto, error) {
+	items, err := r.repository.GetSiteItems(ctx, site.Id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get content for site %v: %w", rssUrl.Name, err)
+		return nil, err
 	}
-	parsed := make([]core.RssItemDto, 0)
-	fp := gofeed.NewParser()
-	seenIds := make(map[string]bool)
-	for _, content := range contents {
-		feed, err := fp.ParseString(content)
+	keptOut := make([]core.RssItemDto, 0)
+	for _, item := range items {
+		matches, err := rule.Matches(item)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse site %v: %w", rssUrl.Name, err)
+			return nil, err
 		}
-
-		for _, item := range feed.Items {
-			dto := r.convertToDto(item, rssUrl)
-			_, hasSeen := seenIds[dto.ItemId]
-			if !hasSeen {
-				parsed = append(parsed, dto)
-				seenIds[dto.ItemId] = true
-			}
+		if matches == (rule.Action == core.RuleBlock) {
+			keptOut = append(keptOut, item)
 		}
 	}
-	return parsed, nil
-
+	return keptOut, nil
 }
 
-var userAgents = map[string]string{
-	"chrome": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/128.0.0.0 Safari/537.36",
+// PurgeContentRule deletes the site's stored items that rule keeps out, and
+// returns how many it deleted. It is how a new rule is applied to what was
+// stored before it.
+func (r *RssService) PurgeContentRule(ctx context.Context, site core.NewsSite, rule core.ContentRule) (int, error) {
+	keptOut, err := r.MatchContentRule(ctx, site, rule)
+	if err != nil {
+		return 0, err
+	}
+	itemIds := make([]string, len(keptOut))
+	for i, item := range keptOut {
+		itemIds[i] = item.ItemId
+	}
+	return r.repository.DeleteItems(ctx, site, itemIds)
 }
 
-func (r *RssService) getContents(rssUrl core.NewsSite) ([]string, error) {
-	contents := make([]string, 0)
-	for _, url := range rssUrl.Urls {
-		req, err := http.NewRequest(http.MethodGet, url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-		if rssUrl.UserAgentKey != "" {
-			userAgent, ok := userAgents[rssUrl.UserAgentKey]
-			if ok {
-				req.Header.Set("User-Agent", userAgent)
+// FetchAndSaveNewItems fetches every site, fetchWorkers of them at a time. A
+// site that fails is logged, counted and left for the next run. Once ctx is
+// cancelled, the sites not yet started are skipped and the requests in flight
+// are abandoned.
+//
+// The run fails in three ways: when the sites cannot be listed, when ctx is
+// cancelled before every site was fetched, and when every site fetched failed.
+// Some sites failing alone does not fail it.
+func (r *RssService) FetchAndSaveNewItems(ctx context.Context) error {
+	run, err := r.startJobRun(ctx, core.JobFetchItems)
+	if err != nil {
+		return err
+	}
+	rssUrls, err := r.repository.GetSites(ctx)
+	if err != nil {
+		return run.finish(ctx, fmt.Errorf("failed to get rss urls: %w", err))
+	}
+	queue := make(chan core.NewsSite)
+	var wg sync.WaitGroup
+	var fetched, failed atomic.Int32
+	for range r.fetchWorkers() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rssUrl := range queue {
+				inserted, revised, siteErr := r.fetchAndSaveNewItemsForSite(ctx, rssUrl)
+				fetched.Add(1)
+				run.add("inserted."+rssUrl.Name, inserted)
+				run.add("inserted", inserted)
+				run.add("revised", revised)
+				if siteErr != nil {
+					failed.Add(1)
+					run.add("failedSites", 1)
+					slog.Error("fetch and save new items for site failed", "site", rssUrl.Name, "error", siteErr)
+				}
 			}
+		}()
+	}
+queueSites:
+	for _, rssUrl := range rssUrls {
+		if rssUrl.Disabled {
+			continue
 		}
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("error getting %v: %w", url, err)
+		if (len(rssUrl.Urls)) == 0 {
+			slog.Warn("not getting items: urls list is empty", "site", rssUrl.Name)
+			continue
+		}
+		select {
+		case queue <- rssUrl:
+		case <-ctx.Done():
+			break queueSites
+		}
+	}
+	close(queue)
+	wg.Wait()
+	// No index reconciliation needed: InsertItems indexes each new row in the same
+	// transaction that inserts it.
+	//
+	// A run cut short, or one where every site failed, is a failed run: recorded
+	// as a success, it would hide an outage in the job history.
+	switch {
+	case ctx.Err() != nil:
+		return run.finish(ctx, fmt.Errorf("fetch interrupted after %v sites: %w", fetched.Load(), ctx.Err()))
+	case fetched.Load() > 0 && failed.Load() == fetched.Load():
+		return run.finish(ctx, fmt.Errorf("all %v sites failed", fetched.Load()))
+	}
+	return run.finish(ctx, nil)
+}
+
+// parse fetches and parses each of urls, which belong to rssUrl. validators are
+// the stored cache validators per URL; a feed that answers 304 to them is
+// notModified and has no items. Pass nil to always fetch in full.
+func (r *RssService) parse(ctx context.Context, rssUrl core.NewsSite, urls []string, validators map[string]core.FeedValidators) []feedResult {
+	results := make([]feedResult, 0, len(urls))
+	for _, url := range urls {
+		result := feedResult{url: url}
+		resp, err := r.source.Fetch(ctx, rssUrl, url, validators[url])
+		if resp.StatusCode != 0 {
+			// A 304 is counted under its own status_code, so the ratio of 304s to
+			// 200s shows how much the conditional fetch saves.
+			rssFetchStatusCodes.WithLabelValues(fmt.Sprintf("%v", resp.StatusCode), rssUrl.Name, url).Inc()
 		}
-		rssFetchStatusCodes.WithLabelValues(fmt.Sprintf("%v", resp.StatusCode), rssUrl.Name, url).Inc()
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, fmt.Errorf("error reading body of %v: %w", url, err)
+			result.err = fmt.Errorf("failed to get content for site %v: %w", rssUrl.Name, err)
+			results = append(results, result)
+			continue
 		}
-		bodyStr := string(body)
-		if resp.StatusCode > 299 {
-			slog.Warn("unexpected status fetching feed", "url", url, "status", resp.StatusCode, "headers", fmt.Sprintf("%v", resp.Header), "body", bodyStr)
-			return nil, fmt.Errorf("error getting %v, returned error code %v", url, resp.StatusCode)
+		result.notModified = resp.NotModified
+		result.validators = resp.Validators
+		if !resp.NotModified {
+			feedItems, err := parseItems(rssUrl, resp)
+			if err != nil {
+				result.err = fmt.Errorf("failed to parse site %v: %w", rssUrl.Name, err)
+				results = append(results, result)
+				continue
+			}
+			for _, item := range feedItems {
+				result.items = append(result.items, r.convertToDto(item, rssUrl))
+			}
 		}
-		contents = append(contents, bodyStr)
+		results = append(results, result)
 	}
-	return contents, nil
+	return results
 }
 
 func (r *RssService) GetRecentTitles(ctx context.Context, siteInfo core.NewsSite, limit int, shuffle bool) ([]string, error) {
@@ -449,6 +888,17 @@ func (r *RssService) GetRecentTitles(ctx context.Context, siteInfo core.NewsSite
 func (r *RssService) GetRecentItems(ctx context.Context, siteId int, limit int, insertedAtOffset *time.Time) ([]core.RssItemDto, error) {
 	return r.repository.GetRecentItems(ctx, siteId, limit, insertedAtOffset)
 }
+
+// GetItem returns the item, or nil if there is none by that id.
+func (r *RssService) GetItem(ctx context.Context, itemId string) (*core.RssItemDto, error) {
+	return r.repository.GetItem(ctx, itemId)
+}
+
+// GetItemRevisions returns every version the item has had, oldest first, or
+// none if it was never changed.
+func (r *RssService) GetItemRevisions(ctx context.Context, itemId string) ([]core.RssItemRevision, error) {
+	return r.repository.GetItemRevisions(ctx, itemId)
+}
 func (r *RssService) GetPopularFakeNews(ctx context.Context, limit int, publishedAfter *time.Time, votes int) ([]core.FakeNewsDto, error) {
 	return r.repository.GetPopularFakeNews(ctx, limit, publishedAfter, votes)
 }
@@ -488,7 +938,17 @@ func (r *RssService) CleanUpFakeNewsAndLogError(ctx context.Context) {
 	}
 }
 
+// CleanUpFakeNews deletes the fake news that was never highlighted, and then the
+// images in S3 that no remaining fake news refers to.
 func (r *RssService) CleanUpFakeNews(ctx context.Context) error {
+	run, err := r.startJobRun(ctx, core.JobCleanFakeNews)
+	if err != nil {
+		return err
+	}
+	return run.finish(ctx, r.cleanUpFakeNews(ctx, run))
+}
+
+func (r *RssService) cleanUpFakeNews(ctx context.Context, run *jobRun) error {
 	const batchSize = 100
 	client, err := storage.NewImageClientFromConfig(ctx, r.context.Config)
 	if err != nil {
@@ -502,10 +962,13 @@ func (r *RssService) CleanUpFakeNews(ctx context.Context) error {
 	publicBaseUrl := r.context.Config.S3ImagePublicBaseUrl
 	var continuationToken *string = nil
 
-	_, err = db.ExecContext(ctx, "DELETE FROM fake_news WHERE highlighted = 0")
+	result, err := db.ExecContext(ctx, "DELETE FROM fake_news WHERE highlighted = 0")
 	if err != nil {
 		return fmt.Errorf("failed to delete non-highlighted from fake_news: %w", err)
 	}
+	if deleted, err := result.RowsAffected(); err == nil {
+		run.add("deletedFakeNews", int(deleted))
+	}
 
 	for {
 		// List objects in S3 bucket with pagination
@@ -530,20 +993,22 @@ func (r *RssService) CleanUpFakeNews(ctx context.Context) error {
 
 			// If batch size is reached, process the batch
 			if len(batch) >= batchSize {
-				err = processBatch(ctx, client, db, bucket, batch)
+				deleted, err := processBatch(ctx, client, db, bucket, batch)
 				if err != nil {
 					return fmt.Errorf("failed to process batch: %w", err)
 				}
+				run.add("deletedImages", deleted)
 				batch = batch[:0] // Clear the batch
 			}
 		}
 
 		// Process any remaining items in the last batch
 		if len(batch) > 0 {
-			err = processBatch(ctx, client, db, bucket, batch)
+			deleted, err := processBatch(ctx, client, db, bucket, batch)
 			if err != nil {
 				return fmt.Errorf("failed to process batch: %w", err)
 			}
+			run.add("deletedImages", deleted)
 		}
 
 		// Break if there are no more objects to process
@@ -567,7 +1032,9 @@ type imageObject struct {
 	url string
 }
 
-func processBatch(ctx context.Context, client *s3.Client, db *sql.DB, bucket string, batch []imageObject) error {
+// processBatch deletes the objects in batch that no fake news refers to, and
+// returns how many it deleted.
+func processBatch(ctx context.Context, client *s3.Client, db *sql.DB, bucket string, batch []imageObject) (int, error) {
 	// Prepare the SQL query
 	placeholders := strings.Repeat("?,", len(batch))
 	placeholders = placeholders[:len(placeholders)-1] // Remove the trailing comma
@@ -583,7 +1050,7 @@ func processBatch(ctx context.Context, client *s3.Client, db *sql.DB, bucket str
 	// Execute the query
 	rows, err := db.Query(query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to get img urls from db: %w", err)
+		return 0, fmt.Errorf("failed to get img urls from db: %w", err)
 	}
 	defer rows.Close()
 
@@ -592,15 +1059,16 @@ func processBatch(ctx context.Context, client *s3.Client, db *sql.DB, bucket str
 	for rows.Next() {
 		var url string
 		if err := rows.Scan(&url); err != nil {
-			return fmt.Errorf("failed to scan url: %w", err)
+			return 0, fmt.Errorf("failed to scan url: %w", err)
 		}
 		existingURLs[url] = struct{}{}
 	}
 	if err := rows.Err(); err != nil {
-		return fmt.Errorf("failed to read img urls: %w", err)
+		return 0, fmt.Errorf("failed to read img urls: %w", err)
 	}
 
 	// Identify and delete orphaned S3 objects
+	deleted := 0
 	for _, obj := range batch {
 		if _, ok := existingURLs[obj.url]; !ok {
 			_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
@@ -611,8 +1079,9 @@ func processBatch(ctx context.Context, client *s3.Client, db *sql.DB, bucket str
 				slog.Warn("deleting object failed", "key", obj.key, "error", err)
 			} else {
 				slog.Debug("deleted object", "key", obj.key)
+				deleted++
 			}
 		}
 	}
-	return nil
+	return deleted, nil
 }
@@ -0,0 +1,750 @@
+package news
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/lang"
+	"github.com/bjarke-xyz/rasende2/internal/repository"
+	"github.com/bjarke-xyz/rasende2/internal/repository/db"
+)
+
+// newTestService builds an RssService over a migrated, empty database.
+func newTestService(t *testing.T) *RssService {
+	t.Helper()
+	cfg := &config.Config{DbConnStr: filepath.Join(t.TempDir(), "test.db")}
+	conn, err := db.Open(cfg)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.Migrate("up", conn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	appContext := &core.AppContext{Config: cfg}
+	repo := repository.NewSqliteNews(appContext)
+	return NewRssService(appContext, repo, NewRssSearch(appContext, repo)).(*RssService)
+}
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Test</title>
+<item><title>Rasende borgere</title><link>https://example.dk/1</link><pubDate>Mon, 06 Jan 2025 10:00:00 +0000</pubDate></item>
+<item><title>Vrede politikere</title><link>https://example.dk/2</link><pubDate>Mon, 06 Jan 2025 11:00:00 +0000</pubDate></item>
+</channel></rss>`
+
+// The second fetch must send back the ETag of the first, and the 304 it gets
+// must be taken as "nothing new" rather than an error or an empty feed.
+func TestFetchSendsConditionalGet(t *testing.T) {
+	var fullResponses, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 06 Jan 2025 11:00:00 GMT")
+		w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	service := newTestService(t)
+	site := testSite
+	site.Urls = []string{server.URL}
+	ctx := context.Background()
+
+	for range 2 {
+		if _, _, err := service.fetchAndSaveNewItemsForSite(ctx, site); err != nil {
+			t.Fatalf("fetch: %v", err)
+		}
+	}
+	if fullResponses.Load() != 1 || notModified.Load() != 1 {
+		t.Errorf("full responses = %v, not modified = %v, want 1 and 1", fullResponses.Load(), notModified.Load())
+	}
+
+	items, err := service.GetRecentItems(ctx, site.Id, 10, nil)
+	if err != nil {
+		t.Fatalf("recent items: %v", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("got %v items, want 2", len(items))
+	}
+
+	validators, err := service.repository.GetFeedValidators(ctx, site.Urls)
+	if err != nil {
+		t.Fatalf("validators: %v", err)
+	}
+	if got := validators[server.URL]; got.ETag != `"v1"` || got.LastModified != "Mon, 06 Jan 2025 11:00:00 GMT" {
+		t.Errorf("stored validators = %+v", got)
+	}
+}
+
+// However many workers fetch at once, no host gets more than its share of the
+// requests in flight.
+func TestFetchCapsRequestsPerHost(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	service := newTestService(t)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.source.Fetch(ctx, testSite, fmt.Sprintf("%v/feed/%v", server.URL, i), core.FeedValidators{}); err != nil {
+				t.Errorf("fetch: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := maxInFlight.Load(); got > defaultFetchPerHost {
+		t.Errorf("%v requests in flight at once, want at most %v", got, defaultFetchPerHost)
+	}
+}
+
+// A feed server that never answers must not hold the job past its context.
+func TestFetchGivesUpWhenCancelled(t *testing.T) {
+	stop := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-stop:
+		}
+	}))
+	defer server.Close()
+	defer close(stop)
+
+	service := newTestService(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := service.source.Fetch(ctx, testSite, server.URL, core.FeedValidators{})
+	if err == nil {
+		t.Fatal("fetch of a hung feed succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("fetch gave up after %v", elapsed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{backoffAfterFailures - 1, 0},
+		{backoffAfterFailures, backoffBase},
+		{backoffAfterFailures + 1, 2 * backoffBase},
+		{backoffAfterFailures + 2, 4 * backoffBase},
+		{backoffAfterFailures + 100, backoffMax},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%v) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// A broken feed is skipped while it backs off, and one success after that is
+// enough to bring it back to normal.
+func TestFeedBacksOffAndRecovers(t *testing.T) {
+	var broken atomic.Bool
+	broken.Store(true)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if broken.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	service := newTestService(t)
+	site := testSite
+	site.Urls = []string{server.URL}
+	ctx := context.Background()
+
+	for range backoffAfterFailures {
+		if _, _, err := service.fetchAndSaveNewItemsForSite(ctx, site); err == nil {
+			t.Fatal("fetch of a broken feed succeeded")
+		}
+	}
+	// Backing off now: this run must not reach the server at all.
+	if _, _, err := service.fetchAndSaveNewItemsForSite(ctx, site); err != nil {
+		t.Fatalf("fetch while backing off: %v", err)
+	}
+	if got := requests.Load(); got != backoffAfterFailures {
+		t.Errorf("server saw %v requests, want %v", got, backoffAfterFailures)
+	}
+	feeds, err := service.repository.GetFeedHealth(ctx)
+	if err != nil {
+		t.Fatalf("feed health: %v", err)
+	}
+	health := findFeed(t, feeds, server.URL)
+	if health.ConsecutiveFailures != backoffAfterFailures || !health.BackingOff(time.Now()) {
+		t.Fatalf("health = %+v, want %v failures and backing off", health, backoffAfterFailures)
+	}
+
+	// Pretend the backoff has run out, and the feed has come back.
+	past := time.Now().Add(-time.Minute)
+	health.NextFetchAt = &past
+	if err := service.repository.SaveFeedHealth(ctx, health); err != nil {
+		t.Fatalf("save health: %v", err)
+	}
+	broken.Store(false)
+	if _, _, err := service.fetchAndSaveNewItemsForSite(ctx, site); err != nil {
+		t.Fatalf("fetch after recovery: %v", err)
+	}
+	feeds, err = service.repository.GetFeedHealth(ctx)
+	if err != nil {
+		t.Fatalf("feed health: %v", err)
+	}
+	health = findFeed(t, feeds, server.URL)
+	if health.ConsecutiveFailures != 0 || health.NextFetchAt != nil || health.LastSuccessAt == nil {
+		t.Errorf("health after recovery = %+v", health)
+	}
+}
+
+func findFeed(t *testing.T, feeds []core.FeedHealth, url string) core.FeedHealth {
+	t.Helper()
+	for _, feed := range feeds {
+		if feed.Url == url {
+			return feed
+		}
+	}
+	t.Fatalf("no health record for %v", url)
+	return core.FeedHealth{}
+}
+
+// A rebuild is recorded as running when it starts, and can be polled until it
+// records how it ended.
+func TestRebuildIsRecordedAsJobRun(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	items := []core.RssItemDto{
+		item(t, "a", "Rasende borgere", "", "2025-01-06T10:00:00Z"),
+		item(t, "b", "Vrede politikere", "", "2025-01-06T11:00:00Z"),
+	}
+	if _, err := service.repository.InsertItems(ctx, testSite, items); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	started, err := service.StartRebuildSearchIndex(ctx)
+	if err != nil {
+		t.Fatalf("start rebuild: %v", err)
+	}
+	if started.Status != core.JobRunning || started.Kind != core.JobRebuildSearchIndex {
+		t.Fatalf("started run = %+v", started)
+	}
+	var run *core.JobRun
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		run, err = service.GetJobRun(ctx, started.Id)
+		if err != nil {
+			t.Fatalf("get run: %v", err)
+		}
+		if run.Status != core.JobRunning {
+			break
+		}
+	}
+	if run.Status != core.JobSucceeded || run.FinishedAt == nil || run.Counters["documents"] != len(items) {
+		t.Errorf("finished run = %+v, want succeeded with %v documents", run, len(items))
+	}
+
+	runs, err := service.GetJobRuns(ctx, 10)
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Id != started.Id {
+		t.Errorf("runs = %+v, want just the rebuild", runs)
+	}
+}
+
+// A fetch run where no site could be fetched, or that was cancelled, is a
+// failed run in the job history, not a successful one.
+func TestFetchRunFailsWhenNoSiteSucceeds(t *testing.T) {
+	service := newTestService(t)
+	// Nothing was recorded, so every feed fails as an unreachable one would.
+	service.source = &replaySource{dir: t.TempDir()}
+	ctx := context.Background()
+	if err := service.FetchAndSaveNewItems(ctx); err == nil {
+		t.Error("fetch with every site failing succeeded")
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	service.source = cancellingSource{cancel: cancel}
+	if err := service.FetchAndSaveNewItems(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled fetch = %v, want context.Canceled", err)
+	}
+	runs, err := service.GetJobRuns(ctx, 10)
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("runs = %+v, want both fetches", runs)
+	}
+	for _, run := range runs {
+		if run.Status != core.JobFailed || run.Error == "" {
+			t.Errorf("run = %+v, want failed", run)
+		}
+	}
+}
+
+// cancellingSource cancels the run at its first fetch, as a shutdown would.
+type cancellingSource struct {
+	cancel context.CancelFunc
+}
+
+func (s cancellingSource) Fetch(ctx context.Context, site core.NewsSite, url string, validators core.FeedValidators) (FeedResponse, error) {
+	s.cancel()
+	return FeedResponse{}, ctx.Err()
+}
+
+// A job started while a run of it is going, as a manual trigger during a
+// scheduled run, is skipped and recorded as such, not run alongside it.
+func TestBusyJobIsSkipped(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	running, err := service.startJobRun(ctx, core.JobArchiveItems)
+	if err != nil {
+		t.Fatalf("start run: %v", err)
+	}
+	if err := service.ArchiveItems(ctx); !errors.Is(err, core.ErrJobBusy) {
+		t.Errorf("archive during a run = %v, want ErrJobBusy", err)
+	}
+	runs, err := service.GetJobRuns(ctx, 10)
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Status != core.JobSkipped || runs[1].Status != core.JobRunning {
+		t.Errorf("runs = %+v, want the running one and a skipped one", runs)
+	}
+
+	running.finish(ctx, nil)
+	if err := service.ArchiveItems(ctx); err != nil {
+		t.Errorf("archive after the run = %v", err)
+	}
+}
+
+// A run the previous process never finished is marked failed on startup.
+func TestInterruptedJobRunsFailOnStartup(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	id, err := service.repository.CreateJobRun(ctx, core.JobFetchItems, time.Now())
+	if err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	service.failInterruptedJobRuns(ctx)
+	run, err := service.GetJobRun(ctx, id)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+	if run.Status != core.JobFailed || run.Error == "" || run.FinishedAt == nil {
+		t.Errorf("run = %+v, want failed", run)
+	}
+}
+
+// Everything the feed says about an item is stored, not just its text: authors,
+// categories, the GUID and a picture, wherever the feed put it.
+func TestFetchKeepsFeedMetadata(t *testing.T) {
+	const feed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:media="http://search.yahoo.com/mrss/"><channel><title>Test</title>
+<item><title>Rasende borgere</title><link>https://example.dk/1</link><guid isPermaLink="false">urn:1</guid>
+<dc:creator>Anna Hansen</dc:creator><category> Indland </category><category>Politik</category><category>Indland</category>
+<enclosure url="https://example.dk/1.mp3" type="audio/mpeg" length="1"/><enclosure url="https://example.dk/1.jpg" type="image/jpeg" length="1"/>
+<pubDate>Mon, 06 Jan 2025 10:00:00 +0000</pubDate></item>
+<item><title>Vrede politikere</title><link>https://example.dk/2</link>
+<description>&lt;img src="https://tracker.example/pixel.gif"&gt;</description>
+<media:thumbnail url="https://example.dk/2-thumb.jpg"/>
+<pubDate>Mon, 06 Jan 2025 11:00:00 +0000</pubDate></item>
+</channel></rss>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	service := newTestService(t)
+	site := testSite
+	site.Urls = []string{server.URL}
+	ctx := context.Background()
+	if _, _, err := service.fetchAndSaveNewItemsForSite(ctx, site); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	items, err := service.GetRecentItems(ctx, site.Id, 10, nil)
+	if err != nil {
+		t.Fatalf("recent items: %v", err)
+	}
+	byLink := make(map[string]core.RssItemDto, len(items))
+	for _, item := range items {
+		byLink[item.Link] = item
+	}
+
+	first := byLink["https://example.dk/1"]
+	if !equal(first.Authors, []string{"Anna Hansen"}) || !equal(first.Categories, []string{"Indland", "Politik"}) {
+		t.Errorf("authors/categories = %q/%q, want [Anna Hansen]/[Indland Politik]", first.Authors, first.Categories)
+	}
+	if first.Guid != "urn:1" || first.ImageUrl != "https://example.dk/1.jpg" {
+		t.Errorf("guid/image = %q/%q, want urn:1 and the image enclosure", first.Guid, first.ImageUrl)
+	}
+	second := byLink["https://example.dk/2"]
+	if second.ImageUrl != "https://example.dk/2-thumb.jpg" {
+		t.Errorf("image = %q, want the media:thumbnail over the <img> in the description", second.ImageUrl)
+	}
+	if len(second.Authors) != 0 || len(second.Categories) != 0 || second.Guid != "" {
+		t.Errorf("item without metadata got %q/%q/%q", second.Authors, second.Categories, second.Guid)
+	}
+
+	danish, _ := lang.Get("da")
+	results, err := service.SearchItems(ctx, danish, "rasende", false, core.SearchFilter{Category: "indland"}, 0, 10, "-published")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 || results[0].ImageUrl != "https://example.dk/1.jpg" || !equal(results[0].Authors, []string{"Anna Hansen"}) {
+		t.Errorf("search in indland = %+v, want the first item with its metadata", results)
+	}
+}
+
+// An edited headline revises the stored item rather than arriving as a new one,
+// and both versions are kept.
+func TestFetchRevisesEditedItems(t *testing.T) {
+	const feed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Test</title>
+<item><title>%v</title><link>https://example.dk/1</link><guid>urn:1</guid><pubDate>Mon, 06 Jan 2025 10:00:00 +0000</pubDate></item>
+</channel></rss>`
+	var title atomic.Value
+	title.Store("Rasende minister skælder ud")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, feed, title.Load())
+	}))
+	defer server.Close()
+
+	service := newTestService(t)
+	site := testSite
+	site.Urls = []string{server.URL}
+	ctx := context.Background()
+	if inserted, _, err := service.fetchAndSaveNewItemsForSite(ctx, site); err != nil || inserted != 1 {
+		t.Fatalf("first fetch inserted %v: %v", inserted, err)
+	}
+	// Unchanged: nothing to revise.
+	if inserted, revised, err := service.fetchAndSaveNewItemsForSite(ctx, site); err != nil || inserted != 0 || revised != 0 {
+		t.Fatalf("second fetch inserted %v and revised %v, want none: %v", inserted, revised, err)
+	}
+	title.Store("Minister kritiserer forslag")
+	if inserted, revised, err := service.fetchAndSaveNewItemsForSite(ctx, site); err != nil || inserted != 0 || revised != 1 {
+		t.Fatalf("fetch after the edit inserted %v and revised %v, want 0 and 1: %v", inserted, revised, err)
+	}
+
+	items, err := service.GetRecentItems(ctx, site.Id, 10, nil)
+	if err != nil {
+		t.Fatalf("recent items: %v", err)
+	}
+	if len(items) != 1 || items[0].Title != "Minister kritiserer forslag" {
+		t.Fatalf("items = %+v, want the one item with its new title", items)
+	}
+	revisions, err := service.GetItemRevisions(ctx, items[0].ItemId)
+	if err != nil {
+		t.Fatalf("revisions: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Title != "Rasende minister skælder ud" || revisions[1].Title != "Minister kritiserer forslag" {
+		t.Errorf("revisions = %+v, want the original and the edit, oldest first", revisions)
+	}
+
+	// The index follows the edit: the old headline no longer matches.
+	danish, _ := lang.Get("da")
+	results, err := service.SearchItems(ctx, danish, "rasende", false, core.SearchFilter{}, 0, 10, "-published")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("search for the old headline = %+v, want nothing", results)
+	}
+}
+
+// Items stored while ids were a hash of title and link are found through their
+// link, so an edit to one of those is a revision too, and the item takes the
+// new kind of id.
+func TestFetchRevisesItemsStoredUnderOldIds(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	now := time.Now()
+	legacy := core.RssItemDto{
+		ItemId: "legacy", SiteId: testSite.Id, Title: "Rasende borgere", Link: "https://example.dk/1",
+		Published: now, InsertedAt: &now,
+	}
+	if _, err := service.repository.InsertItems(ctx, testSite, []core.RssItemDto{legacy}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Replace(testFeed, "Rasende borgere", "Vrede borgere", 1)))
+	}))
+	defer server.Close()
+	site := testSite
+	site.Urls = []string{server.URL}
+
+	inserted, revised, err := service.fetchAndSaveNewItemsForSite(ctx, site)
+	if err != nil || inserted != 1 || revised != 1 {
+		t.Fatalf("fetch inserted %v and revised %v, want 1 and 1: %v", inserted, revised, err)
+	}
+	if item, err := service.GetItem(ctx, "legacy"); err != nil || item != nil {
+		t.Errorf("item under the old id = %+v (%v), want it gone", item, err)
+	}
+	items, err := service.GetRecentItems(ctx, site.Id, 10, nil)
+	if err != nil {
+		t.Fatalf("recent items: %v", err)
+	}
+	for _, item := range items {
+		if item.Link == legacy.Link && item.Title != "Vrede borgere" {
+			t.Errorf("legacy item title = %q, want the edit", item.Title)
+		}
+	}
+}
+
+// An item stored before links were cleaned has the feed's link, tracking
+// parameters and all, and is still found through it, not stored again.
+func TestFetchFindsItemsStoredUnderUncleanedLinks(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	now := time.Now()
+	legacy := core.RssItemDto{
+		ItemId: "legacy", SiteId: testSite.Id, Title: "Rasende borgere", Link: "https://example.dk/1?utm_source=rss",
+		Published: now, InsertedAt: &now,
+	}
+	if _, err := service.repository.InsertItems(ctx, testSite, []core.RssItemDto{legacy}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Replace(testFeed, "https://example.dk/1", "https://example.dk/1?utm_source=rss", 1)))
+	}))
+	defer server.Close()
+	site := testSite
+	site.Urls = []string{server.URL}
+
+	inserted, _, err := service.fetchAndSaveNewItemsForSite(ctx, site)
+	if err != nil || inserted != 1 {
+		t.Fatalf("fetch inserted %v, want only the other item: %v", inserted, err)
+	}
+	items, err := service.GetRecentItems(ctx, site.Id, 10, nil)
+	if err != nil {
+		t.Fatalf("recent items: %v", err)
+	}
+	copies := 0
+	for _, item := range items {
+		if item.Title == legacy.Title {
+			copies++
+		}
+	}
+	if len(items) != 2 || copies != 1 {
+		t.Errorf("items = %+v, want the legacy item once and the other item", items)
+	}
+}
+
+// A test fetch parses the feed as a run would, but saves neither the items nor
+// anything about the feed.
+func TestTestFetchSavesNothing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	service := newTestService(t)
+	ctx := context.Background()
+	items, err := service.TestFetch(ctx, testSite, server.URL)
+	if err != nil {
+		t.Fatalf("test fetch: %v", err)
+	}
+	if len(items) != 2 || items[0].Title != "Rasende borgere" {
+		t.Errorf("items = %+v, want the two in the feed", items)
+	}
+	recent, err := service.GetRecentItems(ctx, testSite.Id, 10, nil)
+	if err != nil {
+		t.Fatalf("recent items: %v", err)
+	}
+	validators, err := service.repository.GetFeedValidators(ctx, []string{server.URL})
+	if err != nil {
+		t.Fatalf("validators: %v", err)
+	}
+	if len(recent) != 0 || len(validators) != 0 {
+		t.Errorf("test fetch saved %v items and %v validators, want none", len(recent), len(validators))
+	}
+}
+
+func TestCreateSiteRejectsUnknownUserAgent(t *testing.T) {
+	service := newTestService(t)
+	site := core.NewsSite{Name: "Ny Avis", Language: "da", UserAgentKey: "netscape"}
+	if _, err := service.CreateSite(context.Background(), site); err == nil {
+		t.Error("CreateSite accepted an unknown user agent key")
+	}
+}
+
+// A dry run lists what a rule would purge and deletes nothing; the purge then
+// takes the items out of the search index along with the table.
+func TestPurgeContentRule(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	if _, err := service.repository.InsertItems(ctx, testSite, corpus(t)); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	rule := core.ParseContentRule("block title ^Rasende")
+
+	matched, err := service.MatchContentRule(ctx, testSite, rule)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(matched) != 1 || matched[0].ItemId != "a" {
+		t.Errorf("dry run matched %+v, want item a", matched)
+	}
+	deleted, err := service.PurgeContentRule(ctx, testSite, rule)
+	if err != nil || deleted != 1 {
+		t.Fatalf("purge = %v, %v, want 1 deleted", deleted, err)
+	}
+
+	results, err := service.search.Search(ctx, "da", "rasende", false, core.SearchFilter{}, nil, nil, "published", 10, 0)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got, want := itemIds(results), []string{"b"}; !equal(got, want) {
+		t.Errorf("search after purge = %v, want %v", got, want)
+	}
+	conn, err := db.Open(service.context.Config)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	var indexed int
+	if err := conn.QueryRow("SELECT count(*) FROM rss_items_fts").Scan(&indexed); err != nil {
+		t.Fatalf("count index rows: %v", err)
+	}
+	if indexed != 3 {
+		t.Errorf("%v index rows after purge, want 3", indexed)
+	}
+	if matched, err := service.MatchContentRule(ctx, testSite, rule); err != nil || len(matched) != 0 {
+		t.Errorf("dry run after purge = %+v, %v, want no matches", matched, err)
+	}
+}
+
+// Purging an allow rule deletes what it does not allow, never what it does, and
+// its dry run lists the same items.
+func TestPurgeAllowRule(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	if _, err := service.repository.InsertItems(ctx, testSite, corpus(t)); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	rule := core.ParseContentRule("allow title ^Rasende")
+
+	keptOut, err := service.MatchContentRule(ctx, testSite, rule)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	ids := make([]string, len(keptOut))
+	for i, item := range keptOut {
+		ids[i] = item.ItemId
+	}
+	slices.Sort(ids)
+	if want := []string{"b", "c", "d"}; !equal(ids, want) {
+		t.Errorf("dry run = %v, want %v", ids, want)
+	}
+	deleted, err := service.PurgeContentRule(ctx, testSite, rule)
+	if err != nil || deleted != len(keptOut) {
+		t.Fatalf("purge = %v, %v, want the %v items of the dry run deleted", deleted, err, len(keptOut))
+	}
+	items, err := service.repository.GetSiteItems(ctx, testSite.Id)
+	if err != nil {
+		t.Fatalf("site items: %v", err)
+	}
+	if len(items) != 1 || items[0].ItemId != "a" {
+		t.Errorf("items after purge = %+v, want just the allowed a", items)
+	}
+}
+
+// A site's rate is its matches per 1,000 of its own articles, so a small site
+// that rages often outranks a big one that rages more in total. A site with too
+// few articles to say is left out rather than topping the board.
+func TestSiteRates(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+	published := time.Now().Add(-time.Hour)
+	insert := func(site core.NewsSite, articles, matches int) {
+		items := make([]core.RssItemDto, articles)
+		for i := range items {
+			title := fmt.Sprintf("Stille nyhed nummer %v fra %v", i, site.Name)
+			if i < matches {
+				title = fmt.Sprintf("Rasende nyhed nummer %v fra %v", i, site.Name)
+			}
+			id := fmt.Sprintf("%v-%v", site.Id, i)
+			items[i] = core.RssItemDto{ItemId: id, SiteId: site.Id, SiteName: site.Name, Title: title, Link: "https://example.dk/" + id, Published: published, InsertedAt: &published}
+		}
+		if _, err := service.repository.InsertItems(ctx, site, items); err != nil {
+			t.Fatalf("insert items: %v", err)
+		}
+	}
+	insert(testSite, 100, 5)
+	insert(core.NewsSite{Id: 4, Name: "BT", Language: "da"}, 200, 4)
+	insert(core.NewsSite{Id: 10, Name: "Ekstrabladet", Language: "da"}, 60, 0)
+	insert(core.NewsSite{Id: 2, Name: "Avisen Danmark", Language: "da"}, 20, 10)
+
+	l := lang.MustGet(lang.Da)
+	rates, err := service.GetSiteRates(ctx, l, "rasende", core.SearchFilter{}, nil, nil)
+	if err != nil {
+		t.Fatalf("GetSiteRates: %v", err)
+	}
+	got := make([]string, len(rates))
+	for i, rate := range rates {
+		got[i] = fmt.Sprintf("%v %v/%v=%v", rate.SiteName, rate.Matches, rate.Articles, rate.Rate)
+	}
+	if want := []string{"Arbejderen 5/100=50", "BT 4/200=20", "Ekstrabladet 0/60=0"}; !equal(got, want) {
+		t.Errorf("rates = %v, want %v", got, want)
+	}
+
+	charts, err := service.GetChartData(ctx, l, []string{"rasende"}, core.SearchFilter{}, core.DefaultChartPeriod(time.Now(), l.Location))
+	if err != nil {
+		t.Fatalf("GetChartData: %v", err)
+	}
+	bar := charts.Charts[len(charts.Charts)-1]
+	if bar.Type != "bar" || !equal(bar.Labels, []string{"Arbejderen", "BT", "Ekstrabladet"}) {
+		t.Errorf("rate chart = %v %v, want a bar per site with a rate", bar.Type, bar.Labels)
+	}
+
+	// Compared with a term no site used, each chart gets a dataset per term.
+	compared, err := service.GetChartData(ctx, l, []string{"rasende", "stille"}, core.SearchFilter{}, core.DefaultChartPeriod(time.Now(), l.Location))
+	if err != nil {
+		t.Fatalf("GetChartData comparing: %v", err)
+	}
+	for _, chart := range compared.Charts {
+		if len(chart.Datasets) != 2 {
+			t.Errorf("%v chart %q has %v datasets, want one per term", chart.Type, chart.Title, len(chart.Datasets))
+		}
+	}
+	if sites := compared.Charts[1]; sites.Type != "bar" || !sites.Stacked || sites.Datasets[1].Label != "stille" {
+		t.Errorf("site chart = %+v, want the terms stacked", sites)
+	}
+	if title, want := compared.Charts[0].Title, "Den seneste uges brug af 'rasende' og 'stille'"; title != want {
+		t.Errorf("line title = %q, want %q", title, want)
+	}
+}
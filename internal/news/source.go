@@ -0,0 +1,221 @@
+package news
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+)
+
+// FeedSource is where RssService gets the raw bytes of what it ingests: the
+// feeds, and the article pages of the sites it extracts. It is the seam that
+// lets an ingest run against recorded responses instead of the live sites.
+type FeedSource interface {
+	// Fetch gets url, which belongs to site, sending validators as a
+	// conditional GET. A response other than 2xx or 304 is an error, returned
+	// along with the response so the caller still sees its status.
+	Fetch(ctx context.Context, site core.NewsSite, url string, validators core.FeedValidators) (FeedResponse, error)
+}
+
+// FeedResponse is one fetched feed or page.
+type FeedResponse struct {
+	// Url is where the response came from after redirects, which relative links
+	// in the body resolve against.
+	Url        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	Body       []byte `json:"-"`
+	// NotModified is set on a 304, and then Body is empty.
+	NotModified bool `json:"notModified"`
+	// Validators are the ones the response carried, or on a 304 the ones sent.
+	Validators core.FeedValidators `json:"validators"`
+}
+
+// maxResponseBytes bounds what is read of a response, so one misbehaving server
+// cannot fill the memory of the job.
+const maxResponseBytes = 32 << 20
+
+var userAgents = map[string]string{
+	"chrome": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/128.0.0.0 Safari/537.36",
+}
+
+// httpSource fetches from the live sites, through the shared client and the
+// per-host slots.
+type httpSource struct {
+	client *http.Client
+	slots  *hostSlots
+}
+
+func newHttpSource(perHost int) *httpSource {
+	return &httpSource{client: newFetchClient(), slots: newHostSlots(perHost)}
+}
+
+func (s *httpSource) Fetch(ctx context.Context, site core.NewsSite, url string, validators core.FeedValidators) (FeedResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return FeedResponse{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+	if userAgent, ok := userAgents[site.UserAgentKey]; ok {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	release, err := s.slots.acquire(ctx, hostOf(req.URL))
+	if err != nil {
+		return FeedResponse{}, err
+	}
+	defer release()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return FeedResponse{}, fmt.Errorf("error getting %v: %w", url, err)
+	}
+	defer resp.Body.Close()
+	fetched := FeedResponse{Url: resp.Request.URL.String(), StatusCode: resp.StatusCode}
+	if resp.StatusCode == http.StatusNotModified {
+		// Nothing new since the validators were stored, and they stay valid.
+		fetched.NotModified = true
+		fetched.Validators = validators
+		return fetched, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return fetched, fmt.Errorf("error reading body of %v: %w", url, err)
+	}
+	if resp.StatusCode > 299 {
+		slog.Warn("unexpected status fetching", "url", url, "status", resp.StatusCode, "headers", fmt.Sprintf("%v", resp.Header), "body", string(body))
+		return fetched, fmt.Errorf("error getting %v, returned error code %v", url, resp.StatusCode)
+	}
+	fetched.Body = body
+	fetched.Validators = core.FeedValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return fetched, nil
+}
+
+// A recorded response is two files in the site's directory, named after a hash
+// of the URL: the body exactly as it was received, so a parser bug reproduces
+// byte for byte, and the rest of the response as JSON beside it.
+const (
+	recordedBodyExt = ".body"
+	recordedMetaExt = ".json"
+)
+
+// recordedPath returns the path of the response to url, without an extension.
+func recordedPath(dir string, site core.NewsSite, url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, siteDirName(site), fmt.Sprintf("%x", sum[:8]))
+}
+
+// siteDirName is the site's id and name, the name reduced to what is safe in a
+// path on every system. The id keeps two sites with similar names apart.
+func siteDirName(site core.NewsSite) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(site.Name))
+	return fmt.Sprintf("%v-%v", site.Id, strings.Trim(name, "-"))
+}
+
+// recordingSource passes every fetch on to another source, and saves what
+// comes back under dir. A 304 is not saved: the response it confirms is already
+// there.
+type recordingSource struct {
+	source FeedSource
+	dir    string
+}
+
+func (s *recordingSource) Fetch(ctx context.Context, site core.NewsSite, url string, validators core.FeedValidators) (FeedResponse, error) {
+	resp, err := s.source.Fetch(ctx, site, url, validators)
+	if err != nil || resp.NotModified {
+		return resp, err
+	}
+	if err := saveRecording(recordedPath(s.dir, site, url), url, resp); err != nil {
+		// The fetch itself went fine, so the ingest goes on; the recording just
+		// misses this response.
+		slog.Error("recording response failed", "site", site.Name, "url", url, "error", err)
+	}
+	return resp, nil
+}
+
+// recording is the JSON beside a recorded body.
+type recording struct {
+	// RequestUrl is the URL that was asked for, which is hashed into the file
+	// name; kept for whoever reads the directory.
+	RequestUrl string `json:"requestUrl"`
+	FeedResponse
+}
+
+func saveRecording(path string, url string, resp FeedResponse) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	meta, err := json.MarshalIndent(recording{RequestUrl: url, FeedResponse: resp}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+recordedBodyExt, resp.Body, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(path+recordedMetaExt, meta, 0o644)
+}
+
+// replaySource serves the responses a recordingSource saved under dir, and
+// never touches the network. A URL with no recording fails as an unreachable
+// feed would. Sent validators that match the recorded ones get a 304, like
+// from the live site.
+type replaySource struct {
+	dir string
+}
+
+func (s *replaySource) Fetch(ctx context.Context, site core.NewsSite, url string, validators core.FeedValidators) (FeedResponse, error) {
+	path := recordedPath(s.dir, site, url)
+	meta, err := os.ReadFile(path + recordedMetaExt)
+	if errors.Is(err, os.ErrNotExist) {
+		return FeedResponse{}, fmt.Errorf("error getting %v: no recording in %v", url, s.dir)
+	}
+	if err != nil {
+		return FeedResponse{}, err
+	}
+	var recorded recording
+	if err := json.Unmarshal(meta, &recorded); err != nil {
+		return FeedResponse{}, fmt.Errorf("error reading recording of %v: %w", url, err)
+	}
+	resp := recorded.FeedResponse
+	if validators != (core.FeedValidators{}) && validators == resp.Validators {
+		resp.NotModified = true
+		return resp, nil
+	}
+	if resp.Body, err = os.ReadFile(path + recordedBodyExt); err != nil {
+		return FeedResponse{}, fmt.Errorf("error reading recording of %v: %w", url, err)
+	}
+	return resp, nil
+}
+
+// newFeedSource returns the source the config asks for: the live sites, the live
+// sites with every response recorded, or a replay of the recordings.
+func newFeedSource(cfg *config.Config) FeedSource {
+	switch {
+	case cfg.FeedReplayDir != "":
+		return &replaySource{dir: cfg.FeedReplayDir}
+	case cfg.FeedRecordDir != "":
+		return &recordingSource{source: newHttpSource(cfg.FetchPerHost), dir: cfg.FeedRecordDir}
+	default:
+		return newHttpSource(cfg.FetchPerHost)
+	}
+}
@@ -0,0 +1,61 @@
+package news
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+)
+
+// What a recorded run fetched, a replay serves back byte for byte, without the
+// site being up, and with the same 304 once the validators are stored.
+func TestReplayServesRecordedFeeds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testFeed))
+	}))
+	site := testSite
+	site.Urls = []string{server.URL}
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	recorder := newTestService(t)
+	recorder.source = &recordingSource{source: recorder.source, dir: dir}
+	if _, _, err := recorder.fetchAndSaveNewItemsForSite(ctx, site); err != nil {
+		t.Fatalf("recorded fetch: %v", err)
+	}
+	server.Close()
+	body, err := os.ReadFile(recordedPath(dir, site, server.URL) + recordedBodyExt)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	if string(body) != testFeed {
+		t.Errorf("recorded body = %q, want the feed as served", body)
+	}
+
+	replayer := newTestService(t)
+	replayer.source = &replaySource{dir: dir}
+	for range 2 {
+		if _, _, err := replayer.fetchAndSaveNewItemsForSite(ctx, site); err != nil {
+			t.Fatalf("replayed fetch: %v", err)
+		}
+	}
+	items, err := replayer.GetRecentItems(ctx, site.Id, 10, nil)
+	if err != nil {
+		t.Fatalf("recent items: %v", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("replay stored %v items, want 2", len(items))
+	}
+	resp, err := replayer.source.Fetch(ctx, site, server.URL, core.FeedValidators{ETag: `"v1"`})
+	if err != nil || !resp.NotModified {
+		t.Errorf("replay with the recorded validators = %+v, %v, want not modified", resp, err)
+	}
+
+	if _, err := replayer.source.Fetch(ctx, site, server.URL+"/other", core.FeedValidators{}); err == nil {
+		t.Error("replay of an unrecorded URL succeeded")
+	}
+}
@@ -0,0 +1,279 @@
+package news
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/lang"
+	"github.com/bjarke-xyz/rasende2/internal/repository/db"
+	"github.com/bjarke-xyz/rasende2/internal/search"
+)
+
+// term_counts rolls the matches of the tracked terms up per site and day, so
+// that the charts of the index page, and of any other tracked term, are summed
+// from a row per day instead of running a MATCH over every item. The repository
+// adds to it as it indexes items; RollUpTerms regenerates it from the index.
+
+// RollUpTerms counts the matches of the tracked terms of every edition afresh
+// from the search index, and marks them rolled up, which is what lets the
+// charts read them from term_counts. With all, every tracked term is counted;
+// without it, only those not rolled up yet, as after one is added to
+// TRACKED_TERMS. It returns the number of terms counted.
+//
+// The rollups of terms no longer tracked are dropped first, so that a term
+// taken out of TRACKED_TERMS, which stops being counted as items come in, is
+// counted afresh if it is put back.
+//
+// Each term is counted in a transaction of its own, which holds the write lock
+// from the start, so that an item inserted meanwhile is either counted here or
+// by its insert, never both.
+func (s *RssSearch) RollUpTerms(ctx context.Context, all bool) (int, error) {
+	dbConn, err := db.Open(s.context.Config)
+	if err != nil {
+		return 0, err
+	}
+	if err := dropUntrackedTerms(ctx, dbConn, s.context.Config); err != nil {
+		return 0, fmt.Errorf("error dropping untracked terms: %w", err)
+	}
+	rolledUp := 0
+	for _, l := range lang.All {
+		for _, term := range core.TrackedTerms(s.context.Config, l) {
+			if _, ok := search.TitleMatch(term, string(l.Code)); !ok {
+				slog.Warn("tracked term cannot be rolled up", "term", term, "lang", l.Code)
+				continue
+			}
+			if !all {
+				done, err := s.isRolledUp(ctx, l, term)
+				if err != nil {
+					return rolledUp, err
+				}
+				if done {
+					continue
+				}
+			}
+			if err := s.rollUpTerm(ctx, dbConn, l, term); err != nil {
+				return rolledUp, fmt.Errorf("error rolling up %q: %w", term, err)
+			}
+			rolledUp++
+		}
+	}
+	return rolledUp, nil
+}
+
+// dropUntrackedTerms deletes the term_counts and rolled_up_terms of the terms
+// each edition no longer tracks.
+func dropUntrackedTerms(ctx context.Context, dbConn *sql.DB, cfg *config.Config) error {
+	tx, err := dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+	for _, l := range lang.All {
+		terms := core.TrackedTerms(cfg, l)
+		notIn := " AND term NOT IN (?" + strings.Repeat(", ?", len(terms)-1) + ")"
+		args := []any{l.Code}
+		for _, term := range terms {
+			args = append(args, term)
+		}
+		rolledUp, err := tx.ExecContext(ctx, "DELETE FROM rolled_up_terms WHERE language = ?"+notIn, args...)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM term_counts WHERE site_id IN (SELECT id FROM sites WHERE language = ?)"+notIn, args...); err != nil {
+			return err
+		}
+		if dropped, err := rolledUp.RowsAffected(); err == nil && dropped > 0 {
+			slog.Info("dropped rollups of untracked terms", "lang", l.Code, "terms", dropped)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *RssSearch) rollUpTerm(ctx context.Context, dbConn *sql.DB, l lang.Lang, term string) error {
+	compiled, ok, err := s.compileQuery(ctx, string(l.Code), term, false)
+	if err != nil {
+		return err
+	}
+	tx, err := dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+	// Deleting first takes the write lock before anything is read.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM term_counts WHERE term = ? AND site_id IN (SELECT id FROM sites WHERE language = ?)", term, l.Code); err != nil {
+		return err
+	}
+	if ok {
+		counts, err := countSiteDays(ctx, tx, compiled, l.Location)
+		if err != nil {
+			return err
+		}
+		for key, count := range counts {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO term_counts (term, site_id, day, count) VALUES (?, ?, ?, ?)",
+				term, key.siteId, key.day, count); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO rolled_up_terms (language, term, rolled_up_at) VALUES (?, ?, ?) "+
+		"ON CONFLICT (language, term) DO UPDATE SET rolled_up_at = excluded.rolled_up_at", l.Code, term, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type siteDay struct {
+	siteId int
+	day    string
+}
+
+// countSiteDays counts the matches of compiled per site and day in loc. As in
+// CountByBucket, SQLite counts them per countSlot, and the slots are added up
+// into days here.
+func countSiteDays(ctx context.Context, tx *sql.Tx, compiled compiledQuery, loc *time.Location) (map[siteDay]int, error) {
+	slot := fmt.Sprintf("unixepoch(i.published) / %d * %d", int(countSlot.Seconds()), int(countSlot.Seconds()))
+	rows, err := tx.QueryContext(ctx, "SELECT i.site_id, "+slot+" AS slot, count(*)"+searchFrom+compiled.clause+" GROUP BY i.site_id, slot",
+		append([]any{compiled.expr}, compiled.args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[siteDay]int)
+	for rows.Next() {
+		var siteId, count int
+		var slot *int64
+		if err := rows.Scan(&siteId, &slot, &count); err != nil {
+			return nil, err
+		}
+		if slot == nil {
+			continue
+		}
+		counts[siteDay{siteId: siteId, day: time.Unix(*slot, 0).In(loc).Format(time.DateOnly)}] += count
+	}
+	return counts, rows.Err()
+}
+
+func (s *RssSearch) isRolledUp(ctx context.Context, l lang.Lang, term string) (bool, error) {
+	dbConn, err := db.Open(s.context.Config)
+	if err != nil {
+		return false, err
+	}
+	var rolledUp bool
+	err = dbConn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM rolled_up_terms WHERE language = ? AND term = ?)", l.Code, term).Scan(&rolledUp)
+	if err != nil {
+		return false, fmt.Errorf("error checking rollup of %q: %w", term, err)
+	}
+	return rolledUp, nil
+}
+
+// rolledUp compiles query for summing its term_counts, and reports whether they
+// give what running it would: when it is a rolled up term of the edition,
+// counted on titles without filters, over whole days of the edition's calendar.
+// Anything else is counted through the index.
+func (s *RssSearch) rolledUp(ctx context.Context, code string, query string, searchContent bool, filter core.SearchFilter, start *time.Time, end *time.Time) (compiledQuery, lang.Lang, bool, error) {
+	l, ok := lang.Get(code)
+	if !ok || searchContent || filter != (core.SearchFilter{}) || !slices.Contains(core.TrackedTerms(s.context.Config, l), query) {
+		return compiledQuery{}, l, false, nil
+	}
+	for _, t := range []*time.Time{start, end} {
+		if t != nil && !t.Equal(core.BucketDay.Truncate(t.In(l.Location))) {
+			return compiledQuery{}, l, false, nil
+		}
+	}
+	rolledUp, err := s.isRolledUp(ctx, l, query)
+	if err != nil || !rolledUp {
+		return compiledQuery{}, l, false, err
+	}
+	compiled, ok, err := s.compileQuery(ctx, code, query, false)
+	return compiled, l, ok, err
+}
+
+// daysBetween is publishedBetween for term_counts: the days from start's up to,
+// but not including, end's. Both are midnights in loc.
+func daysBetween(start *time.Time, end *time.Time, loc *time.Location) (string, []any) {
+	clause := strings.Builder{}
+	args := []any{}
+	if start != nil {
+		clause.WriteString(" AND day >= ?")
+		args = append(args, start.In(loc).Format(time.DateOnly))
+	}
+	if end != nil {
+		clause.WriteString(" AND day < ?")
+		args = append(args, end.In(loc).Format(time.DateOnly))
+	}
+	return clause.String(), args
+}
+
+// termCountsWhere restricts term_counts to the term and the sites compiled is
+// restricted to.
+func termCountsWhere(compiled compiledQuery, term string) (string, []any) {
+	return " FROM term_counts i WHERE term = ?" + compiled.clause, append([]any{term}, compiled.args...)
+}
+
+// sumByBucket is CountByBucket summed from term_counts.
+func (s *RssSearch) sumByBucket(ctx context.Context, compiled compiledQuery, term string, start *time.Time, end *time.Time, bucket core.ChartBucket, loc *time.Location) ([]core.SearchQueryCount, error) {
+	counts := []core.SearchQueryCount{}
+	dbConn, err := db.Open(s.context.Config)
+	if err != nil {
+		return counts, err
+	}
+	where, args := termCountsWhere(compiled, term)
+	rangeClause, rangeArgs := daysBetween(start, end, loc)
+	rows, err := dbConn.QueryContext(ctx, "SELECT day, sum(count)"+where+rangeClause+" GROUP BY day ORDER BY day ASC", append(args, rangeArgs...)...)
+	if err != nil {
+		return counts, fmt.Errorf("error summing by %v: %w", bucket, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day string
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return counts, fmt.Errorf("error scanning %v sum: %w", bucket, err)
+		}
+		if count == 0 {
+			continue
+		}
+		dayStart, err := time.ParseInLocation(time.DateOnly, day, loc)
+		if err != nil {
+			return counts, fmt.Errorf("error reading day %q: %w", day, err)
+		}
+		bucketStart := bucket.Truncate(dayStart)
+		if last := len(counts) - 1; last >= 0 && counts[last].Timestamp.Equal(bucketStart) {
+			counts[last].Count += count
+			continue
+		}
+		counts = append(counts, core.SearchQueryCount{Timestamp: bucketStart, Count: count})
+	}
+	return counts, rows.Err()
+}
+
+// sumBySite is CountBySite summed from term_counts.
+func (s *RssSearch) sumBySite(ctx context.Context, compiled compiledQuery, term string, start *time.Time, end *time.Time, loc *time.Location) ([]core.SiteCount, error) {
+	counts := []core.SiteCount{}
+	dbConn, err := db.Open(s.context.Config)
+	if err != nil {
+		return counts, err
+	}
+	where, args := termCountsWhere(compiled, term)
+	rangeClause, rangeArgs := daysBetween(start, end, loc)
+	rows, err := dbConn.QueryContext(ctx, "SELECT i.site_id, sum(count) AS count"+where+rangeClause+" GROUP BY i.site_id HAVING sum(count) > 0", append(args, rangeArgs...)...)
+	if err != nil {
+		return counts, fmt.Errorf("error summing by site: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var siteCount core.SiteCount
+		if err := rows.Scan(&siteCount.SiteId, &siteCount.Count); err != nil {
+			return counts, fmt.Errorf("error scanning site sum: %w", err)
+		}
+		counts = append(counts, siteCount)
+	}
+	return counts, rows.Err()
+}
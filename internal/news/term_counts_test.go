@@ -0,0 +1,257 @@
+package news
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/lang"
+	"github.com/bjarke-xyz/rasende2/internal/repository/db"
+)
+
+// Once rolled up, a tracked term is summed from term_counts, and the counts
+// follow the items inserted, revised and deleted after: the charts must come
+// out as running the query through the index does, on the edition's calendar.
+func TestRolledUpTermsFollowTheItems(t *testing.T) {
+	items := []core.RssItemDto{
+		item(t, "late", "Rasende sent", "", "2024-03-01T23:30:00Z"),
+		item(t, "early", "Rasende tidligt", "", "2024-03-02T07:00:00+01:00"),
+		item(t, "summer", "Rasende minister", "", "2024-06-30T22:10:00Z"),
+		item(t, "calm", "Minister i ro", "Intet rasende her.", "2024-03-05T12:00:00Z"),
+	}
+	rssSearch := newTestSearch(t, items)
+	rssSearch.context.Config.TrackedTerms = []string{"minister", "site:BT rasende"}
+	ctx := context.Background()
+	da := lang.MustGet(lang.Da)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, da.Location)
+	end := time.Date(2024, 7, 2, 0, 0, 0, 0, da.Location)
+
+	counted := func() []string {
+		t.Helper()
+		got := []string{}
+		for _, term := range []string{"rasende", "minister"} {
+			byWeek, err := rssSearch.CountByBucket(ctx, "da", term, false, core.SearchFilter{}, &start, &end, core.BucketWeek, da.Location)
+			if err != nil {
+				t.Fatalf("CountByBucket %q: %v", term, err)
+			}
+			for _, week := range byWeek {
+				got = append(got, fmt.Sprintf("%v %v=%v", term, week.Timestamp.Format(time.DateOnly), week.Count))
+			}
+			bySite, err := rssSearch.CountBySite(ctx, "da", term, false, core.SearchFilter{}, &start, &end)
+			if err != nil {
+				t.Fatalf("CountBySite %q: %v", term, err)
+			}
+			for _, site := range bySite {
+				got = append(got, fmt.Sprintf("%v site %v=%v", term, site.SiteId, site.Count))
+			}
+		}
+		return got
+	}
+	summed := func() int {
+		t.Helper()
+		conn, err := db.Open(rssSearch.context.Config)
+		if err != nil {
+			t.Fatalf("open db: %v", err)
+		}
+		var sum int
+		if err := conn.QueryRow("SELECT coalesce(sum(count), 0) FROM term_counts WHERE term = 'rasende'").Scan(&sum); err != nil {
+			t.Fatalf("sum term counts: %v", err)
+		}
+		return sum
+	}
+
+	want := []string{"rasende 2024-02-26=2", "rasende 2024-07-01=1", "rasende site 1=3", "minister 2024-03-04=1", "minister 2024-07-01=1", "minister site 1=2"}
+	if got := counted(); !equal(got, want) {
+		t.Errorf("from the index = %v, want %v", got, want)
+	}
+	// rasende and outrage, and minister in both editions; the site: filter
+	// cannot be rolled up.
+	if terms, err := rssSearch.RollUpTerms(ctx, false); err != nil || terms != 4 {
+		t.Fatalf("RollUpTerms = %v, %v, want 4 terms", terms, err)
+	}
+	if got := counted(); !equal(got, want) {
+		t.Errorf("rolled up = %v, want %v", got, want)
+	}
+	if terms, err := rssSearch.RollUpTerms(ctx, false); err != nil || terms != 0 {
+		t.Errorf("second RollUpTerms = %v, %v, want nothing left to roll up", terms, err)
+	}
+
+	if _, err := rssSearch.repository.InsertItems(ctx, testSite, []core.RssItemDto{item(t, "again", "Rasende igen", "", "2024-03-02T12:00:00Z")}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := rssSearch.repository.ReviseItem(ctx, testSite, "early", item(t, "early", "Glad tidligt", "", "2024-03-02T07:00:00+01:00")); err != nil {
+		t.Fatalf("revise: %v", err)
+	}
+	if _, err := rssSearch.repository.DeleteItems(ctx, testSite, []string{"summer"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	want = []string{"rasende 2024-02-26=2", "rasende site 1=2", "minister 2024-03-04=1", "minister site 1=1"}
+	if got := counted(); !equal(got, want) {
+		t.Errorf("rolled up after changes = %v, want %v", got, want)
+	}
+	if got := summed(); got != 2 {
+		t.Errorf("term_counts of rasende sum to %v, want 2", got)
+	}
+
+	if _, _, err := rssSearch.Rebuild(ctx); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if got := counted(); !equal(got, want) {
+		t.Errorf("rolled up after rebuild = %v, want %v", got, want)
+	}
+	if got := summed(); got != 2 {
+		t.Errorf("term_counts of rasende after rebuild sum to %v, want 2", got)
+	}
+}
+
+// A term taken out of TRACKED_TERMS is not counted as items come in, so its
+// rollup is dropped; put back, it is counted afresh, items missed included.
+func TestUntrackedTermLosesItsRollup(t *testing.T) {
+	rssSearch := newTestSearch(t, []core.RssItemDto{
+		item(t, "before", "Minister før", "", "2024-03-01T12:00:00Z"),
+	})
+	rssSearch.context.Config.TrackedTerms = []string{"minister"}
+	ctx := context.Background()
+	da := lang.MustGet(lang.Da)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, da.Location)
+	end := time.Date(2024, 4, 1, 0, 0, 0, 0, da.Location)
+	rows := func() int {
+		t.Helper()
+		conn, err := db.Open(rssSearch.context.Config)
+		if err != nil {
+			t.Fatalf("open db: %v", err)
+		}
+		var n int
+		if err := conn.QueryRow("SELECT (SELECT count(*) FROM term_counts WHERE term = 'minister') + (SELECT count(*) FROM rolled_up_terms WHERE term = 'minister')").Scan(&n); err != nil {
+			t.Fatalf("count rows: %v", err)
+		}
+		return n
+	}
+
+	if _, err := rssSearch.RollUpTerms(ctx, false); err != nil {
+		t.Fatalf("RollUpTerms: %v", err)
+	}
+	if got := rows(); got != 3 {
+		t.Fatalf("rows of minister = %v, want a day and both editions rolled up", got)
+	}
+
+	rssSearch.context.Config.TrackedTerms = nil
+	if terms, err := rssSearch.RollUpTerms(ctx, false); err != nil || terms != 0 {
+		t.Fatalf("RollUpTerms untracked = %v, %v, want nothing to roll up", terms, err)
+	}
+	if got := rows(); got != 0 {
+		t.Errorf("rows of minister once untracked = %v, want none", got)
+	}
+	if _, err := rssSearch.repository.InsertItems(ctx, testSite, []core.RssItemDto{item(t, "meanwhile", "Minister imens", "", "2024-03-02T12:00:00Z")}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	rssSearch.context.Config.TrackedTerms = []string{"minister"}
+	if terms, err := rssSearch.RollUpTerms(ctx, false); err != nil || terms != 2 {
+		t.Fatalf("RollUpTerms tracked again = %v, %v, want minister in both editions", terms, err)
+	}
+	bySite, err := rssSearch.CountBySite(ctx, "da", "minister", false, core.SearchFilter{}, &start, &end)
+	if err != nil {
+		t.Fatalf("CountBySite: %v", err)
+	}
+	if len(bySite) != 1 || bySite[0].Count != 2 {
+		t.Errorf("CountBySite = %+v, want both items of site 1", bySite)
+	}
+}
+
+// A day whose matches were all deleted is left in term_counts at 0, and must
+// not hide the site's other days from the sum.
+func TestRolledUpSiteWithAnEmptiedDay(t *testing.T) {
+	rssSearch := newTestSearch(t, []core.RssItemDto{
+		item(t, "first", "Rasende først", "", "2024-03-01T12:00:00Z"),
+		item(t, "second", "Rasende siden", "", "2024-03-02T12:00:00Z"),
+	})
+	ctx := context.Background()
+	da := lang.MustGet(lang.Da)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, da.Location)
+	end := time.Date(2024, 3, 3, 0, 0, 0, 0, da.Location)
+	if _, err := rssSearch.RollUpTerms(ctx, false); err != nil {
+		t.Fatalf("RollUpTerms: %v", err)
+	}
+	if _, err := rssSearch.repository.DeleteItems(ctx, testSite, []string{"first"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	bySite, err := rssSearch.CountBySite(ctx, "da", "rasende", false, core.SearchFilter{}, &start, &end)
+	if err != nil {
+		t.Fatalf("CountBySite: %v", err)
+	}
+	if len(bySite) != 1 || bySite[0].Count != 1 {
+		t.Errorf("CountBySite = %+v, want the other day of site 1", bySite)
+	}
+}
+
+// The articles a site published in a chart's period are summed from
+// article_counts, and must come out as counting them from rss_items does, with
+// the items inserted and deleted after.
+func TestArticleCountsFollowTheItems(t *testing.T) {
+	sports := item(t, "sports", "Sport", "", "2024-03-02T12:00:00Z")
+	sports.Categories = []string{"Sport"}
+	rssSearch := newTestSearch(t, []core.RssItemDto{
+		item(t, "before", "Før", "", "2024-02-29T22:59:59Z"),
+		item(t, "first", "Første", "", "2024-02-29T23:00:00Z"),
+		sports,
+		item(t, "last", "Sidste", "", "2024-03-02T22:59:59Z"),
+		item(t, "after", "Efter", "", "2024-03-02T23:00:00Z"),
+	})
+	ctx := context.Background()
+	da := lang.MustGet(lang.Da)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, da.Location)
+	end := time.Date(2024, 3, 3, 0, 0, 0, 0, da.Location)
+	offHour := start.Add(30 * time.Minute)
+	sites := []int{testSite.Id, englishSite.Id}
+
+	counted := func(filter core.SearchFilter, start *time.Time, end *time.Time) int {
+		t.Helper()
+		counts, err := rssSearch.CountArticlesBySite(ctx, sites, filter, start, end)
+		if err != nil {
+			t.Fatalf("CountArticlesBySite: %v", err)
+		}
+		if _, ok := counts[englishSite.Id]; ok {
+			t.Errorf("counts = %v, want no site without items", counts)
+		}
+		return counts[testSite.Id]
+	}
+	if got := counted(core.SearchFilter{}, &start, &end); got != 3 {
+		t.Errorf("articles over the days = %v, want 3", got)
+	}
+	if got := counted(core.SearchFilter{}, nil, nil); got != 5 {
+		t.Errorf("articles of all time = %v, want 5", got)
+	}
+	if got := counted(core.SearchFilter{}, &offHour, nil); got != 3 {
+		t.Errorf("articles from off the hour = %v, want 3", got)
+	}
+	if got := counted(core.SearchFilter{Category: "sport"}, &start, &end); got != 1 {
+		t.Errorf("articles in a category = %v, want 1", got)
+	}
+
+	if _, err := rssSearch.repository.InsertItems(ctx, testSite, []core.RssItemDto{item(t, "again", "Igen", "", "2024-03-01T12:00:00Z")}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := rssSearch.repository.DeleteItems(ctx, testSite, []string{"first", "after"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := counted(core.SearchFilter{}, &start, &end); got != 3 {
+		t.Errorf("articles over the days after changes = %v, want 3", got)
+	}
+	if got := counted(core.SearchFilter{}, nil, &end); got != 4 {
+		t.Errorf("articles up to the end after changes = %v, want 4", got)
+	}
+
+	conn, err := db.Open(rssSearch.context.Config)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if _, err := conn.Exec("DELETE FROM article_counts"); err != nil {
+		t.Fatalf("empty article counts: %v", err)
+	}
+	if got := counted(core.SearchFilter{}, &start, &end); got != 0 {
+		t.Errorf("articles over the days without article_counts = %v, want them summed from it", got)
+	}
+}
@@ -0,0 +1,15 @@
+-- +goose Up
+
+-- The validators each feed URL last answered with. They are sent back as
+-- If-None-Match / If-Modified-Since on the next fetch, so an unchanged feed costs
+-- a 304 instead of a download and a parse. Keyed by URL rather than by site: a
+-- site with several feeds gets an answer per feed.
+CREATE TABLE IF NOT EXISTS feed_cache(
+    url TEXT PRIMARY KEY,
+    etag TEXT,
+    last_modified TEXT,
+    updated_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS feed_cache;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- How each feed URL has been doing, so a broken feed is backed off instead of
+-- being hit again on every run. next_fetch_at is NULL while the feed is healthy;
+-- once it is set, the feed is skipped until then. A success clears it again.
+CREATE TABLE IF NOT EXISTS feed_health(
+    url TEXT PRIMARY KEY,
+    site_id INTEGER NOT NULL,
+    last_success_at TIMESTAMP,
+    last_error_at TIMESTAMP,
+    last_error TEXT,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    next_fetch_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS feed_health;
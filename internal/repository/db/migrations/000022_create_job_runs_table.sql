@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- One row per run of a background job: fetching items, rebuilding the search
+-- index, cleaning up fake news. A run is inserted as 'running' when it starts and
+-- updated to 'succeeded' or 'failed' when it ends, so a long rebuild can be
+-- watched. counters is a JSON object of whatever the job counts, e.g. items
+-- inserted per site.
+CREATE TABLE IF NOT EXISTS job_runs(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    status TEXT NOT NULL,
+    error TEXT,
+    counters TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_job_runs_started_at ON job_runs(started_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS job_runs;
@@ -0,0 +1,56 @@
+-- +goose Up
+
+-- The news sites, which used to be compiled in from sitedata/rss.json, so that
+-- adding or disabling one no longer takes a redeploy. urls and
+-- blocked_title_patterns are JSON arrays. position is the order the sites are
+-- listed in, which rss.json expressed by the order of its entries.
+CREATE TABLE IF NOT EXISTS sites(
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    urls TEXT NOT NULL DEFAULT '[]',
+    description TEXT NOT NULL DEFAULT '',
+    language TEXT NOT NULL,
+    disabled INTEGER NOT NULL DEFAULT 0,
+    article_has_content INTEGER NOT NULL DEFAULT 0,
+    user_agent_key TEXT NOT NULL DEFAULT '',
+    blocked_title_patterns TEXT NOT NULL DEFAULT '[]',
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+-- Seeded with rss.json as it was when it was removed. The ids are kept: every
+-- rss_items and fake_news row refers to its site by them.
+INSERT INTO sites (id, name, urls, description, language, disabled, article_has_content, user_agent_key, blocked_title_patterns, position) VALUES
+    (10, 'Ekstrabladet', '["https://ekstrabladet.dk/rssfeed/all/"]', 'Ekstra Bladet is a Danish news media and one of the country''s most well-known newspapers. The newspaper was founded in 1904 and has since been a significant player in Danish journalism. As a popular news medium, Ekstra Bladet has a distinctive style that combines news with entertainment and sensational stories. The newspaper is known for its headlines and articles with high attention value and a provocative approach. Ekstra Bladet appeals to readers who want a more lively and entertaining news experience.', 'da', 0, 0, '', '[]', 1),
+    (4, 'BT', '["https://www.bt.dk/bt/seneste/rss"]', 'BT is a Danish news media considered one of the country''s largest newspapers. It was founded in 1916 and has since been a significant player in Danish journalism. Editorially, BT is regarded as a more sensational newspaper with a focus on tabloid stories. The newspaper is known for its eye-catching and often controversial headlines and articles. This approach appeals to readers who seek a more entertaining and provocative news angle.', 'da', 0, 0, '', '[]', 2),
+    (2, 'Avisen Danmark', '["https://avisendanmark.dk/feed/forside"]', 'Avisen.dk is a Danish tabloid online newspaper, originally run by Nyhedsavisen and later Freeway ApS.', 'da', 0, 0, '', '[]', 3),
+    (11, 'Fyens', '["https://fyens.dk/feed/forside"]', 'Fyens is a Danish news media that primarily focuses on covering events and stories from Funen, an island in Denmark. Fyens is also the name of the newspaper, which was founded in 1772 and has a long history in Danish journalism. Fyens is published both in print and digitally, providing news, reports, interviews, and analyses on various topics, including politics, society, culture, sports, and local matters related to Funen. The newspaper has a team of journalists and editors who work to report on important events, issues, and interesting stories from the area.', 'da', 0, 0, '', '[]', 4),
+    (14, 'Jyllands-Posten', '["https://jyllands-posten.dk/?service=rssfeed&mode=area&areaNames=level0,topflow"]', 'Jyllands-Posten is a Danish newspaper and one of the country''s most prominent and influential news media. The newspaper was founded in 1871 and has since played a significant role in Danish media history. Jyllands-Posten is published both in print and digitally, covering a wide range of topics, including politics, society, economy, culture, sports, and international events. The newspaper has a large number of journalists and correspondents reporting from Denmark and around the world. Jyllands-Posten is known for providing comprehensive news coverage and in-depth analyses.', 'da', 0, 0, '', '[]', 5),
+    (17, 'Politiken', '["https://politiken.dk/rss/senestenyt.rss"]', 'Politiken is a Danish newspaper and one of the country''s most significant news media. The newspaper was founded in 1884 and has since been a prominent player in Danish journalism. Politiken is published both in print and digitally, covering a broad spectrum of topics, including politics, society, culture, economy, science, and sports. The newspaper has an extensive network of journalists and correspondents reporting from Denmark and the rest of the world. Politiken emphasizes thorough and analytical journalism, with angles and perspectives that challenge the established mindset.', 'da', 0, 0, '', '[]', 6),
+    (13, 'JydskeVestkysten', '["https://jv.dk/feed/forside"]', 'JydskeVestkysten, abbreviated as JV, is Denmark''s largest regional news media, measured both by the number of weekly users of jv.dk, which is half a million, and the number of weekly readers, which according to Kantar Gallup in 2021 was 185,000. It is published in Southern and South Jutland and has its headquarters in Esbjerg.', 'da', 0, 0, '', '[]', 7),
+    (5, 'Berlingske', '["https://www.berlingske.dk/content/rss"]', 'Berlingske is a Danish newspaper and one of Denmark''s oldest existing news media. The newspaper was founded in 1749 and has since played a significant role in Danish media history. Berlingske is reputed to be a serious and trustworthy source for news, analysis, and debate.', 'da', 0, 0, '', '[".+– følg med her$"]', 8),
+    (8, 'DR', '["https://www.dr.dk/nyheder/service/feeds/senestenyt"]', 'DR, formerly Danmarks Radio, is Denmark''s national public service company, which disseminates news, culture, entertainment, and information via TV, radio, dr.dk, and mobile platforms in accordance with the Radio and Television Act.', 'da', 0, 0, '', '[]', 9),
+    (19, 'TV2', '["https://feeds.services.tv2.dk/api/feeds/nyheder/rss"]', 'TV 2 is a Danish TV channel operated by the public service TV station TV 2 Denmark. It competes with DR1, owned by DR, to be Denmark''s most-watched TV channel.', 'da', 0, 1, '', '[]', 10),
+    (9, 'Dagens', '["https://www.dagens.dk/feed"]', 'We want to be remembered as the online media that focuses on quirky angles, different stories, and interviews with people who don''t usually make their way into the Danish media.', 'da', 0, 0, '', '[]', 11),
+    (6, 'Børsen', '["https://borsen.dk/rss"]', 'Much more than Denmark''s leading business newspaper. Børsen sets the agenda for business - all business. We write interestingly and with edge. On all platforms. About a business world in motion. For modern people who seek content and enrichment - whether they are out, at home, at work, or on the go.', 'da', 0, 0, 'chrome', '[]', 12),
+    (15, 'Kristeligt Dagblad', '["https://www.kristeligt-dagblad.dk/rss/nyheder"]', 'Kristeligt Dagblad is the only Danish newspaper with special pages on church and faith. Our church and faith editorial team deals daily with the current development in the Danish National Church and all other major church and religious communities, as well as writing about Danes'' relationship to religious and ethical issues in general.', 'da', 0, 0, '', '[]', 13),
+    (21, 'Viborg Folkeblad', '["https://viborg-folkeblad.dk/feed/forside"]', 'Viborg Stifts Folkeblad is a Danish daily newspaper, primarily published in Viborg Municipality.', 'da', 0, 0, '', '[]', 14),
+    (16, 'Nordjyske', '["https://nordjyske.dk/rss/nyheder"]', 'Nordjyske Stiftstidende is a Danish regional newspaper published in Aalborg.', 'da', 0, 0, '', '[]', 15),
+    (7, 'Computerworld', '["https://www.computerworld.dk/rss/all"]', 'Computerworld is Denmark''s largest IT media, with over half a million visitors every month. Computerworld caters to professional IT users. The primary focus is not on IT products but rather on the parts of business and society that use IT.', 'da', 0, 0, '', '[]', 16),
+    (12, 'Ingeniøren', '["https://ing.dk/rss/nyheder"]', 'Ingeniøren, also known as Nyhedsmagasinet Ingeniøren, is a Danish engineering magazine focusing on developments in technology and natural sciences within a societal context.', 'da', 0, 0, '', '[]', 17),
+    (1, 'Arbejderen', '["https://arbejderen.dk/feed/"]', 'Arbejderen is the fighting Denmark''s digital daily newspaper. Our readers are active in the trade union movement, social resistance movements, the climate fight, the housing movement, and the fight against racism and reaction, for peace and international solidarity. Arbejderen has no ambition to be first with the latest, but instead aims to create an overview and show connections in the constant stream of isolated news. Arbejderen describes reality as it is, with the aim of changing it. Our goal is to deliver solid, red quality journalism.', 'da', 0, 1, '', '[]', 18),
+    (20, 'Version2', '["https://www.version2.dk/rss"]', 'Version2 is Denmark''s largest publisher of media about technology and science. We produce journalism in all areas of technology, engineering, IT, and natural sciences. Our media spans newspaper and magazine production, live events, trade shows, websites, newsletters, and podcasts. Together, we manage a range of titles, from general coverage of technology aimed at a broad group in society to highly specialized communication on new technologies and selected industries. Our mission is to bridge the gap between the decision-makers and opinion leaders in the digitalized society and the specialists who work daily with the development of technology and its impact on the surrounding society.', 'da', 0, 0, '', '[]', 19),
+    (18, 'Ritzau', '["https://via.ritzau.dk/rss/releases/latest"]', 'Ritzau is a trustworthy, fast, and market-oriented news agency that sells targeted news products and media services to media houses, companies, and organizations.', 'da', 0, 0, '', '[]', 20),
+    (3, 'BILLED-BLADET', '[]', 'Billed-Bladet (stylized as BILLED-BLADET) is "Denmark''s royal weekly magazine," published since April 5, 1938. BILLED-BLADET mainly deals with news and stories related to the royal family but also contains plenty of articles about Danish and international celebrities and royals - as well as a large TV guide.', 'da', 1, 0, '', '[]', 21),
+    (22, 'KøbenhavnLIV', '["https://kobenhavnliv.dk/feed/forside"]', 'KøbenhavnLIV is a local newspaper covering news and events from Copenhagen and the capital area.', 'da', 0, 0, '', '[]', 22),
+    (23, 'BBC News', '["https://feeds.bbci.co.uk/news/rss.xml"]', 'BBC News is the news division of the British Broadcasting Corporation, the UK''s public service broadcaster, founded in 1922. It is one of the largest and most widely trusted news organisations in the world, known for measured, impartial reporting and a formal, restrained tone. It covers UK and international news, politics, business and culture for a broad general audience.', 'en', 0, 0, 'chrome', '[]', 23),
+    (24, 'CNN', '["http://rss.cnn.com/rss/edition.rss"]', 'CNN is an American cable news network founded in 1980, and was the first channel to provide round-the-clock television news coverage. Its international edition covers world news, US politics, business and breaking events with an urgent, fast-moving style that leans heavily on live coverage and developing stories.', 'en', 0, 0, 'chrome', '[]', 24),
+    (25, 'The Guardian', '["https://www.theguardian.com/uk/rss"]', 'The Guardian is a British daily newspaper founded in 1821, known for its left-leaning, liberal editorial stance and its investigative journalism. It covers politics, world news, the environment, culture and social affairs, often with a campaigning tone and a strong focus on inequality, climate and human rights.', 'en', 0, 0, 'chrome', '[]', 25),
+    (26, 'Sky News', '["https://feeds.skynews.com/feeds/rss/home.xml"]', 'Sky News is a British free-to-air television news channel, known for rolling breaking-news coverage and a brisk, punchy style. It covers UK politics, world events, business and sport for a mainstream audience, with a strong emphasis on immediacy and live reporting.', 'en', 0, 0, 'chrome', '[]', 26),
+    (27, 'The Mirror', '["https://www.mirror.co.uk/news/rss.xml"]', 'The Mirror is a British tabloid newspaper founded in 1903, traditionally left-leaning and working-class in outlook. It mixes hard news with celebrity gossip, human-interest stories and sensational headlines, and is known for an emotive, dramatic tone and a fondness for outrage and scandal.', 'en', 0, 0, 'chrome', '[]', 27),
+    (28, 'Daily Express', '["https://www.express.co.uk/posts/rss/1"]', 'The Daily Express is a British tabloid newspaper founded in 1900, with a right-leaning, populist editorial stance. It is known for dramatic front pages, strong opinions on politics and immigration, weather scare stories and health scares, delivered in an urgent and often indignant tone.', 'en', 0, 0, 'chrome', '[]', 28),
+    (29, 'Metro', '["https://metro.co.uk/news/feed/"]', 'Metro is a British free tabloid newspaper distributed on public transport, aimed at commuters. It covers news, entertainment and quirky human-interest stories in a light, snappy, easily digestible style, mixing serious headlines with the odd and the absurd.', 'en', 0, 0, 'chrome', '[]', 29),
+    (30, 'New York Post', '["https://nypost.com/feed/"]', 'The New York Post is an American tabloid newspaper founded in 1801, known for its brash, irreverent style and its famously punning headlines. It leans conservative, and covers New York City news, US politics, crime, celebrity and sport with a loud, opinionated and often provocative voice.', 'en', 0, 0, 'chrome', '[]', 30),
+    (31, 'The Sun', '["https://www.thesun.co.uk/news/feed/"]', 'The Sun is a British tabloid newspaper founded in 1964 and the country''s best-known red-top. It is famous for its blunt, punchy headlines, wordplay and sensationalism, mixing celebrity, football, crime and populist right-leaning politics in a deliberately loud and informal register.', 'en', 0, 0, 'chrome', '[]', 31);
+
+-- +goose Down
+DROP TABLE IF EXISTS sites;
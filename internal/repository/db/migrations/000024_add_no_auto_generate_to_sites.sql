@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Sites the scheduled fake news generation leaves alone. It replaces a map that
+-- was hard-coded in the API handler, which held DR (8) and TV2 (19).
+ALTER TABLE sites ADD COLUMN no_auto_generate INTEGER NOT NULL DEFAULT 0;
+UPDATE sites SET no_auto_generate = 1 WHERE id IN (8, 19);
+
+-- +goose Down
+ALTER TABLE sites DROP COLUMN no_auto_generate;
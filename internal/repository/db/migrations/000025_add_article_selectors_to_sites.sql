@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- CSS selectors for pulling the article body out of a site's article pages, for
+-- the sites whose feed only carries a teaser. An empty article_selector means the
+-- site is not extracted. article_exclude_selector matches elements inside the
+-- body to drop first, such as ads and "read also" boxes.
+ALTER TABLE sites ADD COLUMN article_selector TEXT NOT NULL DEFAULT '';
+ALTER TABLE sites ADD COLUMN article_exclude_selector TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE sites DROP COLUMN article_exclude_selector;
+ALTER TABLE sites DROP COLUMN article_selector;
@@ -0,0 +1,15 @@
+-- +goose Up
+
+-- What the feed says about an item beyond its title and text. authors and
+-- categories hold JSON arrays of strings. Items stored before this migration have
+-- NULL in all four, and are treated as having none.
+ALTER TABLE rss_items ADD COLUMN authors TEXT;
+ALTER TABLE rss_items ADD COLUMN categories TEXT;
+ALTER TABLE rss_items ADD COLUMN image_url TEXT;
+ALTER TABLE rss_items ADD COLUMN guid TEXT;
+
+-- +goose Down
+ALTER TABLE rss_items DROP COLUMN guid;
+ALTER TABLE rss_items DROP COLUMN image_url;
+ALTER TABLE rss_items DROP COLUMN categories;
+ALTER TABLE rss_items DROP COLUMN authors;
@@ -0,0 +1,26 @@
+-- +goose Up
+
+-- Outlets rewrite headlines after publishing. An item is now identified by its
+-- feed GUID, or failing that its link, rather than by a hash of title and link, so
+-- a rewrite updates the stored item instead of arriving as a new one. These
+-- indexes serve the lookups that find the stored item, including the items that
+-- still carry an id of the old kind.
+CREATE INDEX IF NOT EXISTS idx_rss_items_site_id_guid ON rss_items(site_id, guid);
+CREATE INDEX IF NOT EXISTS idx_rss_items_site_id_link ON rss_items(site_id, link);
+
+-- Every version of an item's title and content, from when it was first seen.
+-- An item that was never changed has no rows here: rss_items holds its only
+-- version. The first change records both the original and the new version.
+CREATE TABLE IF NOT EXISTS rss_item_revisions(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rss_item_id INTEGER NOT NULL REFERENCES rss_items(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    content TEXT,
+    seen_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_rss_item_revisions_rss_item_id ON rss_item_revisions(rss_item_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS rss_item_revisions;
+DROP INDEX IF EXISTS idx_rss_items_site_id_link;
+DROP INDEX IF EXISTS idx_rss_items_site_id_guid;
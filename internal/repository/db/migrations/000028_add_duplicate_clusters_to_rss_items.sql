@@ -0,0 +1,26 @@
+-- +goose Up
+
+-- The same article arrives more than once: from several feeds of one site, and
+-- as a syndicated wire story on several sites. canonical_link is the link with
+-- tracking parameters and the like stripped, or the article page's own
+-- rel=canonical once it has been fetched. title_fingerprint is the title's
+-- stemmed search terms. An item joins the cluster of a recent item that shares
+-- either, and otherwise starts its own: cluster_id is the id of the cluster's
+-- first item.
+--
+-- Items stored before this migration have NULL in all three, and each counts as
+-- a cluster of its own.
+ALTER TABLE rss_items ADD COLUMN canonical_link TEXT;
+ALTER TABLE rss_items ADD COLUMN title_fingerprint TEXT;
+ALTER TABLE rss_items ADD COLUMN cluster_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_rss_items_canonical_link ON rss_items(canonical_link);
+CREATE INDEX IF NOT EXISTS idx_rss_items_title_fingerprint ON rss_items(title_fingerprint);
+CREATE INDEX IF NOT EXISTS idx_rss_items_cluster_id ON rss_items(cluster_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_rss_items_cluster_id;
+DROP INDEX IF EXISTS idx_rss_items_title_fingerprint;
+DROP INDEX IF EXISTS idx_rss_items_canonical_link;
+ALTER TABLE rss_items DROP COLUMN cluster_id;
+ALTER TABLE rss_items DROP COLUMN title_fingerprint;
+ALTER TABLE rss_items DROP COLUMN canonical_link;
@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- What a site's urls point at: 'rss' (RSS or Atom), 'jsonfeed' (JSON Feed), or
+-- 'html', a listing page for outlets without a usable feed. html_source holds
+-- the CSS selectors that pull the headlines out of an html site's pages, as
+-- JSON; it is '{}' for the other types.
+ALTER TABLE sites ADD COLUMN source_type TEXT NOT NULL DEFAULT 'rss';
+ALTER TABLE sites ADD COLUMN html_source TEXT NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE sites DROP COLUMN html_source;
+ALTER TABLE sites DROP COLUMN source_type;
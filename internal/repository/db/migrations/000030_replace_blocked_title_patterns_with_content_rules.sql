@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- content_rules is a JSON list of {"action", "field", "pattern"} objects that
+-- block or allow a site's items by title, link path, category or author. It
+-- replaces blocked_title_patterns, whose patterns become title block rules.
+ALTER TABLE sites ADD COLUMN content_rules TEXT NOT NULL DEFAULT '[]';
+UPDATE sites SET content_rules = (
+    SELECT json_group_array(json_object('action', 'block', 'field', 'title', 'pattern', p.value))
+    FROM json_each(sites.blocked_title_patterns) p
+) WHERE json_array_length(blocked_title_patterns) > 0;
+ALTER TABLE sites DROP COLUMN blocked_title_patterns;
+
+-- +goose Down
+ALTER TABLE sites ADD COLUMN blocked_title_patterns TEXT NOT NULL DEFAULT '[]';
+UPDATE sites SET blocked_title_patterns = (
+    SELECT json_group_array(r.value ->> 'pattern')
+    FROM json_each(sites.content_rules) r
+    WHERE r.value ->> 'action' = 'block' AND r.value ->> 'field' = 'title'
+);
+ALTER TABLE sites DROP COLUMN content_rules;
@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- content_retention_months is how long a site's items keep their content: 0
+-- follows the global CONTENT_RETENTION_MONTHS, and a negative number keeps it
+-- forever. archived_at is when an item's content was dropped under that policy;
+-- the title, link and published time stay.
+ALTER TABLE sites ADD COLUMN content_retention_months INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rss_items ADD COLUMN archived_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE rss_items DROP COLUMN archived_at;
+ALTER TABLE sites DROP COLUMN content_retention_months;
@@ -0,0 +1,8 @@
+-- +goose Up
+
+-- A draft site was imported, from OPML, and is waiting to be reviewed. It stays
+-- disabled until then, and may have a language that is not one of the editions.
+ALTER TABLE sites ADD COLUMN draft INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE sites DROP COLUMN draft;
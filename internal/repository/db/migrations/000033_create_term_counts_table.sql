@@ -0,0 +1,30 @@
+-- +goose Up
+
+-- term_counts is a rollup of the matches of the tracked terms: each edition's
+-- default query and the configured TRACKED_TERMS. It holds how many titles of a
+-- site matched a term on a day, the calendar day in the time zone of the site's
+-- edition, so the charts of those terms are summed from it rather than counted
+-- through the search index. The repository keeps it up to date as items are
+-- inserted, revised and deleted.
+--
+-- rolled_up_terms lists the terms whose counts cover every item, not just the
+-- ones inserted since the term was tracked. A term is read from the rollup only
+-- once it is listed here.
+CREATE TABLE IF NOT EXISTS term_counts (
+    term TEXT NOT NULL,
+    site_id INTEGER NOT NULL,
+    day TEXT NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (term, site_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS rolled_up_terms (
+    language TEXT NOT NULL,
+    term TEXT NOT NULL,
+    rolled_up_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (language, term)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS rolled_up_terms;
+DROP TABLE IF EXISTS term_counts;
@@ -0,0 +1,25 @@
+-- +goose Up
+
+-- article_counts is a rollup of how many items each site published in each
+-- hour, the hour as the unix time it starts at. The rates of the charts are out
+-- of a sum of it, rather than a count of rss_items that parses the published
+-- of every row. The repository keeps it up to date as items are inserted and
+-- deleted.
+--
+-- It is kept per hour in UTC, not per day of the edition like term_counts, so
+-- that it can be filled in here: every edition's midnights fall on the hour,
+-- while its calendar days are beyond SQLite.
+CREATE TABLE IF NOT EXISTS article_counts (
+    site_id INTEGER NOT NULL,
+    hour INTEGER NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (site_id, hour)
+);
+
+INSERT INTO article_counts (site_id, hour, count)
+SELECT site_id, unixepoch(published) / 3600 * 3600 AS hour, count(*) FROM rss_items
+WHERE site_id IS NOT NULL AND unixepoch(published) IS NOT NULL
+GROUP BY site_id, hour;
+
+-- +goose Down
+DROP TABLE IF EXISTS article_counts;
@@ -20,8 +20,10 @@ func TestScanRssItemHandlesNulls(t *testing.T) {
 
 	_, err = db.Exec(`CREATE TABLE rss_items(
 		item_id TEXT, site_name TEXT, title TEXT, content TEXT, link TEXT,
-		published TIMESTAMP, inserted_at TIMESTAMP, site_id INTEGER);
-		INSERT INTO rss_items VALUES('a','Testmedie','Rasende',NULL,NULL,'2024-03-01 10:00:00+00:00',NULL,1);`)
+		published TIMESTAMP, inserted_at TIMESTAMP, site_id INTEGER,
+		authors TEXT, categories TEXT, image_url TEXT, guid TEXT,
+		canonical_link TEXT, cluster_id INTEGER);
+		INSERT INTO rss_items VALUES('a','Testmedie','Rasende',NULL,NULL,'2024-03-01 10:00:00+00:00',NULL,1,NULL,NULL,NULL,NULL,NULL,NULL);`)
 	if err != nil {
 		t.Fatalf("seed: %v", err)
 	}
@@ -39,6 +41,9 @@ func TestScanRssItemHandlesNulls(t *testing.T) {
 	if item.InsertedAt != nil {
 		t.Errorf("NULL inserted_at = %v, want nil", item.InsertedAt)
 	}
+	if len(item.Authors) != 0 || len(item.Categories) != 0 || item.ImageUrl != "" || item.Guid != "" {
+		t.Errorf("NULL feed metadata = %q/%q/%q/%q, want none", item.Authors, item.Categories, item.ImageUrl, item.Guid)
+	}
 }
 
 // published and site_id are nullable in fake_news.
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/search"
+)
+
+// A duplicate is looked for among the items published this long either side of
+// the new one. Wire stories reach every site within hours; a matching title a
+// week apart is a recurring headline ("Dagens vejr"), not the same story.
+const clusterWindow = 3 * 24 * time.Hour
+
+// Titles with fewer search terms than this get no fingerprint. Short ones are
+// too generic to tell a duplicate from a coincidence.
+const minFingerprintTerms = 4
+
+// titleFingerprint is what makes two titles near-identical: the same stemmed
+// search terms in the same order, so a changed inflection, stop word or
+// punctuation mark does not tell two copies of a story apart.
+func titleFingerprint(language string, title string) string {
+	terms := search.Analyze(language, title)
+	if len(terms) < minFingerprintTerms {
+		return ""
+	}
+	return search.StemText(language, title)
+}
+
+// assignCluster stores the canonical link and title fingerprint of row id, and
+// puts it in the cluster of the earliest recent item that shares either; failing
+// that, the row starts a cluster of its own.
+func assignCluster(ctx context.Context, tx *sql.Tx, id int64, canonicalLink string, fingerprint string, published time.Time) error {
+	clusterId, err := findCluster(ctx, tx, id, canonicalLink, fingerprint, published)
+	if err != nil {
+		return err
+	}
+	if clusterId == 0 {
+		clusterId = id
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE rss_items SET canonical_link = ?, title_fingerprint = ?, cluster_id = ? WHERE id = ?",
+		nullIfEmpty(canonicalLink), nullIfEmpty(fingerprint), clusterId, id)
+	return err
+}
+
+// findCluster returns the cluster of the earliest item other than id that shares
+// the canonical link or the fingerprint and was published within clusterWindow,
+// or 0 if there is none.
+func findCluster(ctx context.Context, tx *sql.Tx, id int64, canonicalLink string, fingerprint string, published time.Time) (int64, error) {
+	var clusterId int64
+	err := tx.QueryRowContext(ctx, "SELECT coalesce(cluster_id, id) FROM rss_items WHERE id != ? "+
+		"AND (canonical_link = ? OR title_fingerprint = ?) "+
+		"AND datetime(published) BETWEEN datetime(?) AND datetime(?) ORDER BY id ASC LIMIT 1",
+		id, nullIfEmpty(canonicalLink), nullIfEmpty(fingerprint),
+		published.Add(-clusterWindow).UTC().Format(time.RFC3339), published.Add(clusterWindow).UTC().Format(time.RFC3339)).
+		Scan(&clusterId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return clusterId, err
+}
+
+// mergeCluster stores the canonical link of row id, and merges its cluster with
+// that of another recent item with the same link, if there is one. It is how a
+// row joins its duplicates once its article page has named a canonical link the
+// feed did not. The merged cluster keeps the lower id, so it is still named by
+// its first item.
+func mergeCluster(ctx context.Context, tx *sql.Tx, id int64, canonicalLink string, published time.Time) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE rss_items SET canonical_link = ? WHERE id = ?", canonicalLink, id); err != nil {
+		return err
+	}
+	var current sql.NullInt64
+	if err := tx.QueryRowContext(ctx, "SELECT cluster_id FROM rss_items WHERE id = ?", id).Scan(&current); err != nil {
+		return err
+	}
+	target, err := findCluster(ctx, tx, id, canonicalLink, "", published)
+	if err != nil || target == 0 || !current.Valid || target == current.Int64 {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE rss_items SET cluster_id = ? WHERE cluster_id = ?",
+		min(target, current.Int64), max(target, current.Int64))
+	return err
+}
+
+// nullIfEmpty stores "" as NULL, which equals nothing: two items without a
+// fingerprint must not be taken as duplicates of each other.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
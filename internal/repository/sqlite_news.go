@@ -3,17 +3,13 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"slices"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/bjarke-xyz/rasende2/internal/core"
-	"github.com/bjarke-xyz/rasende2/internal/lang"
 	"github.com/bjarke-xyz/rasende2/internal/repository/db"
 	"github.com/bjarke-xyz/rasende2/internal/search"
 	"github.com/bjarke-xyz/rasende2/pkg"
@@ -27,12 +23,9 @@ func NewSqliteNews(appContext *core.AppContext) core.NewsRepository {
 	return &sqliteNewsRepository{appContext: appContext}
 }
 
-//go:embed sitedata
-var dataFs embed.FS
-
 // Column lists, in the order the scan helpers below read them.
 const (
-	rssItemColumns  = "item_id, site_name, title, content, link, published, inserted_at, site_id"
+	rssItemColumns  = "item_id, site_name, title, content, link, published, inserted_at, site_id, authors, categories, image_url, guid, canonical_link, cluster_id"
 	fakeNewsColumns = "site_name, title, content, published, site_id, img_url, highlighted, votes, external_id"
 )
 
@@ -42,12 +35,15 @@ type rowScanner interface {
 }
 
 // scanRssItem reads rssItemColumns. content and link are nullable in the schema
-// but plain strings on the DTO, so a NULL becomes "".
+// but plain strings on the DTO, so a NULL becomes "". So are the feed metadata
+// columns, which are NULL on every item stored before they were added.
 func scanRssItem(scanner rowScanner) (core.RssItemDto, error) {
 	var item core.RssItemDto
-	var content, link *string
+	var content, link, authors, categories, imageUrl, guid, canonicalLink *string
+	var clusterId *int64
 	err := scanner.Scan(&item.ItemId, &item.SiteName, &item.Title, &content, &link,
-		&item.Published, &item.InsertedAt, &item.SiteId)
+		&item.Published, &item.InsertedAt, &item.SiteId, &authors, &categories, &imageUrl, &guid,
+		&canonicalLink, &clusterId)
 	if err != nil {
 		return item, err
 	}
@@ -57,9 +53,37 @@ func scanRssItem(scanner rowScanner) (core.RssItemDto, error) {
 	if link != nil {
 		item.Link = *link
 	}
+	if item.Authors, err = unmarshalStrings(authors); err != nil {
+		return item, fmt.Errorf("item %v has invalid authors: %w", item.ItemId, err)
+	}
+	if item.Categories, err = unmarshalStrings(categories); err != nil {
+		return item, fmt.Errorf("item %v has invalid categories: %w", item.ItemId, err)
+	}
+	if imageUrl != nil {
+		item.ImageUrl = *imageUrl
+	}
+	if guid != nil {
+		item.Guid = *guid
+	}
+	if canonicalLink != nil {
+		item.CanonicalLink = *canonicalLink
+	}
+	if clusterId != nil {
+		item.ClusterId = *clusterId
+	}
 	return item, nil
 }
 
+// unmarshalStrings reads a nullable JSON array of strings. NULL is no values.
+func unmarshalStrings(value *string) ([]string, error) {
+	values := []string{}
+	if value == nil {
+		return values, nil
+	}
+	err := json.Unmarshal([]byte(*value), &values)
+	return values, err
+}
+
 // scanFakeNews reads fakeNewsColumns. published and site_id are nullable.
 func scanFakeNews(scanner rowScanner) (core.FakeNewsDto, error) {
 	var fakeNews core.FakeNewsDto
@@ -97,43 +121,6 @@ func placeholders(n int) string {
 	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
 }
 
-// loadSites parses the embedded rss.json. The file cannot change at runtime, and
-// GetSites is on the search hot path — every search enriches its results and its
-// site counts with site names — so parse it once.
-//
-// It also rejects a site that belongs to no edition. That has to fail here, at
-// startup, because everything downstream — the analyzer that stems its items,
-// the prompt that writes its fake news — takes the language on trust. The
-// alternative is a panic later, in a background fetch or halfway through a
-// request.
-var loadSites = sync.OnceValues(func() ([]core.NewsSite, error) {
-	jsonBytes, err := dataFs.ReadFile("sitedata/rss.json")
-	if err != nil {
-		return nil, fmt.Errorf("could not load rss.json: %w", err)
-	}
-	var newsSites []core.NewsSite
-	if err := json.Unmarshal(jsonBytes, &newsSites); err != nil {
-		return nil, err
-	}
-	for _, site := range newsSites {
-		if _, ok := lang.Get(site.Language); !ok {
-			return nil, fmt.Errorf("site %q (id %v) has language %q, which is not one of the editions", site.Name, site.Id, site.Language)
-		}
-	}
-	return newsSites, nil
-})
-
-// GetSites returns the configured news sites. The slice is cloned because the
-// backing one is shared by every caller: sorting or reordering the result would
-// otherwise corrupt it for everyone.
-func (r *sqliteNewsRepository) GetSites(ctx context.Context) ([]core.NewsSite, error) {
-	sites, err := loadSites()
-	if err != nil {
-		return nil, err
-	}
-	return slices.Clone(sites), nil
-}
-
 func (r *sqliteNewsRepository) GetSiteNames(ctx context.Context) ([]string, error) {
 	rssUrls, err := r.GetSites(ctx)
 	if err != nil {
@@ -274,32 +261,144 @@ func (r *sqliteNewsRepository) EnrichWithSiteNames(ctx context.Context, rssItems
 	}
 }
 
-func (r *sqliteNewsRepository) GetExistingItemsByIds(ctx context.Context, itemIds []string) (map[string]any, error) {
+// GetExistingItems finds the stored versions of items, keyed by the ItemId of
+// the incoming item; an item that is new is absent. An item is matched by its
+// id, failing that by its GUID within the site, and failing that by its link
+// within the site. The link is what finds the items stored while ids were still a
+// hash of title and link, which no longer match by id once the title changes —
+// that being the point. Those were stored under the link as the feed gave it,
+// so an item's FeedLink finds them as well as its Link. A stored item with a different GUID is a different
+// item, whatever its link.
+func (r *sqliteNewsRepository) GetExistingItems(ctx context.Context, siteId int, items []core.RssItemDto) (map[string]core.RssItemDto, error) {
+	result := make(map[string]core.RssItemDto, len(items))
+	if len(items) == 0 {
+		return result, nil
+	}
 	db, err := db.Open(r.appContext.Config)
 	if err != nil {
 		return nil, err
 	}
-	result := make(map[string]any, len(itemIds))
-	if len(itemIds) == 0 {
-		return result, nil
+	ids, guids, links := []any{}, []any{siteId}, []any{siteId}
+	for _, item := range items {
+		ids = append(ids, item.ItemId)
+		if item.Guid != "" {
+			guids = append(guids, item.Guid)
+		}
+		if item.Link != "" {
+			links = append(links, item.Link)
+		}
+		if item.FeedLink != "" && item.FeedLink != item.Link {
+			links = append(links, item.FeedLink)
+		}
 	}
-	args := make([]any, len(itemIds))
-	for i, itemId := range itemIds {
-		args[i] = itemId
+	byId, err := queryItems(ctx, db, "item_id IN ("+placeholders(len(ids))+")", ids)
+	if err != nil {
+		return nil, err
 	}
-	rows, err := db.QueryContext(ctx, "SELECT item_id FROM rss_items WHERE item_id IN ("+placeholders(len(itemIds))+")", args...)
+	var byGuid, byLink []core.RssItemDto
+	if len(guids) > 1 {
+		byGuid, err = queryItems(ctx, db, "site_id = ? AND guid IN ("+placeholders(len(guids)-1)+")", guids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(links) > 1 {
+		byLink, err = queryItems(ctx, db, "site_id = ? AND link IN ("+placeholders(len(links)-1)+")", links)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Rows come oldest first, so where an edit was stored twice under the old ids,
+	// the newer row is the one that wins.
+	idIndex := make(map[string]core.RssItemDto, len(byId))
+	for _, stored := range byId {
+		idIndex[stored.ItemId] = stored
+	}
+	guidIndex := make(map[string]core.RssItemDto, len(byGuid))
+	for _, stored := range byGuid {
+		guidIndex[stored.Guid] = stored
+	}
+	for _, item := range items {
+		if stored, ok := idIndex[item.ItemId]; ok {
+			result[item.ItemId] = stored
+			continue
+		}
+		if stored, ok := guidIndex[item.Guid]; ok && item.Guid != "" {
+			result[item.ItemId] = stored
+			continue
+		}
+		for _, stored := range byLink {
+			sameLink := stored.Link == item.Link || (item.FeedLink != "" && stored.Link == item.FeedLink)
+			if sameLink && (stored.Guid == "" || stored.Guid == item.Guid) {
+				result[item.ItemId] = stored
+			}
+		}
+	}
+	return result, nil
+}
+
+// queryItems returns the items matching where, oldest first.
+func queryItems(ctx context.Context, db *sql.DB, where string, args []any) ([]core.RssItemDto, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+rssItemColumns+" FROM rss_items WHERE "+where+" ORDER BY id ASC", args...)
 	if err != nil {
-		return nil, fmt.Errorf("error getting items by id: %w", err)
+		return nil, fmt.Errorf("error getting items: %w", err)
 	}
 	defer rows.Close()
+	var items []core.RssItemDto
 	for rows.Next() {
-		var itemId string
-		if err := rows.Scan(&itemId); err != nil {
-			return nil, fmt.Errorf("error scanning item id: %w", err)
+		item, err := scanRssItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning item: %w", err)
 		}
-		result[itemId] = struct{}{}
+		items = append(items, item)
 	}
-	return result, rows.Err()
+	return items, rows.Err()
+}
+
+// GetItem returns the item, or nil if there is none by that id.
+func (r *sqliteNewsRepository) GetItem(ctx context.Context, itemId string) (*core.RssItemDto, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return nil, err
+	}
+	item, err := scanRssItem(db.QueryRowContext(ctx, "SELECT "+rssItemColumns+" FROM rss_items WHERE item_id = ?", itemId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting item %v: %w", itemId, err)
+	}
+	items := []core.RssItemDto{item}
+	r.EnrichWithSiteNames(ctx, items)
+	return &items[0], nil
+}
+
+// GetItemRevisions returns every version of the item, oldest first. It is empty
+// for an item that was never changed.
+func (r *sqliteNewsRepository) GetItemRevisions(ctx context.Context, itemId string) ([]core.RssItemRevision, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT r.title, r.content, r.seen_at FROM rss_item_revisions r "+
+		"JOIN rss_items i ON i.id = r.rss_item_id WHERE i.item_id = ? ORDER BY r.seen_at ASC, r.id ASC", itemId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting revisions of %v: %w", itemId, err)
+	}
+	defer rows.Close()
+	revisions := []core.RssItemRevision{}
+	for rows.Next() {
+		var revision core.RssItemRevision
+		var content *string
+		if err := rows.Scan(&revision.Title, &content, &revision.SeenAt); err != nil {
+			return nil, fmt.Errorf("error scanning revision of %v: %w", itemId, err)
+		}
+		if content != nil {
+			revision.Content = *content
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
 }
 
 func (r *sqliteNewsRepository) GetArticleCounts(ctx context.Context) (map[int]int, error) {
@@ -324,6 +423,222 @@ func (r *sqliteNewsRepository) GetArticleCounts(ctx context.Context) (map[int]in
 	return result, rows.Err()
 }
 
+// GetFeedValidators returns the stored validators for those of urls that have
+// any. A URL that was never fetched, or never sent validators, is absent.
+func (r *sqliteNewsRepository) GetFeedValidators(ctx context.Context, urls []string) (map[string]core.FeedValidators, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]core.FeedValidators, len(urls))
+	if len(urls) == 0 {
+		return result, nil
+	}
+	args := make([]any, len(urls))
+	for i, url := range urls {
+		args[i] = url
+	}
+	rows, err := db.QueryContext(ctx, "SELECT url, etag, last_modified FROM feed_cache WHERE url IN ("+placeholders(len(urls))+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting feed validators: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var url string
+		var etag, lastModified *string
+		if err := rows.Scan(&url, &etag, &lastModified); err != nil {
+			return nil, fmt.Errorf("error scanning feed validators: %w", err)
+		}
+		var validators core.FeedValidators
+		if etag != nil {
+			validators.ETag = *etag
+		}
+		if lastModified != nil {
+			validators.LastModified = *lastModified
+		}
+		result[url] = validators
+	}
+	return result, rows.Err()
+}
+
+// SaveFeedValidators stores the validators of each url, replacing what was there.
+// Empty validators are stored too: a feed that stops sending an ETag must stop
+// being asked with the old one.
+func (r *sqliteNewsRepository) SaveFeedValidators(ctx context.Context, validators map[string]core.FeedValidators) error {
+	if len(validators) == 0 {
+		return nil
+	}
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	now := time.Now().UTC()
+	for url, v := range validators {
+		_, err := tx.ExecContext(ctx, "INSERT INTO feed_cache (url, etag, last_modified, updated_at) VALUES (?, ?, ?, ?) "+
+			"on conflict do update set etag = excluded.etag, last_modified = excluded.last_modified, updated_at = excluded.updated_at",
+			url, v.ETag, v.LastModified, now)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to save feed validators for %v: %w", url, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// GetFeedHealth returns the health record of every feed that has been fetched.
+// SiteName is left for the caller to fill in.
+func (r *sqliteNewsRepository) GetFeedHealth(ctx context.Context) ([]core.FeedHealth, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT url, site_id, last_success_at, last_error_at, last_error, consecutive_failures, next_fetch_at FROM feed_health")
+	if err != nil {
+		return nil, fmt.Errorf("error getting feed health: %w", err)
+	}
+	defer rows.Close()
+	var result []core.FeedHealth
+	for rows.Next() {
+		var health core.FeedHealth
+		var lastError *string
+		if err := rows.Scan(&health.Url, &health.SiteId, &health.LastSuccessAt, &health.LastErrorAt, &lastError,
+			&health.ConsecutiveFailures, &health.NextFetchAt); err != nil {
+			return nil, fmt.Errorf("error scanning feed health: %w", err)
+		}
+		if lastError != nil {
+			health.LastError = *lastError
+		}
+		result = append(result, health)
+	}
+	return result, rows.Err()
+}
+
+func (r *sqliteNewsRepository) SaveFeedHealth(ctx context.Context, health core.FeedHealth) error {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO feed_health (url, site_id, last_success_at, last_error_at, last_error, consecutive_failures, next_fetch_at) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?) on conflict do update set site_id = excluded.site_id, last_success_at = excluded.last_success_at, "+
+		"last_error_at = excluded.last_error_at, last_error = excluded.last_error, consecutive_failures = excluded.consecutive_failures, "+
+		"next_fetch_at = excluded.next_fetch_at",
+		health.Url, health.SiteId, health.LastSuccessAt, health.LastErrorAt, health.LastError, health.ConsecutiveFailures, health.NextFetchAt)
+	if err != nil {
+		return fmt.Errorf("error saving feed health for %v: %w", health.Url, err)
+	}
+	return nil
+}
+
+func (r *sqliteNewsRepository) CreateJobRun(ctx context.Context, kind string, startedAt time.Time) (int64, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return 0, err
+	}
+	result, err := db.ExecContext(ctx, "INSERT INTO job_runs (kind, started_at, status) VALUES (?, ?, ?)", kind, startedAt, core.JobRunning)
+	if err != nil {
+		return 0, fmt.Errorf("error creating job run: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// FinishJobRun stores the outcome of a run: its status, finish time, error and
+// counters.
+func (r *sqliteNewsRepository) FinishJobRun(ctx context.Context, run core.JobRun) error {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return err
+	}
+	counters, err := json.Marshal(run.Counters)
+	if err != nil {
+		return fmt.Errorf("error marshalling job run counters: %w", err)
+	}
+	_, err = db.ExecContext(ctx, "UPDATE job_runs SET finished_at = ?, status = ?, error = ?, counters = ? WHERE id = ?",
+		run.FinishedAt, run.Status, run.Error, string(counters), run.Id)
+	if err != nil {
+		return fmt.Errorf("error finishing job run %v: %w", run.Id, err)
+	}
+	return nil
+}
+
+// FailRunningJobRuns marks every run still recorded as running as failed. At
+// startup those are runs the previous process never got to finish.
+func (r *sqliteNewsRepository) FailRunningJobRuns(ctx context.Context, reason string) error {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "UPDATE job_runs SET status = ?, error = ?, finished_at = ? WHERE status = ?",
+		core.JobFailed, reason, time.Now(), core.JobRunning)
+	if err != nil {
+		return fmt.Errorf("error failing running job runs: %w", err)
+	}
+	return nil
+}
+
+const jobRunColumns = "id, kind, started_at, finished_at, status, error, counters"
+
+func scanJobRun(scanner rowScanner) (core.JobRun, error) {
+	var run core.JobRun
+	var runError, counters *string
+	if err := scanner.Scan(&run.Id, &run.Kind, &run.StartedAt, &run.FinishedAt, &run.Status, &runError, &counters); err != nil {
+		return run, err
+	}
+	if runError != nil {
+		run.Error = *runError
+	}
+	if counters != nil && *counters != "" {
+		if err := json.Unmarshal([]byte(*counters), &run.Counters); err != nil {
+			return run, fmt.Errorf("error unmarshalling counters of job run %v: %w", run.Id, err)
+		}
+	}
+	return run, nil
+}
+
+// GetJobRuns returns the most recent runs, newest first.
+func (r *sqliteNewsRepository) GetJobRuns(ctx context.Context, limit int) ([]core.JobRun, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT "+jobRunColumns+" FROM job_runs ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting job runs: %w", err)
+	}
+	defer rows.Close()
+	result := make([]core.JobRun, 0)
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning job run: %w", err)
+		}
+		result = append(result, run)
+	}
+	return result, rows.Err()
+}
+
+// GetJobRun returns nil if there is no run with that id.
+func (r *sqliteNewsRepository) GetJobRun(ctx context.Context, id int64) (*core.JobRun, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return nil, err
+	}
+	run, err := scanJobRun(db.QueryRowContext(ctx, "SELECT "+jobRunColumns+" FROM job_runs WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting job run %v: %w", id, err)
+	}
+	return &run, nil
+}
+
 func (r *sqliteNewsRepository) InsertItems(ctx context.Context, rssUrl core.NewsSite, items []core.RssItemDto) (int, error) {
 	if len(items) == 0 {
 		return 0, nil
@@ -340,11 +655,23 @@ func (r *sqliteNewsRepository) InsertItems(ctx context.Context, rssUrl core.News
 	// Insert one row at a time so that RowsAffected tells us which items were new:
 	// "on conflict do nothing" makes a batch insert unable to report that. Each new
 	// row is indexed in this same transaction, which is what keeps rss_items_fts
-	// from ever drifting out of step with rss_items.
+	// from ever drifting out of step with rss_items, and counted in term_counts
+	// and article_counts.
 	for _, item := range items {
-		result, err := tx.ExecContext(ctx, "INSERT INTO rss_items (item_id, site_name, title, content, link, published, inserted_at, site_id) "+
-			"values (?, '', ?, ?, ?, ?, ?, ?) on conflict do nothing",
-			item.ItemId, item.Title, item.Content, item.Link, item.Published, item.InsertedAt, item.SiteId)
+		authors, err := json.Marshal(nonNil(item.Authors))
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to marshal authors: %w", err)
+		}
+		categories, err := json.Marshal(nonNil(item.Categories))
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to marshal categories: %w", err)
+		}
+		result, err := tx.ExecContext(ctx, "INSERT INTO rss_items (item_id, site_name, title, content, link, published, inserted_at, site_id, authors, categories, image_url, guid) "+
+			"values (?, '', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) on conflict do nothing",
+			item.ItemId, item.Title, item.Content, item.Link, item.Published, item.InsertedAt, item.SiteId,
+			string(authors), string(categories), item.ImageUrl, item.Guid)
 		if err != nil {
 			tx.Rollback()
 			return 0, fmt.Errorf("failed to insert: %w", err)
@@ -369,6 +696,18 @@ func (r *sqliteNewsRepository) InsertItems(ctx context.Context, rssUrl core.News
 			tx.Rollback()
 			return 0, fmt.Errorf("failed to index item %v: %w", item.ItemId, err)
 		}
+		if err := countTerms(ctx, tx, r.appContext.Config, rssUrl, id, item.Published, 1); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to count terms of item %v: %w", item.ItemId, err)
+		}
+		if err := countArticle(ctx, tx, rssUrl, item.Published, 1); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to count item %v: %w", item.ItemId, err)
+		}
+		if err := assignCluster(ctx, tx, id, item.CanonicalLink, titleFingerprint(rssUrl.Language, item.Title), item.Published); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to cluster item %v: %w", item.ItemId, err)
+		}
 	}
 	now := time.Now().UTC()
 	_, err = tx.ExecContext(ctx, "INSERT INTO site_count (site_id, article_count, updated_at) VALUES (?, ?, ?) on conflict do update set article_count = article_count + excluded.article_count, updated_at = excluded.updated_at", rssUrl.Id, len(items), now)
@@ -389,6 +728,301 @@ func (r *sqliteNewsRepository) InsertItems(ctx context.Context, rssUrl core.News
 	return articleCount, nil
 }
 
+// UpdateItemContent replaces an item's content, and reindexes it in the same
+// transaction, so a search on content finds the new text and not the old.
+// canonicalLink is the article page's rel=canonical, or "" if it named none.
+func (r *sqliteNewsRepository) UpdateItemContent(ctx context.Context, rssUrl core.NewsSite, itemId string, content string, canonicalLink string) error {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+	var id int64
+	var title string
+	var published time.Time
+	err = tx.QueryRowContext(ctx, "SELECT id, title, published FROM rss_items WHERE item_id = ?", itemId).Scan(&id, &title, &published)
+	if err != nil {
+		return fmt.Errorf("failed to get item %v: %w", itemId, err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE rss_items SET content = ? WHERE id = ?", content, id); err != nil {
+		return fmt.Errorf("failed to update content of %v: %w", itemId, err)
+	}
+	if err := reindexItem(ctx, tx, rssUrl.Language, id, title, content); err != nil {
+		return fmt.Errorf("failed to index item %v: %w", itemId, err)
+	}
+	if canonicalLink != "" {
+		if err := mergeCluster(ctx, tx, id, canonicalLink, published); err != nil {
+			return fmt.Errorf("failed to cluster item %v: %w", itemId, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
+// ReviseItem brings the stored item up to date with item, a fresh copy of it
+// from the feed, and reports whether its title or content changed. The stored
+// item takes item's id, GUID and link, so an item found through its link is
+// found by id from then on.
+//
+// A change of title or content is recorded in rss_item_revisions. The first one
+// also records the version it replaces, as seen when the item was inserted, so
+// that the revisions hold the whole history.
+// A revised title moves the item's term counts to the terms it matches now.
+func (r *sqliteNewsRepository) ReviseItem(ctx context.Context, rssUrl core.NewsSite, storedItemId string, item core.RssItemDto) (bool, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return false, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+	var id int64
+	var title string
+	var content *string
+	var insertedAt *time.Time
+	var published time.Time
+	err = tx.QueryRowContext(ctx, "SELECT id, title, content, inserted_at, published FROM rss_items WHERE item_id = ?", storedItemId).
+		Scan(&id, &title, &content, &insertedAt, &published)
+	if err != nil {
+		return false, fmt.Errorf("failed to get item %v: %w", storedItemId, err)
+	}
+	authors, err := json.Marshal(nonNil(item.Authors))
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal authors: %w", err)
+	}
+	categories, err := json.Marshal(nonNil(item.Categories))
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal categories: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE rss_items SET item_id = ?, guid = ?, link = ?, authors = ?, categories = ?, image_url = ? WHERE id = ?",
+		item.ItemId, item.Guid, item.Link, string(authors), string(categories), item.ImageUrl, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to update item %v: %w", storedItemId, err)
+	}
+	storedContent := ""
+	if content != nil {
+		storedContent = *content
+	}
+	revised := title != item.Title || storedContent != item.Content
+	if revised {
+		if err := recordRevision(ctx, tx, id, title, storedContent, insertedAt, published, item); err != nil {
+			return false, fmt.Errorf("failed to record revision of %v: %w", storedItemId, err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE rss_items SET title = ?, content = ?, title_fingerprint = ? WHERE id = ?",
+			item.Title, item.Content, nullIfEmpty(titleFingerprint(rssUrl.Language, item.Title)), id); err != nil {
+			return false, fmt.Errorf("failed to revise item %v: %w", storedItemId, err)
+		}
+		if err := countTerms(ctx, tx, r.appContext.Config, rssUrl, id, published, -1); err != nil {
+			return false, fmt.Errorf("failed to count terms of item %v: %w", storedItemId, err)
+		}
+		if err := reindexItem(ctx, tx, rssUrl.Language, id, item.Title, item.Content); err != nil {
+			return false, fmt.Errorf("failed to index item %v: %w", storedItemId, err)
+		}
+		if err := countTerms(ctx, tx, r.appContext.Config, rssUrl, id, published, 1); err != nil {
+			return false, fmt.Errorf("failed to count terms of item %v: %w", storedItemId, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return revised, nil
+}
+
+// recordRevision stores item as the newest version of row id. When the row has
+// no revisions yet, its current title and content go in first, as seen when the
+// row was inserted — or published, for the oldest rows, which have no insert time.
+func recordRevision(ctx context.Context, tx *sql.Tx, id int64, title string, content string, insertedAt *time.Time, published time.Time, item core.RssItemDto) error {
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT count(*) FROM rss_item_revisions WHERE rss_item_id = ?", id).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		seenAt := published
+		if insertedAt != nil {
+			seenAt = *insertedAt
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO rss_item_revisions (rss_item_id, title, content, seen_at) VALUES (?, ?, ?, ?)",
+			id, title, content, seenAt.UTC()); err != nil {
+			return err
+		}
+	}
+	seenAt := time.Now()
+	if item.InsertedAt != nil {
+		seenAt = *item.InsertedAt
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO rss_item_revisions (rss_item_id, title, content, seen_at) VALUES (?, ?, ?, ?)",
+		id, item.Title, item.Content, seenAt.UTC())
+	return err
+}
+
+// reindexItem replaces the search index row of item id. rss_items_fts is
+// contentless, so a row cannot be updated in place: it is deleted and indexed
+// again.
+func reindexItem(ctx context.Context, tx *sql.Tx, language string, id int64, title string, content string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM rss_items_fts WHERE rowid = ?", id); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, search.InsertFtsSQL, id, search.StemText(language, title), search.StemText(language, content))
+	return err
+}
+
+// GetSiteItems returns every stored item of a site, newest first. It is for
+// going over all of a site's items, as a dry run of a content rule does, and
+// is not paged.
+func (r *sqliteNewsRepository) GetSiteItems(ctx context.Context, siteId int) ([]core.RssItemDto, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT "+rssItemColumns+" FROM rss_items WHERE site_id = ? ORDER BY published DESC", siteId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting items for site %v: %w", siteId, err)
+	}
+	defer rows.Close()
+	rssItems := make([]core.RssItemDto, 0)
+	for rows.Next() {
+		item, err := scanRssItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning item for site %v: %w", siteId, err)
+		}
+		rssItems = append(rssItems, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting items for site %v: %w", siteId, err)
+	}
+	r.EnrichWithSiteNames(ctx, rssItems)
+	return rssItems, nil
+}
+
+// DeleteItems deletes the site's items with the given ids, and returns how many
+// there were. The items leave the search index in the same transaction, so a
+// search never finds a row that is gone, and their revisions go with them. The
+// site's article count and term counts are brought down to match.
+func (r *sqliteNewsRepository) DeleteItems(ctx context.Context, rssUrl core.NewsSite, itemIds []string) (int, error) {
+	if len(itemIds) == 0 {
+		return 0, nil
+	}
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+	deleted := 0
+	for _, itemId := range itemIds {
+		var id int64
+		var published time.Time
+		err := tx.QueryRowContext(ctx, "SELECT id, published FROM rss_items WHERE item_id = ? AND site_id = ?", itemId, rssUrl.Id).Scan(&id, &published)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to get item %v: %w", itemId, err)
+		}
+		if err := countTerms(ctx, tx, r.appContext.Config, rssUrl, id, published, -1); err != nil {
+			return 0, fmt.Errorf("failed to count terms of item %v: %w", itemId, err)
+		}
+		if err := countArticle(ctx, tx, rssUrl, published, -1); err != nil {
+			return 0, fmt.Errorf("failed to count item %v: %w", itemId, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rss_items_fts WHERE rowid = ?", id); err != nil {
+			return 0, fmt.Errorf("failed to unindex item %v: %w", itemId, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rss_items WHERE id = ?", id); err != nil {
+			return 0, fmt.Errorf("failed to delete item %v: %w", itemId, err)
+		}
+		deleted++
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE site_count SET article_count = max(article_count - ?, 0), updated_at = ? WHERE site_id = ?",
+		deleted, time.Now().UTC(), rssUrl.Id); err != nil {
+		return 0, fmt.Errorf("failed to update site count: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return deleted, nil
+}
+
+// GetItemsToArchive returns up to limit of the site's items published before
+// publishedBefore that still have their content, oldest first. published is
+// TEXT with varying offsets and fractional-second digits, so it is compared
+// through datetime() rather than lexically.
+func (r *sqliteNewsRepository) GetItemsToArchive(ctx context.Context, siteId int, publishedBefore time.Time, limit int) ([]core.RssItemDto, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT "+rssItemColumns+" FROM rss_items WHERE site_id = ? AND archived_at IS NULL AND datetime(published) < datetime(?) ORDER BY datetime(published), id LIMIT ?",
+		siteId, publishedBefore.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting items to archive for site %v: %w", siteId, err)
+	}
+	defer rows.Close()
+	rssItems := make([]core.RssItemDto, 0)
+	for rows.Next() {
+		item, err := scanRssItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning item for site %v: %w", siteId, err)
+		}
+		rssItems = append(rssItems, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting items to archive for site %v: %w", siteId, err)
+	}
+	r.EnrichWithSiteNames(ctx, rssItems)
+	return rssItems, nil
+}
+
+// ArchiveItems drops the content of the site's items with the given ids, and
+// marks them archived at archivedAt. Each is reindexed by its title alone in the
+// same transaction, so a search on content no longer finds text that is gone.
+// Their revisions keep their titles, but lose their content too: otherwise an
+// item that was ever revised would keep all of it.
+func (r *sqliteNewsRepository) ArchiveItems(ctx context.Context, rssUrl core.NewsSite, itemIds []string, archivedAt time.Time) error {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+	for _, itemId := range itemIds {
+		var id int64
+		var title string
+		err := tx.QueryRowContext(ctx, "UPDATE rss_items SET content = '', archived_at = ? WHERE item_id = ? AND site_id = ? RETURNING id, title",
+			archivedAt, itemId, rssUrl.Id).Scan(&id, &title)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to archive item %v: %w", itemId, err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE rss_item_revisions SET content = '' WHERE rss_item_id = ?", id); err != nil {
+			return fmt.Errorf("failed to archive revisions of %v: %w", itemId, err)
+		}
+		if err := reindexItem(ctx, tx, rssUrl.Language, id, title, ""); err != nil {
+			return fmt.Errorf("failed to index item %v: %w", itemId, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tx: %w", err)
+	}
+	return nil
+}
+
 func (r *sqliteNewsRepository) GetRecentFakeNews(ctx context.Context, limit int, publishedAfter *time.Time) ([]core.FakeNewsDto, error) {
 	db, err := db.Open(r.appContext.Config)
 	var fakeNewsDtos []core.FakeNewsDto
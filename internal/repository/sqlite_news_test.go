@@ -2,27 +2,265 @@ package repository
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/repository/db"
 )
 
-func TestGetSites(t *testing.T) {
-	t.Parallel()
+// newTestRepository returns a repository over a migrated database in a temp dir,
+// which holds the sites the migrations seed.
+func newTestRepository(t *testing.T) core.NewsRepository {
+	t.Helper()
+	cfg := &config.Config{DbConnStr: filepath.Join(t.TempDir(), "test.db")}
+	conn, err := db.Open(cfg)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.Migrate("up", conn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewSqliteNews(&core.AppContext{Config: cfg})
+}
 
-	// NOTE: this is not a usable repository. It is only used to call GetSites, which loads a json file
-	sqliteNewsRepository := NewSqliteNews(nil)
+func TestGetSites(t *testing.T) {
+	sqliteNewsRepository := newTestRepository(t)
 
 	sites, err := sqliteNewsRepository.GetSites(context.Background())
 	if err != nil {
 		t.Errorf("error getting sites: %v", err)
 	}
+	if len(sites) == 0 {
+		t.Fatal("the migrations seeded no sites")
+	}
 	for _, site := range sites {
-		if len(site.BlockedTitlePatterns) > 0 {
+		if len(site.ContentRules) > 0 {
 			t.Run(site.Name, func(t *testing.T) {
-				_, err := site.IsBlockedTitle("test")
+				_, err := site.Blocks(core.RssItemDto{Title: "test"})
 				if err != nil {
-					t.Errorf("error in checking blocked title for site: %v", err)
+					t.Errorf("error in checking content rules for site: %v", err)
 				}
 			})
 		}
 	}
+	// The seeded blocked title patterns were migrated to title block rules.
+	for _, site := range sites {
+		if site.Name != "Berlingske" {
+			continue
+		}
+		if blocked, err := site.Blocks(core.RssItemDto{Title: "Valget – følg med her"}); err != nil || !blocked {
+			t.Errorf("Berlingske lost its blocked title pattern: %+v, %v, %v", site.ContentRules, blocked, err)
+		}
+	}
+}
+
+// A site that is created or changed must show up in the next GetSites, even
+// though GetSites is cached.
+func TestSiteChangesInvalidateCache(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	before, err := repo.GetSites(ctx)
+	if err != nil {
+		t.Fatalf("get sites: %v", err)
+	}
+
+	id, err := repo.CreateSite(ctx, core.NewsSite{Name: "Ny Avis", Language: "da", Urls: []string{"https://example.dk/rss"}})
+	if err != nil {
+		t.Fatalf("create site: %v", err)
+	}
+	sites, err := repo.GetSites(ctx)
+	if err != nil {
+		t.Fatalf("get sites: %v", err)
+	}
+	if len(sites) != len(before)+1 {
+		t.Fatalf("got %v sites after create, want %v", len(sites), len(before)+1)
+	}
+	created := sites[len(sites)-1]
+	if created.Id != id || created.Name != "Ny Avis" || len(created.Urls) != 1 {
+		t.Errorf("created site = %+v, want it listed last", created)
+	}
+
+	if created.SourceType != core.SourceRss {
+		t.Errorf("created site has source type %q, want the default %q", created.SourceType, core.SourceRss)
+	}
+
+	created.Disabled = true
+	created.SourceType = core.SourceHtml
+	created.HtmlSource = core.HtmlSource{ItemSelector: "article", TimeSelector: "time"}
+	if err := repo.UpdateSite(ctx, created); err != nil {
+		t.Fatalf("update site: %v", err)
+	}
+	sites, err = repo.GetSites(ctx)
+	if err != nil {
+		t.Fatalf("get sites: %v", err)
+	}
+	if updated := sites[len(sites)-1]; !updated.Disabled || updated.HtmlSource != created.HtmlSource {
+		t.Errorf("update did not show up in GetSites: %+v", updated)
+	}
+}
+
+// A load that read the sites before an update committed must not put its list
+// in the cache after the update dropped it.
+func TestStaleSiteLoadIsNotCached(t *testing.T) {
+	repo := newTestRepository(t).(*sqliteNewsRepository)
+	ctx := context.Background()
+	key := repo.appContext.Config.ConnectionString()
+	siteCache.RLock()
+	generation := siteCache.generation[key]
+	siteCache.RUnlock()
+	stale, err := repo.loadSites(ctx)
+	if err != nil {
+		t.Fatalf("load sites: %v", err)
+	}
+
+	site := stale[0]
+	site.Disabled = !site.Disabled
+	if err := repo.UpdateSite(ctx, site); err != nil {
+		t.Fatalf("update site: %v", err)
+	}
+	storeSites(key, generation, stale)
+
+	sites, err := repo.GetSites(ctx)
+	if err != nil {
+		t.Fatalf("get sites: %v", err)
+	}
+	if sites[0].Disabled != site.Disabled {
+		t.Errorf("GetSites after a stale load = %+v, want the update", sites[0])
+	}
+}
+
+func TestCreateSiteRejectsInvalidSites(t *testing.T) {
+	repo := newTestRepository(t)
+	invalid := map[string]core.NewsSite{
+		"no edition":    {Name: "Ny Avis", Language: "de"},
+		"no name":       {Language: "da"},
+		"bad pattern":   {Name: "Ny Avis", Language: "da", ContentRules: []core.ContentRule{{Action: core.RuleBlock, Field: core.RuleTitle, Pattern: "("}}},
+		"bad rule":      {Name: "Ny Avis", Language: "da", ContentRules: []core.ContentRule{{Action: core.RuleBlock, Field: "body", Pattern: "x"}}},
+		"enabled draft": {Name: "Ny Avis", Language: "da", Draft: true},
+		"bad selector":  {Name: "Ny Avis", Language: "da", ArticleSelector: "div["},
+		"bad source":    {Name: "Ny Avis", Language: "da", SourceType: "atom"},
+		"no headlines":  {Name: "Ny Avis", Language: "da", SourceType: core.SourceHtml},
+		"bad headline":  {Name: "Ny Avis", Language: "da", SourceType: core.SourceHtml, HtmlSource: core.HtmlSource{ItemSelector: "article", TimeSelector: "time["}},
+	}
+	for name, site := range invalid {
+		if _, err := repo.CreateSite(context.Background(), site); err == nil {
+			t.Errorf("%v: CreateSite succeeded, want an error", name)
+		}
+	}
+}
+
+func TestGetExistingItems(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	sites, err := repo.GetSites(ctx)
+	if err != nil {
+		t.Fatalf("sites: %v", err)
+	}
+	site := sites[0]
+	now := time.Now()
+	stored := []core.RssItemDto{
+		{ItemId: "by-guid", SiteId: site.Id, Title: "A", Link: "https://example.dk/a", Guid: "urn:a", Published: now, InsertedAt: &now},
+		{ItemId: "legacy", SiteId: site.Id, Title: "B", Link: "https://example.dk/b", Published: now, InsertedAt: &now},
+		{ItemId: "other-guid", SiteId: site.Id, Title: "C", Link: "https://example.dk/c", Guid: "urn:c", Published: now, InsertedAt: &now},
+	}
+	if _, err := repo.InsertItems(ctx, site, stored); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	existing, err := repo.GetExistingItems(ctx, site.Id, []core.RssItemDto{
+		{ItemId: "by-guid", Guid: "urn:a"},                             // by id
+		{ItemId: "new-a", Guid: "urn:a", Link: "https://example.dk/x"}, // by GUID, whatever the link
+		{ItemId: "new-b", Guid: "urn:b", Link: "https://example.dk/b"}, // by link, the stored one has no GUID
+		{ItemId: "new-c", Guid: "urn:d", Link: "https://example.dk/c"}, // same link, another GUID: a new item
+	})
+	if err != nil {
+		t.Fatalf("existing: %v", err)
+	}
+	for id, want := range map[string]string{"by-guid": "by-guid", "new-a": "by-guid", "new-b": "legacy"} {
+		if got := existing[id].ItemId; got != want {
+			t.Errorf("%v matched %q, want %q", id, got, want)
+		}
+	}
+	if stored, ok := existing["new-c"]; ok {
+		t.Errorf("new-c matched %v, want no match", stored.ItemId)
+	}
+
+	// Another site's item is never a match, whatever it shares.
+	existing, err = repo.GetExistingItems(ctx, sites[1].Id, []core.RssItemDto{{ItemId: "new-a", Guid: "urn:a", Link: "https://example.dk/a"}})
+	if err != nil {
+		t.Fatalf("existing: %v", err)
+	}
+	if len(existing) != 0 {
+		t.Errorf("matched across sites: %v", existing)
+	}
+}
+
+// An item whose article page names the canonical link of another site's item
+// joins that item's cluster, and takes the rest of its own cluster along.
+func TestUpdateItemContentMergesClusters(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	sites, err := repo.GetSites(ctx)
+	if err != nil {
+		t.Fatalf("sites: %v", err)
+	}
+	now := time.Now()
+	original := core.RssItemDto{ItemId: "original", SiteId: sites[0].Id, Title: "A", Link: "https://example.dk/a", CanonicalLink: "https://example.dk/a", Published: now}
+	if _, err := repo.InsertItems(ctx, sites[0], []core.RssItemDto{original}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	copies := []core.RssItemDto{
+		{ItemId: "copy", SiteId: sites[1].Id, Title: "B", Link: "https://other.dk/b", CanonicalLink: "https://other.dk/b", Published: now},
+		{ItemId: "copy-of-copy", SiteId: sites[1].Id, Title: "C", Link: "https://other.dk/b?page=1", CanonicalLink: "https://other.dk/b", Published: now},
+	}
+	if _, err := repo.InsertItems(ctx, sites[1], copies); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if err := repo.UpdateItemContent(ctx, sites[1], "copy", "", "https://example.dk/a"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	want, err := repo.GetItem(ctx, "original")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	for _, id := range []string{"copy", "copy-of-copy"} {
+		got, err := repo.GetItem(ctx, id)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if got.ClusterId != want.ClusterId {
+			t.Errorf("cluster of %v = %d, want %d", id, got.ClusterId, want.ClusterId)
+		}
+	}
+}
+
+// published is stored with the offset it came with, so the cutoff must be
+// compared as an instant: 10:00 at +05:00 is before 06:00 UTC, though it sorts
+// after it as text.
+func TestGetItemsToArchiveComparesInstants(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	sites, err := repo.GetSites(ctx)
+	if err != nil {
+		t.Fatalf("sites: %v", err)
+	}
+	zone := time.FixedZone("+05", 5*60*60)
+	items := []core.RssItemDto{
+		{ItemId: "east", SiteId: sites[0].Id, Title: "A", Content: "a", Published: time.Date(2024, 3, 1, 10, 0, 0, 0, zone)},
+		{ItemId: "later", SiteId: sites[0].Id, Title: "B", Content: "b", Published: time.Date(2024, 3, 1, 6, 30, 0, 0, time.UTC)},
+	}
+	if _, err := repo.InsertItems(ctx, sites[0], items); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	got, err := repo.GetItemsToArchive(ctx, sites[0].Id, time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC), 10)
+	if err != nil {
+		t.Fatalf("items to archive: %v", err)
+	}
+	if len(got) != 1 || got[0].ItemId != "east" {
+		t.Errorf("items to archive = %+v, want just east", got)
+	}
 }
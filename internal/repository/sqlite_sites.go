@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/lang"
+	"github.com/bjarke-xyz/rasende2/internal/repository/db"
+)
+
+// siteCache holds the sites per database. GetSites is on the search hot path —
+// every search enriches its results and its site counts with site names — and
+// the table only changes through CreateSite and UpdateSite, which drop the
+// entry. It is keyed by connection string rather than held on the repository
+// because db.Open shares one connection per database between every repository
+// built over it; a per-repository cache would miss another's writes.
+//
+// generation counts the invalidations per database. A load that read the table
+// before a write committed would otherwise store its stale list after the write
+// dropped the entry, and it would stay cached until the next write.
+var siteCache = struct {
+	sync.RWMutex
+	byDb       map[string][]core.NewsSite
+	generation map[string]uint64
+}{byDb: make(map[string][]core.NewsSite), generation: make(map[string]uint64)}
+
+func (r *sqliteNewsRepository) invalidateSites() {
+	key := r.appContext.Config.ConnectionString()
+	siteCache.Lock()
+	defer siteCache.Unlock()
+	delete(siteCache.byDb, key)
+	siteCache.generation[key]++
+}
+
+// storeSites caches sites, which were loaded while key was at generation. They
+// are dropped if the sites were invalidated since, as they may predate the write.
+func storeSites(key string, generation uint64, sites []core.NewsSite) {
+	siteCache.Lock()
+	defer siteCache.Unlock()
+	if siteCache.generation[key] == generation {
+		siteCache.byDb[key] = sites
+	}
+}
+
+const siteColumns = "id, name, urls, description, language, disabled, article_has_content, user_agent_key, content_rules, no_auto_generate, article_selector, article_exclude_selector, source_type, html_source, content_retention_months, draft"
+
+func scanSite(scanner rowScanner) (core.NewsSite, error) {
+	var site core.NewsSite
+	var urls, contentRules, htmlSource string
+	err := scanner.Scan(&site.Id, &site.Name, &urls, &site.Description, &site.Language,
+		&site.Disabled, &site.ArticleHasContent, &site.UserAgentKey, &contentRules, &site.NoAutoGenerate,
+		&site.ArticleSelector, &site.ArticleExcludeSelector, &site.SourceType, &htmlSource, &site.ContentRetentionMonths, &site.Draft)
+	if err != nil {
+		return site, err
+	}
+	if err := json.Unmarshal([]byte(urls), &site.Urls); err != nil {
+		return site, fmt.Errorf("site %q has invalid urls: %w", site.Name, err)
+	}
+	if err := json.Unmarshal([]byte(contentRules), &site.ContentRules); err != nil {
+		return site, fmt.Errorf("site %q has invalid content rules: %w", site.Name, err)
+	}
+	if err := json.Unmarshal([]byte(htmlSource), &site.HtmlSource); err != nil {
+		return site, fmt.Errorf("site %q has invalid html source: %w", site.Name, err)
+	}
+	return site, nil
+}
+
+// validateSite rejects a site that belongs to no edition. That has to fail when
+// the site is stored, or at the latest when it is loaded, because everything
+// downstream — the analyzer that stems its items, the prompt that writes its
+// fake news — takes the language on trust. The alternative is a panic later, in
+// a background fetch or halfway through a request. A draft is the exception: it
+// stays disabled, and so unread, until it has been reviewed.
+func validateSite(site core.NewsSite) error {
+	if strings.TrimSpace(site.Name) == "" {
+		return fmt.Errorf("site (id %v) has no name", site.Id)
+	}
+	if site.Draft && !site.Disabled {
+		return fmt.Errorf("site %q (id %v) is a draft, and cannot be enabled before it is reviewed", site.Name, site.Id)
+	}
+	if _, ok := lang.Get(site.Language); !ok && !site.Draft {
+		return fmt.Errorf("site %q (id %v) has language %q, which is not one of the editions", site.Name, site.Id, site.Language)
+	}
+	if err := site.CompileRules(); err != nil {
+		return fmt.Errorf("site %q (id %v) has invalid content rules: %w", site.Name, site.Id, err)
+	}
+	selectors := []string{site.ArticleSelector, site.ArticleExcludeSelector}
+	switch site.Source() {
+	case core.SourceRss, core.SourceJsonFeed:
+	case core.SourceHtml:
+		source := site.HtmlSource
+		if source.ItemSelector == "" {
+			return fmt.Errorf("site %q (id %v) is read from html pages, but has no item selector", site.Name, site.Id)
+		}
+		selectors = append(selectors, source.ItemSelector, source.TitleSelector, source.LinkSelector, source.TimeSelector)
+	default:
+		return fmt.Errorf("site %q (id %v) has source type %q, which is not one of %v", site.Name, site.Id, site.SourceType, core.SourceTypes)
+	}
+	// goquery treats a selector it cannot parse as one that matches nothing, so a
+	// typo would quietly turn extraction off, or find no headlines.
+	for _, selector := range selectors {
+		if selector == "" {
+			continue
+		}
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			return fmt.Errorf("site %q (id %v) has invalid selector %q: %w", site.Name, site.Id, selector, err)
+		}
+	}
+	return nil
+}
+
+func (r *sqliteNewsRepository) loadSites(ctx context.Context) ([]core.NewsSite, error) {
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT "+siteColumns+" FROM sites ORDER BY position, id")
+	if err != nil {
+		return nil, fmt.Errorf("error getting sites: %w", err)
+	}
+	defer rows.Close()
+	sites := make([]core.NewsSite, 0)
+	for rows.Next() {
+		site, err := scanSite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning site: %w", err)
+		}
+		if err := validateSite(site); err != nil {
+			return nil, err
+		}
+		// Compiled once here, the rules are shared by every copy of the site the
+		// cache hands out, and not compiled again for each item fetched.
+		if err := site.CompileRules(); err != nil {
+			return nil, err
+		}
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
+
+// GetSites returns the configured news sites. The slice is cloned because the
+// backing one is shared by every caller: sorting or reordering the result would
+// otherwise corrupt it for everyone.
+func (r *sqliteNewsRepository) GetSites(ctx context.Context) ([]core.NewsSite, error) {
+	key := r.appContext.Config.ConnectionString()
+	siteCache.RLock()
+	sites, ok := siteCache.byDb[key]
+	generation := siteCache.generation[key]
+	siteCache.RUnlock()
+	if ok {
+		return slices.Clone(sites), nil
+	}
+	sites, err := r.loadSites(ctx)
+	if err != nil {
+		return nil, err
+	}
+	storeSites(key, generation, sites)
+	return slices.Clone(sites), nil
+}
+
+func siteArgs(site core.NewsSite) ([]any, error) {
+	urls, err := json.Marshal(nonNil(site.Urls))
+	if err != nil {
+		return nil, err
+	}
+	contentRules := site.ContentRules
+	if contentRules == nil {
+		contentRules = []core.ContentRule{}
+	}
+	rules, err := json.Marshal(contentRules)
+	if err != nil {
+		return nil, err
+	}
+	htmlSource, err := json.Marshal(site.HtmlSource)
+	if err != nil {
+		return nil, err
+	}
+	return []any{site.Name, string(urls), site.Description, site.Language, site.Disabled,
+		site.ArticleHasContent, site.UserAgentKey, string(rules), site.NoAutoGenerate,
+		site.ArticleSelector, site.ArticleExcludeSelector, site.Source(), string(htmlSource), site.ContentRetentionMonths, site.Draft}, nil
+}
+
+// nonNil stores an absent list as [] rather than null.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// CreateSite stores a new site, listed after the existing ones, and returns its
+// id. The id of site is ignored.
+func (r *sqliteNewsRepository) CreateSite(ctx context.Context, site core.NewsSite) (int, error) {
+	if err := validateSite(site); err != nil {
+		return 0, err
+	}
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return 0, err
+	}
+	args, err := siteArgs(site)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding site %q: %w", site.Name, err)
+	}
+	result, err := db.ExecContext(ctx, "INSERT INTO sites (name, urls, description, language, disabled, article_has_content, user_agent_key, content_rules, no_auto_generate, "+
+		"article_selector, article_exclude_selector, source_type, html_source, content_retention_months, draft, position) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM sites))", args...)
+	if err != nil {
+		return 0, fmt.Errorf("error creating site %q: %w", site.Name, err)
+	}
+	r.invalidateSites()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error getting id of site %q: %w", site.Name, err)
+	}
+	return int(id), nil
+}
+
+// UpdateSite overwrites the stored site with the same id.
+func (r *sqliteNewsRepository) UpdateSite(ctx context.Context, site core.NewsSite) error {
+	if err := validateSite(site); err != nil {
+		return err
+	}
+	db, err := db.Open(r.appContext.Config)
+	if err != nil {
+		return err
+	}
+	args, err := siteArgs(site)
+	if err != nil {
+		return fmt.Errorf("error encoding site %q: %w", site.Name, err)
+	}
+	result, err := db.ExecContext(ctx, "UPDATE sites SET name = ?, urls = ?, description = ?, language = ?, disabled = ?, "+
+		"article_has_content = ?, user_agent_key = ?, content_rules = ?, no_auto_generate = ?, "+
+		"article_selector = ?, article_exclude_selector = ?, source_type = ?, html_source = ?, content_retention_months = ?, draft = ? WHERE id = ?", append(args, site.Id)...)
+	if err != nil {
+		return fmt.Errorf("error updating site %q: %w", site.Name, err)
+	}
+	r.invalidateSites()
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return fmt.Errorf("error updating site %q: %w", site.Name, sql.ErrNoRows)
+	}
+	return nil
+}
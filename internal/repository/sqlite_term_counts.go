@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/config"
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/lang"
+	"github.com/bjarke-xyz/rasende2/internal/search"
+)
+
+// upsertTermCountSQL adds to a term's count of a site on a day, creating the
+// row if there is none.
+const upsertTermCountSQL = "INSERT INTO term_counts (term, site_id, day, count) VALUES (?, ?, ?, ?) " +
+	"ON CONFLICT (term, site_id, day) DO UPDATE SET count = count + excluded.count"
+
+// countTerms adds delta to the term_counts of each tracked term the title of
+// row id matches, as the row stands in the search index. It is called in the
+// transaction that indexes, reindexes or unindexes the row, with 1 after the
+// row is indexed and -1 before it is unindexed, so the rollup moves in step
+// with rss_items_fts. The day is the one the item was published on in the
+// time zone of the site's edition.
+func countTerms(ctx context.Context, tx *sql.Tx, cfg *config.Config, site core.NewsSite, id int64, published time.Time, delta int) error {
+	l, ok := lang.Get(site.Language)
+	if !ok {
+		return nil
+	}
+	day := published.In(l.Location).Format(time.DateOnly)
+	for _, term := range core.TrackedTerms(cfg, l) {
+		expr, ok := search.TitleMatch(term, site.Language)
+		if !ok {
+			continue
+		}
+		var matched bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM rss_items_fts WHERE rss_items_fts MATCH ? AND rowid = ?)", expr, id).Scan(&matched); err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, upsertTermCountSQL, term, site.Id, day, delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countArticle adds delta to the article_counts of the site in the hour the
+// item was published in, in the transaction that inserts or deletes it.
+func countArticle(ctx context.Context, tx *sql.Tx, site core.NewsSite, published time.Time, delta int) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO article_counts (site_id, hour, count) VALUES (?, ?, ?) "+
+		"ON CONFLICT (site_id, hour) DO UPDATE SET count = count + excluded.count",
+		site.Id, published.Unix()/3600*3600, delta)
+	return err
+}
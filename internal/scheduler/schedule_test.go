@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronNext(t *testing.T) {
+	copenhagen, err := time.LoadLocation("Europe/Copenhagen")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	at := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, copenhagen)
+		if err != nil {
+			t.Fatalf("parse %q: %v", value, err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		spec  string
+		after string
+		want  string
+	}{
+		{"*/10 * * * *", "2025-01-06 10:03", "2025-01-06 10:10"},
+		{"*/10 * * * *", "2025-01-06 10:10", "2025-01-06 10:20"}, // strictly after
+		{"0 3 * * *", "2025-01-06 10:03", "2025-01-07 03:00"},
+		{"30 8-9 * * 1-5", "2025-01-10 09:45", "2025-01-13 08:30"}, // Friday evening to Monday
+		{"0 0 1 * *", "2025-01-31 12:00", "2025-02-01 00:00"},
+		{"0 12 * * 0", "2025-01-06 10:00", "2025-01-12 12:00"},
+		{"0 12 * * 7", "2025-01-06 10:00", "2025-01-12 12:00"}, // 7 is Sunday too
+		{"0 0 29 2 *", "2025-01-01 00:00", "2028-02-29 00:00"},
+		// Day of month and day of week both restricted: either may match.
+		{"0 0 13 * 5", "2025-01-06 00:00", "2025-01-10 00:00"},
+		// 02:30 does not exist on the night clocks go forward in Denmark, so that
+		// night's run is skipped.
+		{"30 2 * * *", "2025-03-30 01:00", "2025-03-31 02:30"},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.spec, copenhagen)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		got := schedule.Next(at(tt.after))
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("%q after %v = %v, want %v", tt.spec, tt.after, got, want)
+		}
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	schedule, err := Parse("15m", time.UTC)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	now := time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+	if got, want := schedule.Next(now), now.Add(15*time.Minute); !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, spec := range []string{"", "10s", "every day", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "0 0 31 2 x"} {
+		if _, err := Parse(spec, time.UTC); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
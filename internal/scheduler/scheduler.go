@@ -0,0 +1,96 @@
+// Package scheduler runs the background jobs — ingestion, fake news generation
+// and cleanup — inside the app, instead of relying on an outside cron to POST to
+// the /api endpoints. The endpoints stay, as manual triggers.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// JobFunc is one run of a job. ctx is cancelled when the app shuts down, and a
+// job is expected to give up promptly when it is.
+type JobFunc func(ctx context.Context) error
+
+type job struct {
+	name     string
+	schedule Schedule
+	run      JobFunc
+}
+
+// Scheduler runs each added job on its own schedule.
+//
+// Each job gets one goroutine that sleeps until the next run time, runs the job
+// to completion, and only then works out the run after that. So a job never
+// overlaps itself: a run that overshoots its slot delays the next one rather
+// than racing it, and missed slots are skipped rather than caught up.
+type Scheduler struct {
+	jobs []job
+	wg   sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add registers a job. It must be called before Start.
+func (s *Scheduler) Add(name string, schedule Schedule, run JobFunc) {
+	s.jobs = append(s.jobs, job{name: name, schedule: schedule, run: run})
+}
+
+// Start launches the jobs. They stop when ctx is cancelled; Wait blocks until
+// they have.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+}
+
+// Wait blocks until every job loop has returned, which after cancellation means
+// until any run in progress has finished.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	for {
+		now := time.Now()
+		next := j.schedule.Next(now)
+		if next.IsZero() {
+			slog.Warn("scheduler: job will never run again", "job", j.name)
+			return
+		}
+		slog.Debug("scheduler: next run", "job", j.name, "at", next)
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.runOnce(ctx, j)
+	}
+}
+
+// runOnce runs the job, and turns a panic into a logged error: a bug in one job
+// must not take the whole process down, nor stop that job's future runs.
+func (s *Scheduler) runOnce(ctx context.Context, j job) {
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.Error("scheduler: job panicked", "job", j.name, "panic", rec)
+		}
+	}()
+	slog.Info("scheduler: job started", "job", j.name)
+	if err := j.run(ctx); err != nil {
+		slog.Error("scheduler: job failed", "job", j.name, "error", err, "duration_s", time.Since(start).Seconds())
+		return
+	}
+	slog.Info("scheduler: job finished", "job", j.name, "duration_s", time.Since(start).Seconds())
+}
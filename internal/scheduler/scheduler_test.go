@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A run that outlasts its interval must delay the next run, not overlap it.
+func TestJobNeverOverlapsItself(t *testing.T) {
+	var running, overlaps, runs atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New()
+	s.Add("slow", interval(time.Millisecond), func(ctx context.Context) error {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		runs.Add(1)
+		return nil
+	})
+	s.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	s.Wait()
+
+	if runs.Load() == 0 {
+		t.Fatal("job never ran")
+	}
+	if overlaps.Load() != 0 {
+		t.Errorf("job overlapped itself %v times", overlaps.Load())
+	}
+}
+
+// Cancelling the context stops a job that is waiting for its next slot, and
+// Wait returns once it has.
+func TestCancelStopsWaitingJobs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New()
+	s.Add("hourly", interval(time.Hour), func(ctx context.Context) error {
+		t.Error("job ran before its slot")
+		return nil
+	})
+	s.Start(ctx)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after cancel")
+	}
+}
@@ -65,20 +65,35 @@ func Analyze(lang string, text string) []string {
 		return nil
 	}
 	tokens := []string{}
+	a.words(text, func(_, _ int, token string) {
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	})
+	return tokens
+}
+
+// words calls fn with the byte range of each word of text, in order, and its
+// token, which is "" for a stop word. It is the pipeline both Analyze and
+// Snippet run, so a snippet marks exactly the words the index matched.
+func (a analyzer) words(text string, fn func(start, end int, token string)) {
 	seg := segment.NewWordSegmenterDirect([]byte(text))
+	offset := 0
 	for seg.Segment() {
+		start := offset
+		offset += len(seg.Bytes())
 		if seg.Type() == segment.None {
 			continue
 		}
 		word := strings.ToLower(string(seg.Bytes()))
 		if _, stop := a.stopWords[word]; stop {
+			fn(start, offset, "")
 			continue
 		}
 		env := snowballstem.NewEnv(word)
 		a.stem(env)
-		tokens = append(tokens, env.Current())
+		fn(start, offset, env.Current())
 	}
-	return tokens
 }
 
 // StemText renders text as the space-joined token stream stored in the FTS5 index.
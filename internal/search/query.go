@@ -0,0 +1,533 @@
+package search
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Query is a parsed search query. Its terms form a tree of phrases and the
+// operators joining them; its filters narrow the whole search, whatever the
+// terms. The syntax is small:
+//
+//	rasende borgere        either word; words side by side are ORed, as always
+//	"rasende borgere"      the phrase
+//	rasende AND borgere    both words
+//	rasende NOT borgere    the first word, but not with the second
+//	rase*                  any word starting with the prefix
+//	(a OR b) AND c         grouping
+//	title:rasende          the word in the title, also when content is searched
+//	site:BT site:"BBC News" only items from these sites
+//	after:2024-03-01       published on the day or later
+//	before:2024-04-01      published before the day
+//
+// The operators are only operators in upper case, so that a search for the
+// English words still works. AND and NOT bind tighter than OR.
+type Query struct {
+	root *queryNode
+	// Sites are the site names the query is restricted to, as written.
+	Sites []string
+	// After and Before are the bounds on the published date, After inclusive
+	// and Before exclusive, or nil. Each is a midnight in the zone the query
+	// was parsed in.
+	After  *time.Time
+	Before *time.Time
+}
+
+// QueryError is a query that cannot be parsed, explained for the person who
+// wrote it. Key names the explanation in the catalogs of internal/lang, and
+// Args are its arguments.
+type QueryError struct {
+	Key  string
+	Args []any
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query: %v %v", e.Key, e.Args)
+}
+
+func queryError(key string, args ...any) *QueryError {
+	return &QueryError{Key: key, Args: args}
+}
+
+// minPrefixRunes is the shortest prefix a prefix search may use. A shorter one
+// expands to most of the index.
+const minPrefixRunes = 2
+
+type nodeKind int
+
+const (
+	nodeWord nodeKind = iota
+	nodePhrase
+	nodeAnd
+	nodeOr
+	nodeNot
+)
+
+// queryNode is a part of a query's terms. A word or phrase holds its text as
+// written, to be stemmed when the query is matched; an operator holds its
+// operands, and for NOT those are what is kept and what is excluded.
+type queryNode struct {
+	kind      nodeKind
+	text      string
+	prefix    bool
+	titleOnly bool
+	children  []*queryNode
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenOperator
+	tokenOpen
+	tokenClose
+	tokenTitle
+	tokenFilter
+)
+
+type queryToken struct {
+	kind   tokenKind
+	text   string
+	field  string
+	prefix bool
+}
+
+// filterFields are the fields that take a value and narrow the whole search.
+var filterFields = []string{"site", "after", "before"}
+
+var operators = []string{"AND", "OR", "NOT"}
+
+// ParseQuery parses text, with the dates of after: and before: in UTC. Errors
+// are *QueryError.
+func ParseQuery(text string) (Query, error) {
+	return ParseQueryIn(text, time.UTC)
+}
+
+// ParseQueryIn parses text, with the dates of after: and before: starting at
+// midnight in loc, the calendar of the edition searched. Errors are
+// *QueryError.
+func ParseQueryIn(text string, loc *time.Location) (Query, error) {
+	tokens, err := lexQuery(text)
+	if err != nil {
+		return Query{}, err
+	}
+	p := &queryParser{tokens: tokens, loc: loc}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return Query{}, err
+	}
+	if p.pos < len(p.tokens) {
+		// parseOr only stops early at a closing parenthesis with none open.
+		return Query{}, queryError("error.query.unexpectedParen")
+	}
+	if root == nil {
+		return Query{}, queryError("error.query.noTerms")
+	}
+	p.query.root = root
+	return p.query, nil
+}
+
+func lexQuery(text string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: tokenOpen})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: tokenClose})
+			i++
+		case c == '"':
+			phrase, next, err := lexQuoted(text, i)
+			if err != nil {
+				return nil, err
+			}
+			token := queryToken{kind: tokenPhrase, text: phrase}
+			if next < len(text) && text[next] == '*' {
+				token.prefix = true
+				next++
+			}
+			tokens = append(tokens, token)
+			i = next
+		default:
+			end := i
+			for end < len(text) && !strings.ContainsRune(" \t\n\r()\"", rune(text[end])) {
+				end++
+			}
+			word := text[i:end]
+			field, value, isField := strings.Cut(word, ":")
+			field = strings.ToLower(field)
+			switch {
+			case isField && field == "title":
+				// The value, if any, is lexed as the next token: a word, a phrase
+				// or a group.
+				tokens = append(tokens, queryToken{kind: tokenTitle})
+				i += len("title:")
+				continue
+			case isField && slices.Contains(filterFields, field):
+				if value == "" && end < len(text) && text[end] == '"' {
+					quoted, next, err := lexQuoted(text, end)
+					if err != nil {
+						return nil, err
+					}
+					value, end = quoted, next
+				}
+				if strings.TrimSpace(value) == "" {
+					return nil, queryError("error.query.filterValue", field+":")
+				}
+				tokens = append(tokens, queryToken{kind: tokenFilter, field: field, text: value})
+			case slices.Contains(operators, word):
+				tokens = append(tokens, queryToken{kind: tokenOperator, text: word})
+			case strings.HasSuffix(word, "*"):
+				tokens = append(tokens, queryToken{kind: tokenWord, text: strings.TrimRight(word, "*"), prefix: true})
+			default:
+				tokens = append(tokens, queryToken{kind: tokenWord, text: word})
+			}
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// lexQuoted reads the quoted text starting at the quote at start, and returns
+// it and the index after the closing quote.
+func lexQuoted(text string, start int) (string, int, error) {
+	end := strings.IndexByte(text[start+1:], '"')
+	if end < 0 {
+		return "", 0, queryError("error.query.unclosedQuote")
+	}
+	return text[start+1 : start+1+end], start + 1 + end + 1, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	query  Query
+	// loc is the zone the dates of the filters are days in.
+	loc *time.Location
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// parseOr parses terms joined by OR, or by nothing, which means the same. depth
+// is how many groups are open.
+func (p *queryParser) parseOr(depth int) (*queryNode, error) {
+	operands := []*queryNode{}
+	for {
+		token, ok := p.peek()
+		if !ok || token.kind == tokenClose {
+			break
+		}
+		if token.kind == tokenOperator && token.text == "OR" {
+			if len(operands) == 0 {
+				return nil, queryError("error.query.missingOperand", token.text)
+			}
+			p.pos++
+			if next, ok := p.peek(); !ok || next.kind == tokenClose || next.kind == tokenOperator {
+				return nil, queryError("error.query.missingOperand", token.text)
+			}
+			continue
+		}
+		operand, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		if operand != nil {
+			operands = append(operands, operand)
+		}
+	}
+	return join(nodeOr, operands), nil
+}
+
+// parseAnd parses terms joined by AND and NOT. A NOT must follow a term: the
+// index can exclude matches, but not list everything that lacks a word.
+func (p *queryParser) parseAnd(depth int) (*queryNode, error) {
+	if token, _ := p.peek(); token.kind == tokenOperator && token.text != "OR" {
+		return nil, queryError("error.query.missingOperand", token.text)
+	}
+	left, err := p.parsePrimary(depth, false)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token, ok := p.peek()
+		if !ok || token.kind != tokenOperator || token.text == "OR" {
+			return left, nil
+		}
+		p.pos++
+		next, ok := p.peek()
+		if ok && next.kind == tokenFilter {
+			return nil, queryError("error.query.nestedFilter", next.field+":")
+		}
+		if !ok || next.kind == tokenClose || next.kind == tokenOperator {
+			return nil, queryError("error.query.missingOperand", token.text)
+		}
+		right, err := p.parsePrimary(depth, false)
+		if err != nil {
+			return nil, err
+		}
+		// A filter, or an empty group, has no terms to join.
+		if left == nil {
+			left = right
+			continue
+		}
+		if right == nil {
+			continue
+		}
+		if token.text == "AND" {
+			left = join(nodeAnd, []*queryNode{left, right})
+		} else {
+			left = &queryNode{kind: nodeNot, children: []*queryNode{left, right}}
+		}
+	}
+}
+
+// parsePrimary parses a word, a phrase, a group or a filter. A filter is
+// recorded on the query and returns no node. Filters narrow the whole search,
+// so one inside a group, where it would seem to narrow only the group, is an
+// error.
+func (p *queryParser) parsePrimary(depth int, titleOnly bool) (*queryNode, error) {
+	token, ok := p.peek()
+	if !ok {
+		return nil, queryError("error.query.filterValue", "title:")
+	}
+	p.pos++
+	switch token.kind {
+	case tokenWord, tokenPhrase:
+		node := &queryNode{kind: nodeWord, text: token.text, prefix: token.prefix, titleOnly: titleOnly}
+		if token.kind == tokenPhrase {
+			node.kind = nodePhrase
+		}
+		if node.prefix && utf8.RuneCountInString(strings.TrimSpace(lastWord(node.text))) < minPrefixRunes {
+			return nil, queryError("error.query.shortPrefix", token.text+"*", minPrefixRunes)
+		}
+		return node, nil
+	case tokenOpen:
+		group, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || next.kind != tokenClose {
+			return nil, queryError("error.query.unclosedParen")
+		}
+		p.pos++
+		if group != nil && titleOnly {
+			group.titleOnly = true
+		}
+		return group, nil
+	case tokenTitle:
+		next, ok := p.peek()
+		if !ok || next.kind == tokenClose || next.kind == tokenOperator || next.kind == tokenFilter || next.kind == tokenTitle {
+			return nil, queryError("error.query.filterValue", "title:")
+		}
+		return p.parsePrimary(depth, true)
+	case tokenFilter:
+		if depth > 0 {
+			return nil, queryError("error.query.nestedFilter", token.field+":")
+		}
+		return nil, p.addFilter(token)
+	case tokenClose:
+		return nil, queryError("error.query.unexpectedParen")
+	default:
+		return nil, queryError("error.query.missingOperand", token.text)
+	}
+}
+
+func (p *queryParser) addFilter(token queryToken) error {
+	if token.field == "site" {
+		p.query.Sites = append(p.query.Sites, token.text)
+		return nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, token.text, p.loc)
+	if err != nil {
+		return queryError("error.query.invalidDate", token.field+":"+token.text)
+	}
+	if token.field == "after" {
+		p.query.After = &day
+	} else {
+		p.query.Before = &day
+	}
+	return nil
+}
+
+// join combines operands under an operator, or returns the one operand, or nil
+// for none.
+func join(kind nodeKind, operands []*queryNode) *queryNode {
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return operands[0]
+	default:
+		return &queryNode{kind: kind, children: operands}
+	}
+}
+
+func lastWord(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// Match renders the query's terms as an FTS5 MATCH expression over the tokens
+// in the index, stemmed by lang's analyzer, the one that stemmed the rows they
+// are matched against. Every token is quoted, so nothing a user writes reaches
+// FTS5 as an operator. Without searchContent, all terms match the title only.
+//
+// Reports false when the terms carry nothing searchable — a query of nothing
+// but stop words, say. Callers must return no results then; an empty MATCH
+// expression is a syntax error.
+func (q Query) Match(lang string, searchContent bool) (string, bool) {
+	expr := q.root.match(lang, searchContent)
+	if expr == "" {
+		return "", false
+	}
+	if !searchContent {
+		// expr is one quoted phrase or one parenthesised group, which a column
+		// filter applies to whole.
+		return "{title} : " + expr, true
+	}
+	return expr, true
+}
+
+// TitleMatch parses query and renders it as Match(lang, false) does, for a
+// query whose matches can be rolled up per site and day: one that parses, and
+// has no site: or date filter narrowing it further than that. Reports false for
+// any other query.
+func TitleMatch(query string, lang string) (string, bool) {
+	parsed, err := ParseQuery(query)
+	if err != nil || len(parsed.Sites) > 0 || parsed.After != nil || parsed.Before != nil {
+		return "", false
+	}
+	return parsed.Match(lang, false)
+}
+
+// match renders the node, or "" if nothing in it is searchable. A part with
+// nothing searchable drops out of the operator it is in; the kept side of a
+// NOT with nothing searchable makes the NOT match nothing.
+func (n *queryNode) match(lang string, searchContent bool) string {
+	if n == nil {
+		return ""
+	}
+	var expr string
+	switch n.kind {
+	case nodeWord, nodePhrase:
+		expr = n.matchText(lang)
+	case nodeAnd, nodeOr:
+		parts := []string{}
+		for _, child := range n.children {
+			if part := child.match(lang, searchContent); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		operator := " OR "
+		if n.kind == nodeAnd {
+			operator = " AND "
+		}
+		expr = strings.Join(parts, operator)
+		if len(parts) > 1 {
+			expr = "(" + expr + ")"
+		}
+	case nodeNot:
+		kept := n.children[0].match(lang, searchContent)
+		excluded := n.children[1].match(lang, searchContent)
+		expr = kept
+		if kept != "" && excluded != "" {
+			expr = "(" + kept + " NOT " + excluded + ")"
+		}
+	}
+	if expr != "" && n.titleOnly && searchContent {
+		expr = "{title} : " + expr
+	}
+	return expr
+}
+
+// matchText renders a word or phrase. A word that the analyzer splits in
+// several tokens matches any of them, as the words of a query do; a phrase
+// matches its tokens in order. A prefix applies to the last token.
+func (n *queryNode) matchText(lang string) string {
+	tokens := n.tokens(lang)
+	if len(tokens) == 0 {
+		return ""
+	}
+	if n.kind == nodePhrase || n.prefix {
+		expr := quote(strings.Join(tokens, " "))
+		if n.prefix {
+			expr += " *"
+		}
+		return expr
+	}
+	quoted := make([]string, len(tokens))
+	for i, token := range tokens {
+		quoted[i] = quote(token)
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return "(" + strings.Join(quoted, " OR ") + ")"
+}
+
+// tokens returns the tokens of a word or phrase. The last word of a prefix is
+// kept as written if it is a stop word, as it still begins other words.
+func (n *queryNode) tokens(lang string) []string {
+	tokens := Analyze(lang, n.text)
+	if n.prefix && len(Analyze(lang, lastWord(n.text))) == 0 {
+		tokens = append(tokens, strings.ToLower(lastWord(n.text)))
+	}
+	return tokens
+}
+
+func quote(token string) string {
+	return `"` + strings.ReplaceAll(token, `"`, `""`) + `"`
+}
+
+// Term is a token a query searches for, or with Prefix, the start of one.
+type Term struct {
+	Token  string
+	Prefix bool
+}
+
+// Terms returns the tokens the query's terms match, stemmed by lang's analyzer,
+// for marking the matches in a result. What a NOT excludes is left out: a
+// result cannot contain it.
+func (q Query) Terms(lang string) []Term {
+	terms := []Term{}
+	var walk func(n *queryNode)
+	walk = func(n *queryNode) {
+		if n == nil {
+			return
+		}
+		switch n.kind {
+		case nodeWord, nodePhrase:
+			tokens := n.tokens(lang)
+			for i, token := range tokens {
+				term := Term{Token: token, Prefix: n.prefix && i == len(tokens)-1}
+				if !slices.Contains(terms, term) {
+					terms = append(terms, term)
+				}
+			}
+		case nodeNot:
+			walk(n.children[0])
+		default:
+			for _, child := range n.children {
+				walk(child)
+			}
+		}
+	}
+	walk(q.root)
+	return terms
+}
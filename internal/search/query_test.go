@@ -0,0 +1,113 @@
+package search
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryMatch(t *testing.T) {
+	tests := []struct {
+		query         string
+		searchContent bool
+		want          string
+	}{
+		{"rasende", false, `{title} : "ras"`},
+		{"rasende politiker", false, `{title} : ("ras" OR "politik")`},
+		{"rasende politiker", true, `("ras" OR "politik")`},
+		{`"rasende politiker"`, true, `"ras politik"`},
+		{"rasende AND politiker", true, `("ras" AND "politik")`},
+		{"rasende NOT politiker", true, `("ras" NOT "politik")`},
+		{"hund rasende AND politiker", true, `("hund" OR ("ras" AND "politik"))`},
+		{"(hund OR kat) AND rasende", true, `(("hund" OR "kat") AND "ras")`},
+		{"minist*", true, `"minist" *`},
+		{"de*", true, `"de" *`},
+		{`"rasende politi"*`, true, `"ras politi" *`},
+		{"title:rasende borgere", true, `({title} : "ras" OR "borg")`},
+		{"title:(hund kat)", true, `{title} : ("hund" OR "kat")`},
+		{"rasende og", true, `"ras"`},
+		{"rasende NOT og", true, `"ras"`},
+		{"and or not", true, `("and" OR "or" OR "not")`},
+		{`NEAR("a" "b")`, true, `("near" OR ("a" OR "b"))`},
+		{"site:BT rasende after:2024-03-01", true, `"ras"`},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		got, ok := q.Match("da", tt.searchContent)
+		if !ok || got != tt.want {
+			t.Errorf("ParseQuery(%q).Match = %q, %v, want %q", tt.query, got, ok, tt.want)
+		}
+	}
+}
+
+// A query of nothing but stop words parses, but has nothing to match.
+func TestQueryMatchStopWordsOnly(t *testing.T) {
+	q, err := ParseQuery(`og "i det"`)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	if expr, ok := q.Match("da", true); ok {
+		t.Errorf("Match = %q, want nothing to match", expr)
+	}
+}
+
+func TestQueryFilters(t *testing.T) {
+	q, err := ParseQuery(`site:BT site:"BBC News" Site:dr after:2024-03-01 before:2024-04-01 rasende`)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	if len(q.Sites) != 3 || q.Sites[0] != "BT" || q.Sites[1] != "BBC News" || q.Sites[2] != "dr" {
+		t.Errorf("sites = %q, want BT, BBC News and dr", q.Sites)
+	}
+	if q.After == nil || !q.After.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("after = %v, want 2024-03-01", q.After)
+	}
+	if q.Before == nil || !q.Before.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("before = %v, want 2024-04-01", q.Before)
+	}
+}
+
+func TestQueryFiltersInZone(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Copenhagen")
+	if err != nil {
+		t.Fatalf("load zone: %v", err)
+	}
+	q, err := ParseQueryIn("rasende after:2024-03-01", loc)
+	if err != nil {
+		t.Fatalf("ParseQueryIn: %v", err)
+	}
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, loc); q.After == nil || !q.After.Equal(want) {
+		t.Errorf("after = %v, want %v", q.After, want)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := map[string]string{
+		`"rasende politiker`:        "error.query.unclosedQuote",
+		"(rasende OR hund":          "error.query.unclosedParen",
+		"rasende)":                  "error.query.unexpectedParen",
+		"NOT rasende":               "error.query.missingOperand",
+		"rasende AND":               "error.query.missingOperand",
+		"OR rasende":                "error.query.missingOperand",
+		"rasende OR OR hund":        "error.query.missingOperand",
+		"r*":                        "error.query.shortPrefix",
+		"site:":                     "error.query.filterValue",
+		"rasende title:":            "error.query.filterValue",
+		"(rasende site:BT)":         "error.query.nestedFilter",
+		"rasende NOT site:BT":       "error.query.nestedFilter",
+		"rasende after:1. marts":    "error.query.invalidDate",
+		"site:BT before:2024-04-01": "error.query.noTerms",
+		"":                          "error.query.noTerms",
+	}
+	for query, want := range tests {
+		_, err := ParseQuery(query)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) || queryErr.Key != want {
+			t.Errorf("ParseQuery(%q) = %v, want %v", query, err, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Fragment is a piece of a snippet. Match marks a word that matched one of the
+// terms searched for.
+type Fragment struct {
+	Text  string
+	Match bool
+}
+
+// snippetLead is how much of the text before the first match a snippet keeps,
+// as a share of its length, so the match is read in context.
+const snippetLead = 4
+
+// Snippet returns about maxRunes of text around its first match of terms, cut
+// at whole words and split into fragments with the matching words marked. The
+// index is contentless, so FTS5 cannot highlight; instead text is run through
+// lang's analyzer again, and a word matches when its token does, so "rasende"
+// is marked in a search for "raser". Text without a match gives its start. A
+// cut end is marked with an ellipsis. A language with no analyzer gives no
+// snippet: it is on the path of a search request, where a result without one
+// is better than no results.
+func Snippet(lang string, text string, terms []Term, maxRunes int) []Fragment {
+	a, ok := analyzers[lang]
+	if !ok {
+		return nil
+	}
+	type word struct {
+		start, end int
+		match      bool
+	}
+	words := []word{}
+	firstMatch := -1
+	a.words(text, func(start, end int, token string) {
+		w := word{start: start, end: end, match: matches(terms, token, strings.ToLower(text[start:end]))}
+		if w.match && firstMatch < 0 {
+			firstMatch = len(words)
+		}
+		words = append(words, w)
+	})
+	if len(words) == 0 {
+		return nil
+	}
+
+	// The window runs from the word a lead before the first match to the last
+	// word that ends within maxRunes of it.
+	first := 0
+	if utf8.RuneCountInString(text) > maxRunes && firstMatch > 0 {
+		lead := maxRunes / snippetLead
+		first = firstMatch
+		for first > 0 && utf8.RuneCountInString(text[words[first-1].start:words[firstMatch].start]) <= lead {
+			first--
+		}
+	}
+	start := words[first].start
+	if first == 0 {
+		start = 0
+	}
+	last := first
+	for i := first; i < len(words); i++ {
+		if utf8.RuneCountInString(text[start:words[i].end]) > maxRunes {
+			break
+		}
+		last = i
+	}
+	end := words[last].end
+	if last == len(words)-1 {
+		end = len(text)
+	}
+
+	fragments := []Fragment{}
+	add := func(s string, match bool) {
+		if s == "" {
+			return
+		}
+		if n := len(fragments); n > 0 && fragments[n-1].Match == match {
+			fragments[n-1].Text += s
+			return
+		}
+		fragments = append(fragments, Fragment{Text: s, Match: match})
+	}
+	if start > 0 {
+		add("…", false)
+	}
+	pos := start
+	for _, w := range words[first : last+1] {
+		if !w.match {
+			continue
+		}
+		add(text[pos:w.start], false)
+		add(text[w.start:w.end], true)
+		pos = w.end
+	}
+	add(text[pos:end], false)
+	if end < len(text) {
+		add("…", false)
+	}
+	return fragments
+}
+
+// matches reports whether a word, with its token, is one of terms. A prefix
+// also matches the word as written, which is what a prefix of a stop word is
+// matched against.
+func matches(terms []Term, token string, word string) bool {
+	for _, term := range terms {
+		switch {
+		case token != "" && token == term.Token:
+			return true
+		case term.Prefix && (token != "" && strings.HasPrefix(token, term.Token) || strings.HasPrefix(word, term.Token)):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+// render writes the fragments with the matches in brackets.
+func render(fragments []Fragment) string {
+	var sb strings.Builder
+	for _, fragment := range fragments {
+		if fragment.Match {
+			sb.WriteString("[" + fragment.Text + "]")
+		} else {
+			sb.WriteString(fragment.Text)
+		}
+	}
+	return sb.String()
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("Tallene er dårlige i år. ", 10) + "Ministeren raser over de rasende vælgere. " + strings.Repeat("Intet nyt her. ", 10)
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		max   int
+		want  string
+	}{
+		{"marks inflections", "Ministeren raser over de rasende vælgere.", "rasende", 200, "Ministeren [raser] over de [rasende] vælgere."},
+		{"marks a phrase's words", "En rasende god dessert.", `"god dessert"`, 200, "En rasende [god] [dessert]."},
+		{"marks prefixes", "Ministeren og ministrene", "minist*", 200, "[Ministeren] og [ministrene]"},
+		{"leaves out what NOT excludes", "Rasende hund og glad kat", "rasende NOT kat", 200, "[Rasende] hund og glad kat"},
+		{"no match gives the start", "Glad hund finder ny ejer i dag", "rasende", 20, "Glad hund finder ny…"},
+		{"cuts around the first match", long, "rasende", 60, "…år. Ministeren [raser] over de [rasende] vælgere. Intet nyt her…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q): %v", tt.query, err)
+			}
+			if got := render(Snippet("da", tt.text, q.Terms("da"), tt.max)); got != tt.want {
+				t.Errorf("Snippet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippetOfNothing(t *testing.T) {
+	if got := Snippet("da", " ... ", []Term{{Token: "ras"}}, 200); got != nil {
+		t.Errorf("Snippet of no words = %v, want nil", got)
+	}
+	if got := Snippet("xx", "Rasende borgere", []Term{{Token: "ras"}}, 200); got != nil {
+		t.Errorf("Snippet in a language with no analyzer = %v, want nil", got)
+	}
+}
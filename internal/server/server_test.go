@@ -17,6 +17,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -25,6 +26,7 @@ import (
 	"github.com/bjarke-xyz/rasende2/internal/core"
 	"github.com/bjarke-xyz/rasende2/internal/lang"
 	"github.com/bjarke-xyz/rasende2/internal/repository/db"
+	"github.com/bjarke-xyz/rasende2/internal/search"
 	"github.com/bjarke-xyz/rasende2/internal/server"
 )
 
@@ -47,7 +49,7 @@ const jobKey = "test-job-key"
 var testSite = core.NewsSite{
 	Id:          1,
 	Name:        "Test Site",
-	Language:    "danish",
+	Language:    "da",
 	Description: "a test site",
 	Urls:        []string{"https://example.com/rss"},
 }
@@ -71,13 +73,17 @@ func testArticle() core.FakeNewsDto {
 type fakeService struct {
 	core.NewsService
 
-	created []string // titles passed to CreateFakeNews
-	votes   int
+	created    []string // titles passed to CreateFakeNews
+	votes      int
+	chartTerms []string // terms passed to GetChartData
 
 	// blankContent makes GetFakeNewsByTitle return an article with no content,
 	// which is what sends /generate-article down the generating path instead of
 	// the cached one.
 	blankContent bool
+
+	// busyJobs makes the jobs fail as already running.
+	busyJobs bool
 }
 
 func (f *fakeService) GetIndexPageData(ctx context.Context, l lang.Lang) (*core.IndexPageData, error) {
@@ -90,14 +96,23 @@ func (f *fakeService) GetIndexPageData(ctx context.Context, l lang.Lang) (*core.
 	}, nil
 }
 
-func (f *fakeService) GetChartData(ctx context.Context, l lang.Lang, query string) (core.ChartsResult, error) {
+func (f *fakeService) GetChartData(ctx context.Context, l lang.Lang, terms []string, filter core.SearchFilter, period core.ChartPeriod) (core.ChartsResult, error) {
+	f.chartTerms = terms
 	return core.ChartsResult{}, nil
 }
 
-func (f *fakeService) SearchItems(ctx context.Context, l lang.Lang, query string, searchContent bool, offset, limit int, orderBy string) ([]core.RssSearchResult, error) {
+func (f *fakeService) GetSiteRates(ctx context.Context, l lang.Lang, query string, filter core.SearchFilter, start, end *time.Time) ([]core.SiteRate, error) {
+	return []core.SiteRate{{SiteId: testSite.Id, SiteName: testSite.Name, Matches: 3, Articles: 120, Rate: 25}}, nil
+}
+
+func (f *fakeService) SearchItems(ctx context.Context, l lang.Lang, query string, searchContent bool, filter core.SearchFilter, offset, limit int, orderBy string) ([]core.RssSearchResult, error) {
+	if _, err := search.ParseQuery(query); err != nil {
+		return nil, err
+	}
 	return []core.RssSearchResult{{
 		ItemId: "1", SiteId: 1, SiteName: testSite.Name,
 		Title: "Rasende mand " + query, Link: "https://example.com/a", Published: time.Now(),
+		Snippet: []core.SnippetPart{{Text: "En "}, {Text: "rasende", Match: true}, {Text: " <mand>"}},
 	}}, nil
 }
 
@@ -121,6 +136,23 @@ func (f *fakeService) GetRecentItems(ctx context.Context, siteId, limit int, off
 	}}, nil
 }
 
+func (f *fakeService) GetItem(ctx context.Context, itemId string) (*core.RssItemDto, error) {
+	if itemId != "1" {
+		return nil, nil
+	}
+	return &core.RssItemDto{
+		ItemId: "1", SiteId: testSite.Id, SiteName: testSite.Name,
+		Title: "Kritik af minister", Link: "https://example.com/a", Published: time.Now(),
+	}, nil
+}
+
+func (f *fakeService) GetItemRevisions(ctx context.Context, itemId string) ([]core.RssItemRevision, error) {
+	return []core.RssItemRevision{
+		{Title: "Rasende kritik af minister", SeenAt: time.Now().Add(-time.Hour)},
+		{Title: "Kritik af minister", SeenAt: time.Now()},
+	}, nil
+}
+
 func (f *fakeService) GetPopularFakeNews(ctx context.Context, limit int, after *time.Time, votes int) ([]core.FakeNewsDto, error) {
 	return []core.FakeNewsDto{testArticle()}, nil
 }
@@ -171,13 +203,44 @@ func (f *fakeService) VoteFakeNews(ctx context.Context, siteId int, title string
 	return 3 + f.votes, nil
 }
 
-func (f *fakeService) CleanUpFakeNews(ctx context.Context) error         { return nil }
-func (f *fakeService) FetchAndSaveNewItems(ctx context.Context) error    { return nil }
-func (f *fakeService) RefreshMetrics(ctx context.Context) error          { return nil }
-func (f *fakeService) RebuildSearchIndexAndLogError(ctx context.Context) {}
-func (f *fakeService) CleanUpFakeNewsAndLogError(ctx context.Context)    {}
-func (f *fakeService) Initialise(ctx context.Context)                    {}
-func (f *fakeService) Dispose()                                          {}
+func (f *fakeService) CleanUpFakeNews(ctx context.Context) error      { return f.job() }
+func (f *fakeService) FetchAndSaveNewItems(ctx context.Context) error { return f.job() }
+func (f *fakeService) ArchiveItems(ctx context.Context) error         { return f.job() }
+func (f *fakeService) RefreshMetrics(ctx context.Context) error       { return nil }
+func (f *fakeService) CleanUpFakeNewsAndLogError(ctx context.Context) {}
+func (f *fakeService) Initialise(ctx context.Context)                 {}
+func (f *fakeService) Dispose()                                       {}
+
+func (f *fakeService) job() error {
+	if f.busyJobs {
+		return fmt.Errorf("not starting: %w", core.ErrJobBusy)
+	}
+	return nil
+}
+
+func testJobRun() core.JobRun {
+	return core.JobRun{
+		Id: 7, Kind: core.JobRebuildSearchIndex, Status: core.JobRunning,
+		StartedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Counters: map[string]int{},
+	}
+}
+
+func (f *fakeService) StartRebuildSearchIndex(ctx context.Context) (*core.JobRun, error) {
+	run := testJobRun()
+	return &run, nil
+}
+
+func (f *fakeService) GetJobRuns(ctx context.Context, limit int) ([]core.JobRun, error) {
+	return []core.JobRun{testJobRun()}, nil
+}
+
+func (f *fakeService) GetJobRun(ctx context.Context, id int64) (*core.JobRun, error) {
+	if id != testJobRun().Id {
+		return nil, nil
+	}
+	run := testJobRun()
+	return &run, nil
+}
 
 // fakeAI streams back a fixed script, so the SSE framing is deterministic.
 type fakeAI struct {
@@ -369,13 +432,34 @@ func TestRoutes(t *testing.T) {
 			want: 200, wantBody: "sse",
 		},
 
+		{name: "item page", method: "GET", path: "/da/items/1", want: 200, wantBody: "Rasende kritik af minister"},
+		// An item belongs to its site's edition only.
+		{name: "item page of another edition", method: "GET", path: "/en/items/1", want: 404},
+		{name: "unknown item", method: "GET", path: "/da/items/2", want: 404},
+
 		{name: "search results", method: "POST", path: "/da/search", form: url.Values{"search": {"rasende"}}, want: 200, wantBody: "Rasende mand rasende"},
+		{name: "search result snippet", method: "POST", path: "/da/search", form: url.Values{"search": {"rasende"}}, want: 200, wantBody: "En <mark>rasende</mark> &lt;mand&gt;"},
+		{name: "leaderboard", method: "GET", path: "/da/leaderboard", want: 200, wantBody: "<td>25.0</td>"},
+		{name: "leaderboard over a year", method: "GET", path: "/en/leaderboard?range=1y", want: 200, wantBody: `<option value="1y" selected>`},
+		{name: "leaderboard custom range", method: "GET", path: "/da/leaderboard?range=custom", want: 400},
+		{name: "search syntax error", method: "POST", path: "/da/search", form: url.Values{"search": {`"rasende`}}, want: 400, wantBody: "Et anførselstegn er ikke lukket"},
+		{name: "search chart range", method: "POST", path: "/da/search", form: url.Values{"search": {"rasende"}, "include-charts": {"on"}, "range": {"90d"}, "bucket": {"week"}}, want: 200},
+		{name: "search chart range backwards", method: "POST", path: "/da/search", form: url.Values{"search": {"rasende"}, "include-charts": {"on"}, "range": {"custom"}, "start": {"2024-03-01"}, "end": {"2024-02-01"}}, want: 400, wantBody: "slutdatoen"},
 
 		// Bad input.
 		{name: "article generator without site", method: "GET", path: "/da/article-generator", want: 400},
 		{name: "titles sse without site", method: "GET", path: "/da/generate-titles-sse", want: 400},
 		{name: "sse titles without site", method: "GET", path: "/da/generate-titles", want: 400},
 		{name: "unknown path", method: "GET", path: "/da/nope", want: 404},
+		{name: "admin feeds without admin", method: "GET", path: "/da/admin/feeds", want: 403},
+		{name: "admin sites without admin", method: "GET", path: "/da/admin/sites", want: 403},
+		{name: "admin site without admin", method: "GET", path: "/da/admin/sites/1", want: 403},
+		{name: "save site without admin", method: "POST", path: "/da/admin/sites/new", form: url.Values{"name": {"Ny Avis"}, "language": {"da"}}, want: 403},
+		{name: "test fetch without admin", method: "POST", path: "/da/admin/sites/test-fetch", form: url.Values{"testUrl": {"https://example.com/rss"}}, want: 403},
+		{name: "rule dry run without admin", method: "POST", path: "/da/admin/sites/1/rules/dry-run", form: url.Values{"rule": {"block title ^Quiz"}}, want: 403},
+		{name: "opml export without admin", method: "GET", path: "/da/admin/sites/opml", want: 403},
+		{name: "opml import without admin", method: "POST", path: "/da/admin/sites/opml", form: url.Values{}, want: 403},
+		{name: "rule purge without admin", method: "POST", path: "/da/admin/sites/1/rules/purge", form: url.Values{"rule": {"block title ^Quiz"}}, want: 403},
 		{name: "unknown root path", method: "GET", path: "/robots.txt", want: 404},
 	}
 
@@ -655,6 +739,42 @@ func assertWellFormedSSE(t *testing.T, body string) {
 
 // --- cookies and session ----------------------------------------------------
 
+// A search from the form compares the query with the other terms, and puts all
+// of it in the address bar, where the link opens the page filled in the same.
+func TestSearchStateInUrl(t *testing.T) {
+	app := newTestApp(t)
+
+	rec := app.postForm(t, "/da/search", url.Values{
+		"search":         {"rasende"},
+		"compare":        {"vred, harme,Rasende, "},
+		"range":          {"30d"},
+		"include-charts": {"on"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200\n%s", rec.Code, truncate(rec.Body.String()))
+	}
+	if got, want := app.svc.chartTerms, []string{"rasende", "vred", "harme"}; !slices.Equal(got, want) {
+		t.Errorf("charted terms = %q, want %q", got, want)
+	}
+	link := rec.Header().Get("HX-Replace-Url")
+	if want := "/da/search?compare=vred%2C+harme%2CRasende%2C+&range=30d&search=rasende"; link != want {
+		t.Fatalf("HX-Replace-Url = %q, want %q", link, want)
+	}
+
+	page := app.get(t, link)
+	body := page.Body.String()
+	for _, want := range []string{`value="rasende"`, `value="vred, harme,Rasende, "`, `<option value="30d" selected>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("search page from the link lacks %q", want)
+		}
+	}
+
+	more := app.postForm(t, "/da/search", url.Values{"search": {"rasende"}, "offset": {"100"}})
+	if link := more.Header().Get("HX-Replace-Url"); link != "" {
+		t.Errorf("load more replaced the URL with %q", link)
+	}
+}
+
 func TestVoteSetsCookie(t *testing.T) {
 	app := newTestApp(t)
 	article := testArticle()
@@ -741,23 +861,27 @@ func TestLogoutRedirects(t *testing.T) {
 func TestApiRequiresJobKey(t *testing.T) {
 	app := newTestApp(t)
 
-	paths := []string{
-		"/api/job",
-		"/api/admin/rebuild-index",
-		"/api/admin/auto-generate-fake-news",
-		"/api/admin/clean-fake-news",
+	routes := []struct{ method, path string }{
+		{http.MethodPost, "/api/job"},
+		{http.MethodPost, "/api/admin/rebuild-index"},
+		{http.MethodPost, "/api/admin/auto-generate-fake-news"},
+		{http.MethodPost, "/api/admin/clean-fake-news"},
+		{http.MethodPost, "/api/admin/archive-items"},
+		{http.MethodGet, "/api/admin/jobs"},
+		{http.MethodGet, "/api/admin/jobs/7"},
 	}
 
-	for _, path := range paths {
-		t.Run(path, func(t *testing.T) {
+	for _, route := range routes {
+		method, path := route.method, route.path
+		t.Run(method+" "+path, func(t *testing.T) {
 			t.Run("no key", func(t *testing.T) {
-				req := httptest.NewRequest(http.MethodPost, path, nil)
+				req := httptest.NewRequest(method, path, nil)
 				if rec := app.do(t, req); rec.Code != http.StatusUnauthorized {
 					t.Errorf("status = %d, want 401", rec.Code)
 				}
 			})
 			t.Run("wrong key", func(t *testing.T) {
-				req := httptest.NewRequest(http.MethodPost, path, nil)
+				req := httptest.NewRequest(method, path, nil)
 				req.Header.Set("Authorization", "nope")
 				if rec := app.do(t, req); rec.Code != http.StatusUnauthorized {
 					t.Errorf("status = %d, want 401", rec.Code)
@@ -778,6 +902,49 @@ func TestApiCleanFakeNewsWithKey(t *testing.T) {
 	}
 }
 
+// A job that is already running is not run again, and the trigger says so.
+func TestApiBusyJobConflicts(t *testing.T) {
+	app := newTestApp(t)
+	app.svc.busyJobs = true
+
+	for _, path := range []string{"/api/job", "/api/admin/clean-fake-news", "/api/admin/archive-items"} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", jobKey)
+		if rec := app.do(t, req); rec.Code != http.StatusConflict {
+			t.Errorf("%v: status = %d, want 409\n%s", path, rec.Code, truncate(rec.Body.String()))
+		}
+	}
+}
+
+func TestApiJobRuns(t *testing.T) {
+	app := newTestApp(t)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/api/admin/rebuild-index", http.StatusAccepted},
+		{http.MethodGet, "/api/admin/jobs", http.StatusOK},
+		{http.MethodGet, "/api/admin/jobs?limit=x", http.StatusBadRequest},
+		{http.MethodGet, "/api/admin/jobs/7", http.StatusOK},
+		{http.MethodGet, "/api/admin/jobs/8", http.StatusNotFound},
+		{http.MethodGet, "/api/admin/jobs/x", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", jobKey)
+		rec := app.do(t, req)
+		if rec.Code != tt.want {
+			t.Errorf("%v %v: status = %d, want %d\n%s", tt.method, tt.path, rec.Code, tt.want, truncate(rec.Body.String()))
+		}
+		if rec.Code == http.StatusOK || rec.Code == http.StatusAccepted {
+			if !strings.Contains(rec.Body.String(), `"kind":"rebuild-search-index"`) {
+				t.Errorf("%v %v: body does not describe the run: %s", tt.method, tt.path, truncate(rec.Body.String()))
+			}
+		}
+	}
+}
+
 func truncate(s string) string {
 	if len(s) > 600 {
 		return s[:600] + fmt.Sprintf("... (%d bytes)", len(s))
@@ -0,0 +1,348 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/lang"
+	"github.com/bjarke-xyz/rasende2/internal/session"
+	"github.com/bjarke-xyz/rasende2/internal/web/components"
+)
+
+// requireAdmin renders a 403 and reports false unless the visitor is an admin.
+// The admin pages are read-mostly views of operational state, so a plain error
+// page is enough; there is nowhere sensible to redirect a non-admin to.
+func (h *web) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if session.IsAdmin(r) {
+		return true
+	}
+	h.renderError(w, r, http.StatusForbidden, errors.New(LangOf(r).T("error.requiresAdmin")))
+	return false
+}
+
+func (h *web) HandleGetAdminFeeds(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	l := LangOf(r)
+	feeds, err := h.appContext.Deps.Service.GetFeedHealth(r.Context())
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	base := h.getBaseModel(w, r, l.T("page.adminFeeds"))
+	model := components.AdminFeedsViewModel{Base: base, Feeds: feeds, Now: time.Now()}
+	h.renderer.Page(w, r, http.StatusOK, "adminFeeds", base, model)
+}
+
+func (h *web) HandleGetAdminSites(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	sites, err := h.appContext.Deps.Service.GetSites(r.Context())
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	base := h.getBaseModel(w, r, LangOf(r).T("page.adminSites"))
+	model := components.AdminSitesViewModel{Base: base, Sites: sites}
+	h.renderer.Page(w, r, http.StatusOK, "adminSites", base, model)
+}
+
+// HandleGetAdminSite renders the form for the site in the path, or an empty one
+// for /admin/sites/new.
+func (h *web) HandleGetAdminSite(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	site := core.NewsSite{Language: string(LangOf(r).Code)}
+	if r.PathValue("id") != "" {
+		found, ok := h.siteFromPath(w, r)
+		if !ok {
+			return
+		}
+		site = *found
+	}
+	h.renderAdminSite(w, r, http.StatusOK, site, nil)
+}
+
+func (h *web) renderAdminSite(w http.ResponseWriter, r *http.Request, status int, site core.NewsSite, err error) {
+	base := h.getBaseModel(w, r, LangOf(r).T("page.adminSite"))
+	languages := make([]string, len(lang.All))
+	for i, l := range lang.All {
+		languages[i] = string(l.Code)
+	}
+	model := components.AdminSiteViewModel{Base: base, Site: site, Languages: languages, SourceTypes: core.SourceTypes, Err: err}
+	h.renderer.Page(w, r, status, "adminSite", base, model)
+}
+
+// siteFromPath looks up the site whose id is in the path, rendering the error
+// page and reporting false if there is none.
+func (h *web) siteFromPath(w http.ResponseWriter, r *http.Request) (*core.NewsSite, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, fmt.Errorf("invalid site id %q", r.PathValue("id")))
+		return nil, false
+	}
+	site, err := h.appContext.Deps.Service.GetSiteInfoById(r.Context(), id)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	if site == nil {
+		h.renderError(w, r, http.StatusNotFound, fmt.Errorf("site not found for id %v", id))
+		return nil, false
+	}
+	return site, true
+}
+
+// siteFromForm reads the site form. The lists are one entry per line, and blank
+// lines are dropped. The content rules are only parsed here; saving the site is
+// what rejects an invalid one. The site is never a draft: saving the form is
+// reviewing it.
+func siteFromForm(r *http.Request) core.NewsSite {
+	lines := func(name string) []string {
+		values := make([]string, 0)
+		for line := range strings.Lines(r.FormValue(name)) {
+			if line = strings.TrimSpace(line); line != "" {
+				values = append(values, line)
+			}
+		}
+		return values
+	}
+	checked := func(name string) bool { return r.FormValue(name) == "on" }
+	number := func(name string) int {
+		n, _ := strconv.Atoi(strings.TrimSpace(r.FormValue(name)))
+		return n
+	}
+	return core.NewsSite{
+		Name:                   strings.TrimSpace(r.FormValue("name")),
+		Urls:                   lines("urls"),
+		Description:            strings.TrimSpace(r.FormValue("description")),
+		Language:               r.FormValue("language"),
+		UserAgentKey:           strings.TrimSpace(r.FormValue("userAgentKey")),
+		ArticleSelector:        strings.TrimSpace(r.FormValue("articleSelector")),
+		ArticleExcludeSelector: strings.TrimSpace(r.FormValue("articleExcludeSelector")),
+		ArticleHasContent:      checked("articleHasContent"),
+		NoAutoGenerate:         checked("noAutoGenerate"),
+		Disabled:               checked("disabled"),
+		ContentRules:           rules(lines("contentRules")),
+		ContentRetentionMonths: number("contentRetentionMonths"),
+		SourceType:             r.FormValue("sourceType"),
+		HtmlSource: core.HtmlSource{
+			ItemSelector:  strings.TrimSpace(r.FormValue("htmlItemSelector")),
+			TitleSelector: strings.TrimSpace(r.FormValue("htmlTitleSelector")),
+			LinkSelector:  strings.TrimSpace(r.FormValue("htmlLinkSelector")),
+			TimeSelector:  strings.TrimSpace(r.FormValue("htmlTimeSelector")),
+			TimeLayout:    strings.TrimSpace(r.FormValue("htmlTimeLayout")),
+		},
+	}
+}
+
+// HandlePostAdminSite creates the site for /admin/sites/new and updates the one
+// in the path otherwise. An invalid site renders the form again, with what was
+// typed still in it.
+func (h *web) HandlePostAdminSite(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+	site := siteFromForm(r)
+	var err error
+	if r.PathValue("id") == "" {
+		site.Id, err = h.appContext.Deps.Service.CreateSite(ctx, site)
+	} else {
+		existing, ok := h.siteFromPath(w, r)
+		if !ok {
+			return
+		}
+		site.Id = existing.Id
+		err = h.appContext.Deps.Service.UpdateSite(ctx, site)
+	}
+	if err != nil {
+		h.renderAdminSite(w, r, http.StatusBadRequest, site, err)
+		return
+	}
+	http.Redirect(w, r, editionRoot(r)+"/admin/sites", http.StatusSeeOther)
+}
+
+// HandlePostAdminSiteDisabled disables or re-enables the site in the path, from
+// the list.
+func (h *web) HandlePostAdminSiteDisabled(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	site, ok := h.siteFromPath(w, r)
+	if !ok {
+		return
+	}
+	site.Disabled = r.FormValue("disabled") == "true"
+	if !site.Disabled {
+		// Enabling a draft from the list is what approves it.
+		site.Draft = false
+	}
+	if err := h.appContext.Deps.Service.UpdateSite(r.Context(), *site); err != nil {
+		session.AddFlashError(w, r, err)
+	}
+	http.Redirect(w, r, editionRoot(r)+"/admin/sites", http.StatusSeeOther)
+}
+
+// HandlePostAdminSiteTestFetch fetches one feed as the site in the form would,
+// and shows what came back without saving any of it. It reads the whole site
+// form, not the stored site, so a new feed URL or user agent can be tried before
+// it is saved.
+func (h *web) HandlePostAdminSiteTestFetch(w http.ResponseWriter, r *http.Request) {
+	if !session.IsAdmin(r) {
+		h.renderErrorFragment(w, r, http.StatusForbidden, errors.New(LangOf(r).T("error.requiresAdmin")))
+		return
+	}
+	site := siteFromForm(r)
+	url := strings.TrimSpace(r.FormValue("testUrl"))
+	if url == "" && len(site.Urls) > 0 {
+		url = site.Urls[0]
+	}
+	if url == "" {
+		h.renderErrorFragment(w, r, http.StatusBadRequest, errors.New("missing url"))
+		return
+	}
+	model := components.AdminTestFetchViewModel{Url: url}
+	items, err := h.appContext.Deps.Service.TestFetch(r.Context(), site, url)
+	if err != nil {
+		model.Err = err
+	}
+	for _, item := range items {
+		blocked, err := site.Blocks(item)
+		if err != nil {
+			model.Err = err
+			break
+		}
+		model.Items = append(model.Items, components.TestFetchItem{Item: item, Blocked: blocked})
+	}
+	h.renderer.Partial(w, r, http.StatusOK, "adminTestFetch", model)
+}
+
+func rules(lines []string) []core.ContentRule {
+	rules := make([]core.ContentRule, len(lines))
+	for i, line := range lines {
+		rules[i] = core.ParseContentRule(line)
+	}
+	return rules
+}
+
+// HandlePostAdminSiteRuleDryRun lists the stored items of the site in the path
+// that the rule in the form keeps out, without changing anything. It is the check
+// to run before HandlePostAdminSiteRulePurge.
+func (h *web) HandlePostAdminSiteRuleDryRun(w http.ResponseWriter, r *http.Request) {
+	site, rule, ok := h.siteRuleFromRequest(w, r)
+	if !ok {
+		return
+	}
+	model := components.AdminRuleMatchesViewModel{Rule: rule}
+	model.Items, model.Err = h.appContext.Deps.Service.MatchContentRule(r.Context(), *site, rule)
+	h.renderer.Partial(w, r, http.StatusOK, "adminRuleMatches", model)
+}
+
+// HandlePostAdminSiteRulePurge deletes the stored items of the site in the path
+// that the rule in the form keeps out. The rule is not saved with the site; a
+// rule that should keep such items out from now on is added to the site form as
+// well.
+func (h *web) HandlePostAdminSiteRulePurge(w http.ResponseWriter, r *http.Request) {
+	site, rule, ok := h.siteRuleFromRequest(w, r)
+	if !ok {
+		return
+	}
+	model := components.AdminRuleMatchesViewModel{Rule: rule, Purged: true}
+	model.Deleted, model.Err = h.appContext.Deps.Service.PurgeContentRule(r.Context(), *site, rule)
+	h.renderer.Partial(w, r, http.StatusOK, "adminRuleMatches", model)
+}
+
+// siteRuleFromRequest reads the site in the path and the rule in the form of a
+// rule request, rendering an error fragment and reporting false if either is
+// missing or the visitor is not an admin.
+func (h *web) siteRuleFromRequest(w http.ResponseWriter, r *http.Request) (*core.NewsSite, core.ContentRule, bool) {
+	if !session.IsAdmin(r) {
+		h.renderErrorFragment(w, r, http.StatusForbidden, errors.New(LangOf(r).T("error.requiresAdmin")))
+		return nil, core.ContentRule{}, false
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.renderErrorFragment(w, r, http.StatusBadRequest, fmt.Errorf("invalid site id %q", r.PathValue("id")))
+		return nil, core.ContentRule{}, false
+	}
+	site, err := h.appContext.Deps.Service.GetSiteInfoById(r.Context(), id)
+	if err != nil {
+		h.renderErrorFragment(w, r, http.StatusInternalServerError, err)
+		return nil, core.ContentRule{}, false
+	}
+	if site == nil {
+		h.renderErrorFragment(w, r, http.StatusNotFound, fmt.Errorf("site not found for id %v", id))
+		return nil, core.ContentRule{}, false
+	}
+	rule := core.ParseContentRule(r.FormValue("rule"))
+	if _, err := rule.Matches(core.RssItemDto{}); err != nil {
+		h.renderErrorFragment(w, r, http.StatusBadRequest, err)
+		return nil, core.ContentRule{}, false
+	}
+	return site, rule, true
+}
+
+// maxOpmlBytes bounds an uploaded OPML list. Feed reader exports of a few
+// hundred feeds are well under it.
+const maxOpmlBytes = 4 << 20
+
+// HandleGetAdminSitesOpml downloads the site list as OPML.
+func (h *web) HandleGetAdminSitesOpml(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	out, err := h.appContext.Deps.Service.ExportOpml(r.Context())
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="rasende2-sites.opml"`)
+	w.Write(out)
+}
+
+// HandlePostAdminSitesOpml imports an uploaded OPML list, or a .json list of
+// sites as the duda command writes, as draft sites, and goes back to the list,
+// where the drafts wait to be reviewed.
+func (h *web) HandlePostAdminSitesOpml(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	l := LangOf(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxOpmlBytes)
+	file, header, err := r.FormFile("opml")
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, fmt.Errorf("missing OPML file: %w", err))
+		return
+	}
+	defer file.Close()
+	importSites := h.appContext.Deps.Service.ImportOpml
+	if strings.EqualFold(filepath.Ext(header.Filename), ".json") {
+		importSites = h.appContext.Deps.Service.ImportSitesJson
+	}
+	result, err := importSites(r.Context(), file)
+	if err != nil {
+		session.AddFlashError(w, r, err)
+	}
+	if len(result.Created) > 0 || len(result.Skipped) > 0 {
+		session.AddFlashInfo(w, r, l.T("flash.opmlImported", len(result.Created), len(result.Skipped)))
+	}
+	if len(result.Unanalyzed) > 0 {
+		names := make([]string, len(result.Unanalyzed))
+		for i, site := range result.Unanalyzed {
+			names[i] = fmt.Sprintf("%v (%q)", site.Name, site.Language)
+		}
+		session.AddFlashWarn(w, r, l.T("flash.opmlUnanalyzed", strings.Join(names, ", ")))
+	}
+	http.Redirect(w, r, editionRoot(r)+"/admin/sites", http.StatusSeeOther)
+}
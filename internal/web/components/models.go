@@ -2,7 +2,12 @@
 // internal/web/templates. Each page template renders exactly one of these.
 package components
 
-import "github.com/bjarke-xyz/rasende2/internal/core"
+import (
+	"time"
+
+	"github.com/bjarke-xyz/rasende2/internal/core"
+	"github.com/bjarke-xyz/rasende2/internal/search"
+)
 
 type BaseOpenGraphModel struct {
 	Title       string
@@ -115,8 +120,27 @@ func (m IndexModel) Earlier() []core.RssSearchResult {
 	return m.SearchResults.Items[1:]
 }
 
+// ItemViewModel is an item and, when its title or content was ever changed,
+// every version of them, oldest first.
+type ItemViewModel struct {
+	Base      BaseViewModel
+	Item      core.RssItemDto
+	Revisions []core.RssItemRevision
+}
+
+// SearchViewModel is the search page, its form filled in from the URL so that
+// a search, charts and all, can be shared as a link.
 type SearchViewModel struct {
-	Base BaseViewModel
+	Base     BaseViewModel
+	Search   string
+	Compare  string
+	Category string
+	Content  bool
+	Collapse bool
+	Range    string
+	Bucket   string
+	Start    string
+	End      string
 }
 
 type SearchResultsViewModel struct {
@@ -124,9 +148,37 @@ type SearchResultsViewModel struct {
 	ChartsResult  core.ChartsResult
 	NextOffset    int
 	Search        string
+	Category      string
+	Collapse      bool
 	IncludeCharts bool
 }
 
+type LeaderboardViewModel struct {
+	Base  BaseViewModel
+	Rates []core.SiteRate
+	// Range is the period the rates are over.
+	Range       string
+	MinArticles int
+}
+
+// LeaderboardRow is a site's rate and its place. Sites with the same rate share
+// a place, and the next site's place skips past them.
+type LeaderboardRow struct {
+	Rank int
+	core.SiteRate
+}
+
+func (m LeaderboardViewModel) Rows() []LeaderboardRow {
+	rows := make([]LeaderboardRow, len(m.Rates))
+	for i, rate := range m.Rates {
+		rows[i] = LeaderboardRow{Rank: i + 1, SiteRate: rate}
+		if i > 0 && rate.Rate == m.Rates[i-1].Rate {
+			rows[i].Rank = rows[i-1].Rank
+		}
+	}
+	return rows
+}
+
 type FakeNewsViewModel struct {
 	Base         BaseViewModel
 	FakeNews     []core.FakeNewsDto
@@ -198,3 +250,55 @@ type ArticleImgModel struct {
 	Src string
 	Alt string
 }
+
+type AdminFeedsViewModel struct {
+	Base  BaseViewModel
+	Feeds []core.FeedHealth
+	Now   time.Time
+}
+
+type AdminSitesViewModel struct {
+	Base  BaseViewModel
+	Sites []core.NewsSite
+}
+
+// Analyzed reports whether site's language has an analyzer, which a draft
+// imported from OPML may lack.
+func (m AdminSitesViewModel) Analyzed(site core.NewsSite) bool {
+	return search.Supported(site.Language)
+}
+
+// AdminSiteViewModel is the form for creating a site, when Site.Id is 0, or
+// editing one. Err is set when a save was rejected, and Site then holds what was
+// submitted rather than what is stored.
+type AdminSiteViewModel struct {
+	Base        BaseViewModel
+	Site        core.NewsSite
+	Languages   []string
+	SourceTypes []string
+	Err         error
+}
+
+// TestFetchItem is one parsed item, and whether the site's content rules would
+// keep it out.
+type TestFetchItem struct {
+	Item    core.RssItemDto
+	Blocked bool
+}
+
+type AdminTestFetchViewModel struct {
+	Url   string
+	Items []TestFetchItem
+	Err   error
+}
+
+// AdminRuleMatchesViewModel is the result of trying a content rule on a site's
+// stored items: the items it matches on a dry run, or once Purged, how many of
+// them were deleted.
+type AdminRuleMatchesViewModel struct {
+	Rule    core.ContentRule
+	Items   []core.RssItemDto
+	Purged  bool
+	Deleted int
+	Err     error
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/bjarke-xyz/rasende2/internal/web/components"
+)
+
+// HandleGetItem shows one item and the history of its headline. Like every other
+// page, it only shows the edition's own sites: an item of another edition's site
+// is not found here.
+func (h *web) HandleGetItem(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := LangOf(r)
+	itemId := r.PathValue("id")
+	item, err := h.appContext.Deps.Service.GetItem(ctx, itemId)
+	if err != nil {
+		slog.Error("getting item failed", "itemId", itemId, "error", err)
+		h.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if item != nil {
+		site, err := h.appContext.Deps.Service.GetSiteInfoById(ctx, item.SiteId)
+		if err != nil {
+			slog.Error("getting site failed", "siteId", item.SiteId, "error", err)
+			h.renderError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if site == nil || site.Language != string(l.Code) {
+			item = nil
+		}
+	}
+	if item == nil {
+		h.renderError(w, r, http.StatusNotFound, errors.New("item not found"))
+		return
+	}
+	revisions, err := h.appContext.Deps.Service.GetItemRevisions(ctx, itemId)
+	if err != nil {
+		slog.Error("getting item revisions failed", "itemId", itemId, "error", err)
+		h.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	itemViewModel := components.ItemViewModel{
+		Base:      h.getBaseModel(w, r, l.T("page.item", item.Title)),
+		Item:      *item,
+		Revisions: revisions,
+	}
+	h.renderer.Page(w, r, http.StatusOK, "item", itemViewModel.Base, itemViewModel)
+}
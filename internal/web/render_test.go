@@ -25,6 +25,7 @@ func TestTemplatesExecute(t *testing.T) {
 	}
 
 	published := time.Date(2024, 8, 19, 12, 0, 0, 0, time.UTC)
+	nextFetch := published.Add(time.Hour)
 	imgUrl := "https://example.com/img.png"
 	externalId := "abc123"
 	article := core.FakeNewsDto{
@@ -38,11 +39,16 @@ func TestTemplatesExecute(t *testing.T) {
 		ExternalId: &externalId,
 	}
 	item := core.RssSearchResult{SiteName: "DR", Title: "Rasende borger", Link: "https://example.com/a", Published: published}
+	itemWithMetadata := item
+	itemWithMetadata.Authors = []string{"Anna Hansen", "Bo Jensen"}
+	itemWithMetadata.Categories = []string{"Sport"}
+	itemWithMetadata.ImageUrl = imgUrl
+	itemWithMetadata.Duplicates = 2
 	charts := core.ChartsResult{Charts: []core.ChartResult{{
 		Type:     "doughnut",
 		Title:    "Raseri",
 		Labels:   []string{"DR"},
-		Datasets: []core.ChartDataset{{Label: "Raseriudbrud", Data: []int{1}}},
+		Datasets: []core.ChartDataset{{Label: "Raseriudbrud", Data: []float64{1}}},
 	}}}
 	base := components.BaseViewModel{
 		Path:       "/da/fake-news",
@@ -76,10 +82,15 @@ func TestTemplatesExecute(t *testing.T) {
 		{"layout", layoutData{BaseViewModel: anonBase, Content: "<p>hi</p>"}},
 		{"error", components.ErrorModel{Err: errors.New("boom")}},
 		{"error", components.ErrorModel{}}, // nil error must not panic
-		{"index", components.IndexModel{Base: base, SearchResults: core.SearchResult{Items: []core.RssSearchResult{item, item}}, ChartsResult: charts}},
+		{"index", components.IndexModel{Base: base, SearchResults: core.SearchResult{Items: []core.RssSearchResult{item, itemWithMetadata}}, ChartsResult: charts}},
 		{"index", components.IndexModel{Base: base}}, // no results: "Ingen raseri!"
 		{"search", components.SearchViewModel{Base: base}},
-		{"searchResults", components.SearchResultsViewModel{SearchResults: core.SearchResult{Items: []core.RssSearchResult{item}}, ChartsResult: charts, NextOffset: 100, Search: "rasende", IncludeCharts: true}},
+		{"item", components.ItemViewModel{Base: base, Item: core.RssItemDto{ItemId: "1", SiteName: "DR", Title: "Kritik af minister", Link: "https://example.com/a", Authors: []string{"Anna Hansen"}, Published: published}, Revisions: []core.RssItemRevision{
+			{Title: "Rasende kritik af minister", Content: "Første\nAnden", SeenAt: published},
+			{Title: "Kritik af minister", SeenAt: published.Add(time.Hour)},
+		}}},
+		{"item", components.ItemViewModel{Base: base, Item: core.RssItemDto{ItemId: "1", SiteName: "DR", Title: "Kritik af minister"}}}, // never revised, no link
+		{"searchResults", components.SearchResultsViewModel{SearchResults: core.SearchResult{Items: []core.RssSearchResult{item, itemWithMetadata}}, ChartsResult: charts, NextOffset: 100, Search: "rasende", Category: "Sport", Collapse: true, IncludeCharts: true}},
 		{"searchResults", components.SearchResultsViewModel{IncludeCharts: false}},
 		{"fakeNews", components.FakeNewsViewModel{Base: base, FakeNews: []core.FakeNewsDto{article}, Cursor: "c", Sorting: "popular"}},
 		{"fakeNewsGrid", components.FakeNewsViewModel{FakeNews: []core.FakeNewsDto{article}, Sorting: "latest"}}, // empty cursor: no button
@@ -101,6 +112,36 @@ func TestTemplatesExecute(t *testing.T) {
 		{"badge", "DR"},
 		{"itemLink", item},
 		{"barsSvg", nil},
+		{"adminFeeds", components.AdminFeedsViewModel{Base: adminBase, Now: published, Feeds: []core.FeedHealth{
+			{SiteName: "DR", Url: "https://example.com/rss"}, // never fetched
+			{SiteName: "DR", Url: "https://example.com/rss2", LastSuccessAt: &published, LastErrorAt: &published, LastError: "boom", ConsecutiveFailures: 4, NextFetchAt: &nextFetch},
+		}}},
+		{"adminSites", components.AdminSitesViewModel{Base: adminBase, Sites: []core.NewsSite{
+			{Id: 1, Name: "DR", Language: "da", Urls: []string{"https://example.com/rss"}, NoAutoGenerate: true},
+			{Id: 2, Name: "TV2", Language: "da", Disabled: true},
+			{Id: 3, Name: "Die Zeit", Language: "de", Disabled: true, Draft: true},
+		}}},
+		{"adminSite", components.AdminSiteViewModel{Base: adminBase, Languages: []string{"da", "en"}, SourceTypes: core.SourceTypes, Site: core.NewsSite{Language: "da"}}}, // new
+		{"adminSite", components.AdminSiteViewModel{Base: adminBase, Languages: []string{"da", "en"}, SourceTypes: core.SourceTypes, Site: core.NewsSite{
+			Id: 3, Name: "BILLED-BLADET", Language: "da", Urls: []string{"https://example.com/"}, SourceType: core.SourceHtml,
+			HtmlSource: core.HtmlSource{ItemSelector: "article", LinkSelector: "a.headline", TimeSelector: "time"},
+		}}},
+		{"adminSite", components.AdminSiteViewModel{Base: adminBase, Languages: []string{"da", "en"}, Err: errors.New("invalid"), Site: core.NewsSite{
+			Id: 1, Name: "DR", Language: "en", Urls: []string{"https://example.com/rss", "https://example.com/rss2"},
+			ContentRules: []core.ContentRule{{Action: core.RuleBlock, Field: core.RuleTitle, Pattern: "^Quiz"}}, ArticleSelector: "article", ArticleHasContent: true, NoAutoGenerate: true, Disabled: true,
+		}}},
+		{"adminTestFetch", components.AdminTestFetchViewModel{Url: "https://example.com/rss", Items: []components.TestFetchItem{
+			{Item: core.RssItemDto{Title: "Rasende borger", Link: "https://example.com/a", Published: published}},
+			{Item: core.RssItemDto{Title: "Quiz", Published: published}, Blocked: true},
+		}}},
+		{"adminTestFetch", components.AdminTestFetchViewModel{Url: "https://example.com/rss", Err: errors.New("boom")}},
+		{"adminTestFetch", components.AdminTestFetchViewModel{Url: "https://example.com/rss"}}, // empty feed
+		{"adminRuleMatches", components.AdminRuleMatchesViewModel{Rule: core.ParseContentRule("block title ^Quiz"), Items: []core.RssItemDto{
+			{Title: "Quiz: Ugens nyheder", Link: "https://example.com/quiz", Published: published},
+		}}},
+		{"adminRuleMatches", components.AdminRuleMatchesViewModel{Rule: core.ParseContentRule("block title ^Quiz")}}, // no matches
+		{"adminRuleMatches", components.AdminRuleMatchesViewModel{Rule: core.ParseContentRule("block title ^Quiz"), Purged: true, Deleted: 3}},
+		{"adminRuleMatches", components.AdminRuleMatchesViewModel{Rule: core.ParseContentRule("block title ^Quiz"), Purged: true, Err: errors.New("boom")}},
 	}
 
 	// Every case runs in every edition. The template sets differ only in their
@@ -161,7 +202,7 @@ func TestCatalogsCoverTemplates(t *testing.T) {
 	// The Go side uses the rest — page titles, chart labels, flashes, the
 	// sign-in mail — so only report a key no template uses if nothing else
 	// plausibly does either. Keeping this loose beats deleting a live key.
-	goSidePrefixes := []string{"page.", "chart.", "auth.", "mail.", "error.", "lang.", "brand", "nav."}
+	goSidePrefixes := []string{"page.", "chart.", "auth.", "mail.", "error.", "flash.", "lang.", "brand", "nav."}
 	for _, key := range lang.All[0].Keys() {
 		if _, ok := used[key]; ok {
 			continue
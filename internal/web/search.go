@@ -1,37 +1,90 @@
 package web
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/bjarke-xyz/rasende2/internal/core"
 	"github.com/bjarke-xyz/rasende2/internal/httpx"
+	"github.com/bjarke-xyz/rasende2/internal/search"
 	"github.com/bjarke-xyz/rasende2/internal/web/components"
 	"github.com/bjarke-xyz/rasende2/pkg"
 )
 
 var allowedOrderBys = []string{"-published", "published", "-_score", "_score"}
 
+// searchStateKeys are the fields of the search form that make up a search, and
+// so the query string of a link to it.
+var searchStateKeys = []string{"search", "compare", "category", "content", "collapse", "range", "bucket", "start", "end"}
+
 func (h *web) HandleGetSearch(w http.ResponseWriter, r *http.Request) {
 	l := LangOf(r)
 	searchViewModel := components.SearchViewModel{
-		Base: h.getBaseModel(w, r, l.T("page.search")),
+		Base:     h.getBaseModel(w, r, l.T("page.search")),
+		Search:   httpx.StringForm(r, "search", l.DefaultQuery),
+		Compare:  r.FormValue("compare"),
+		Category: r.FormValue("category"),
+		Content:  r.FormValue("content") == "on",
+		Collapse: r.FormValue("collapse") == "on",
+		Range:    httpx.StringForm(r, "range", string(core.DefaultRange)),
+		Bucket:   httpx.StringForm(r, "bucket", string(core.DefaultBucket)),
+		Start:    r.FormValue("start"),
+		End:      r.FormValue("end"),
 	}
 	h.renderer.Page(w, r, http.StatusOK, "search", searchViewModel.Base, searchViewModel)
 }
 
+// searchUrl is the search page with the form filled in as r submitted it.
+func searchUrl(r *http.Request) string {
+	state := url.Values{}
+	for _, key := range searchStateKeys {
+		if value := r.FormValue(key); value != "" {
+			state.Set(key, value)
+		}
+	}
+	return editionRoot(r) + "/search?" + state.Encode()
+}
+
+// chartTerms are the query and the terms it is compared with, which are split
+// on commas, without blanks and repeats, and at most core.MaxChartTerms in all.
+func chartTerms(query string, compare string) []string {
+	terms := []string{query}
+	for _, term := range strings.Split(compare, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" || slices.ContainsFunc(terms, func(t string) bool { return strings.EqualFold(t, term) }) {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	return terms[:min(len(terms), core.MaxChartTerms)]
+}
+
 func (h *web) HandlePostSearch(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	l := LangOf(r)
 	query := r.FormValue("search")
+	filter := core.SearchFilter{
+		Category:           strings.TrimSpace(r.FormValue("category")),
+		CollapseDuplicates: httpx.StringForm(r, "collapse", "") == "on",
+	}
 	offset := httpx.IntForm(r, "offset", 0)
 	limit := min(httpx.IntForm(r, "limit", 100), 100)
 
 	includeCharts := httpx.StringForm(r, "include-charts", "") == "on"
+	period, err := core.NewChartPeriod(r.FormValue("range"), r.FormValue("bucket"), r.FormValue("start"), r.FormValue("end"), time.Now(), l.Location)
+	if err != nil && includeCharts {
+		h.renderErrorFragment(w, r, http.StatusBadRequest, errors.New(l.T("error.chartPeriod")))
+		return
+	}
 
 	chartsPromise := pkg.NewPromise(func() (core.ChartsResult, error) {
 		if includeCharts {
-			return h.appContext.Deps.Service.GetChartData(ctx, l, query)
+			return h.appContext.Deps.Service.GetChartData(ctx, l, chartTerms(query, r.FormValue("compare")), filter, period)
 		} else {
 			return core.ChartsResult{}, nil
 		}
@@ -40,10 +93,9 @@ func (h *web) HandlePostSearch(w http.ResponseWriter, r *http.Request) {
 	searchContentStr := httpx.StringForm(r, "content", "false")
 	searchContent := searchContentStr == "on"
 	orderBy := allowedOrderBys[0]
-	results, err := h.appContext.Deps.Service.SearchItems(ctx, l, query, searchContent, offset, limit, orderBy)
+	results, err := h.appContext.Deps.Service.SearchItems(ctx, l, query, searchContent, filter, offset, limit, orderBy)
 	if err != nil {
-		slog.Error("getting items failed", "query", query, "error", err)
-		h.renderErrorFragment(w, r, http.StatusInternalServerError, err)
+		h.renderSearchError(w, r, query, err)
 		return
 	}
 	if len(results) > limit {
@@ -51,8 +103,7 @@ func (h *web) HandlePostSearch(w http.ResponseWriter, r *http.Request) {
 	}
 	chartsResult, err := chartsPromise.Get()
 	if err != nil {
-		slog.Error("getting charts failed", "query", query, "error", err)
-		h.renderErrorFragment(w, r, http.StatusInternalServerError, err)
+		h.renderSearchError(w, r, query, err)
 		return
 	}
 	searchResultsModel := components.SearchResultsViewModel{
@@ -62,7 +113,26 @@ func (h *web) HandlePostSearch(w http.ResponseWriter, r *http.Request) {
 		ChartsResult:  chartsResult,
 		NextOffset:    offset + limit,
 		Search:        query,
+		Category:      filter.Category,
+		Collapse:      filter.CollapseDuplicates,
 		IncludeCharts: includeCharts,
 	}
+	if includeCharts {
+		// A search from the form, not "load more", and the address bar follows
+		// it, so that the page can be shared or bookmarked as it is.
+		w.Header().Set("HX-Replace-Url", searchUrl(r))
+	}
 	h.renderer.Partial(w, r, http.StatusOK, "searchResults", searchResultsModel)
 }
+
+// renderSearchError explains a query that could not be parsed to whoever wrote
+// it, in their edition's language. Any other error is the server's.
+func (h *web) renderSearchError(w http.ResponseWriter, r *http.Request, query string, err error) {
+	var queryErr *search.QueryError
+	if errors.As(err, &queryErr) {
+		h.renderErrorFragment(w, r, http.StatusBadRequest, errors.New(LangOf(r).T(queryErr.Key, queryErr.Args...)))
+		return
+	}
+	slog.Error("searching failed", "query", query, "error", err)
+	h.renderErrorFragment(w, r, http.StatusInternalServerError, err)
+}
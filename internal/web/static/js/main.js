@@ -14,7 +14,7 @@ function handleCharts() {
             ctx.restore();
         },
     };
-    const makeOptions = (title, type) => {
+    const makeOptions = (title, type, stacked) => {
         const isCartesian = type !== "doughnut" && type !== "pie";
         return {
             responsive: true,
@@ -30,8 +30,12 @@ function handleCharts() {
             },
             ...(isCartesian && {
                 scales: {
+                    x: {
+                        stacked: stacked,
+                    },
                     y: {
                         beginAtZero: true,
+                        stacked: stacked,
                     },
                 },
             }),
@@ -85,7 +89,7 @@ function handleCharts() {
             labels: chart.labels
         }
         chart.options = {
-            ...makeOptions(chart.title, chart.type),
+            ...makeOptions(chart.title, chart.type, !!chart.stacked),
         }
         chart.plugins = [plugin]
         chartPlaceholder.innerHTML = '';
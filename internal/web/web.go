@@ -131,6 +131,8 @@ func (h *web) routes(mux *http.ServeMux, l lang.Lang) {
 	handle(http.MethodGet, "", h.HandleGetIndex)
 	handle(http.MethodGet, "/search", h.HandleGetSearch)
 	handle(http.MethodPost, "/search", h.HandlePostSearch)
+	handle(http.MethodGet, "/leaderboard", h.HandleGetLeaderboard)
+	handle(http.MethodGet, "/items/{id}", h.HandleGetItem)
 	handle(http.MethodGet, "/fake-news", h.HandleGetFakeNews)
 	handle(http.MethodGet, "/fake-news/{slug}", h.HandleGetFakeNewsArticle)
 	handle(http.MethodPost, "/fake-news/{slug}", h.HandleGetFakeNewsArticle)
@@ -144,6 +146,18 @@ func (h *web) routes(mux *http.ServeMux, l lang.Lang) {
 	handle(http.MethodPost, "/reset-article-content", h.HandlePostResetContent)
 	handle(http.MethodGet, "/login", h.HandleGetLogin)
 	handle(http.MethodPost, "/logout", h.HandlePostLogout)
+	handle(http.MethodGet, "/admin/feeds", h.HandleGetAdminFeeds)
+	handle(http.MethodGet, "/admin/sites", h.HandleGetAdminSites)
+	handle(http.MethodGet, "/admin/sites/new", h.HandleGetAdminSite)
+	handle(http.MethodPost, "/admin/sites/new", h.HandlePostAdminSite)
+	handle(http.MethodPost, "/admin/sites/test-fetch", h.HandlePostAdminSiteTestFetch)
+	handle(http.MethodGet, "/admin/sites/opml", h.HandleGetAdminSitesOpml)
+	handle(http.MethodPost, "/admin/sites/opml", h.HandlePostAdminSitesOpml)
+	handle(http.MethodGet, "/admin/sites/{id}", h.HandleGetAdminSite)
+	handle(http.MethodPost, "/admin/sites/{id}", h.HandlePostAdminSite)
+	handle(http.MethodPost, "/admin/sites/{id}/disabled", h.HandlePostAdminSiteDisabled)
+	handle(http.MethodPost, "/admin/sites/{id}/rules/dry-run", h.HandlePostAdminSiteRuleDryRun)
+	handle(http.MethodPost, "/admin/sites/{id}/rules/purge", h.HandlePostAdminSiteRulePurge)
 
 	// /da/ 301s to /da. gin redirected the trailing slash away for free; ServeMux
 	// would 404 it, and it is a URL people have.